@@ -15,6 +15,7 @@
 package main
 
 import (
+	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
@@ -25,6 +26,11 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && (os.Args[1] == "--help" || os.Args[1] == "-h") {
+		fmt.Fprintf(os.Stderr, "usage: protoc --go_cli_out=DIR --go_cli_opt=OPTION[,OPTION...] PROTO...\n\noptions:\n%s", gencli.Options.Usage())
+		return
+	}
+
 	reqBytes, err := ioutil.ReadAll(os.Stdin)
 	if err != nil {
 		log.Fatal(err)
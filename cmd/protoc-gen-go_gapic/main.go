@@ -15,6 +15,7 @@
 package main
 
 import (
+	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
@@ -26,6 +27,11 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && (os.Args[1] == "--help" || os.Args[1] == "-h") {
+		fmt.Fprintf(os.Stderr, "usage: protoc --go_gapic_out=DIR --go_gapic_opt=OPTION[,OPTION...] PROTO...\n\noptions:\n%s", gengapic.Options.Usage())
+		return
+	}
+
 	reqBytes, err := ioutil.ReadAll(os.Stdin)
 	if err != nil {
 		log.Fatal(err)
@@ -40,7 +46,7 @@ func main() {
 		genResp.Error = proto.String(err.Error())
 	}
 
-	sampleResp, err := gensample.PluginEntry(&genReq)
+	sampleResp, err := gensample.PluginEntry(&genReq, gengapic.Options)
 	if err != nil {
 		sampleResp.Error = proto.String(err.Error())
 	}
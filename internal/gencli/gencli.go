@@ -18,7 +18,6 @@ import (
 	"fmt"
 	"go/format"
 	"regexp"
-	"strconv"
 	"strings"
 
 	"github.com/jhump/protoreflect/desc"
@@ -27,6 +26,7 @@ import (
 	"github.com/golang/protobuf/protoc-gen-go/descriptor"
 	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
 	"github.com/googleapis/gapic-generator-go/internal/errors"
+	"github.com/googleapis/gapic-generator-go/internal/options"
 	"github.com/googleapis/gapic-generator-go/internal/pbinfo"
 	"github.com/googleapis/gapic-generator-go/internal/printer"
 	"google.golang.org/genproto/googleapis/api/annotations"
@@ -638,55 +638,51 @@ func (g *gcli) prepareName(m desc.Descriptor) string {
 	return name
 }
 
-func (g *gcli) parseParameters(params *string) (err error) {
-	// by default formatting is enabled
-	g.format = true
+// Options are the options of the generator as a protoc plugin.
+var Options = options.NewSet(
+	&options.Option{
+		Name:     "gapic",
+		Value:    "path[;name]",
+		Usage:    "the import path of the package of the GAPIC clients used by the CLI, and its name if it isn't the last element of the path",
+		Required: true,
+	},
+	&options.Option{
+		Name:     "root",
+		Value:    "name",
+		Usage:    "the name of the root command of the CLI",
+		Required: true,
+	},
+	&options.Option{
+		Name:    "fmt",
+		Kind:    options.Bool,
+		Usage:   "whether to format the generated code",
+		Default: "true",
+	},
+)
 
+func (g *gcli) parseParameters(params *string) error {
 	if params == nil {
 		return fmt.Errorf("Missing required parameters. See usage")
 	}
-
-	for _, str := range strings.Split(*params, ",") {
-		argSep := strings.Index(str, "=")
-		if argSep == -1 {
-			return fmt.Errorf("Unknown parameter: %s", str)
-		}
-
-		switch str[:argSep] {
-		case "gapic":
-			pkg := str[argSep+1:]
-			pkgSep := strings.Index(pkg, ";")
-			if pkgSep >= 0 {
-				// save the package name for Service name reduction later
-				g.gapicName = pkg[pkgSep+1:]
-				pkg = pkg[:pkgSep]
-			}
-
-			putImport(g.imports, &pbinfo.ImportSpec{
-				Name: "gapic",
-				Path: pkg,
-			})
-		case "root":
-			g.root = str[argSep+1:]
-		case "fmt":
-			g.format, err = strconv.ParseBool(str[argSep+1:])
-			if err != nil {
-				return
-			}
-		default:
-			return fmt.Errorf("Unknown parameter: %s", str)
-		}
-	}
-
-	if _, ok := g.imports["gapic"]; !ok {
-		return fmt.Errorf("Missing option \"gapic=[import path]\". Got %q", *params)
+	opts, err := Options.Parse(*params)
+	if err != nil {
+		return err
 	}
 
-	if g.root == "" {
-		return fmt.Errorf("Missing option \"root=[root cmd]\". Got %q", *params)
+	pkg := opts.String("gapic")
+	if pkgSep := strings.Index(pkg, ";"); pkgSep >= 0 {
+		// save the package name for Service name reduction later
+		g.gapicName = pkg[pkgSep+1:]
+		pkg = pkg[:pkgSep]
 	}
+	putImport(g.imports, &pbinfo.ImportSpec{
+		Name: "gapic",
+		Path: pkg,
+	})
+	g.root = opts.String("root")
+	g.format = opts.Bool("fmt")
 
-	return
+	return nil
 }
 
 func buildOneOfUsage(oneof *desc.OneOfDescriptor) string {
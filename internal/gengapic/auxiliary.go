@@ -0,0 +1,236 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gengapic
+
+import (
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/googleapis/gapic-generator-go/internal/errors"
+	"github.com/googleapis/gapic-generator-go/internal/pbinfo"
+	"google.golang.org/genproto/googleapis/longrunning"
+)
+
+// auxTypes gathers details of types we need to generate along with the clients.
+// They are collected from all services of the package and generated once, into auxiliary.go.
+type auxTypes struct {
+	// LRO methods, by the name of their "FooOperation" type.
+	// Services with an LRO method of the same name share the type.
+	lros map[string]servMethod
+
+	// Custom operation methods, by the name of their "FooOperation" type.
+	customOps map[string]*customOp
+
+	// Streaming methods, by the name of their "FooStream" type.
+	// Services with a streaming method of the same name and signature share the type.
+	streams map[string]servMethod
+
+	// "List" of iterator types. We use these to generate FooIterator returned by paging methods.
+	// Since multiple methods can page over the same type, we dedupe by the name of the iterator,
+	// which is in turn determined by the element type name.
+	iters map[string]*iterType
+
+	// Whether any client in the package limits retries with maxAttemptsRetryer.
+	maxAttempts bool
+
+	// Whether any client in the package sends hedged requests with invokeHedged.
+	hedging bool
+
+	// Whether any client in the package sends REST requests with sendRESTRequest.
+	rest bool
+
+	// Messages whose REQUIRED fields are checked before requests are sent, by the name of their validator.
+	validators map[string]*descriptor.DescriptorProto
+
+	// Messages whose OUTPUT_ONLY fields are cleared from copies of requests, by the name of the function clearing them.
+	outputOnlyClearers map[string]*descriptor.DescriptorProto
+
+	// The regexps extracting google.api.routing parameters from requests, without duplicates.
+	// The i-th regexp is compiled into the package-level variable routingRegexp{i}.
+	routingRegexps []string
+}
+
+// servMethod is a method m declared in serv.
+type servMethod struct {
+	serv *descriptor.ServiceDescriptorProto
+	m    *descriptor.MethodDescriptorProto
+}
+
+func newAuxTypes() *auxTypes {
+	return &auxTypes{
+		lros:      map[string]servMethod{},
+		customOps: map[string]*customOp{},
+		streams:   map[string]servMethod{},
+		iters:     map[string]*iterType{},
+
+		validators:         map[string]*descriptor.DescriptorProto{},
+		outputOnlyClearers: map[string]*descriptor.DescriptorProto{},
+	}
+}
+
+// addLRO records that the "FooOperation" type of the LRO method m, declared in serv,
+// must be generated. It errors if another service of the package has an LRO method of
+// the same name with a different google.longrunning.operation_info.
+func (g *generator) addLRO(serv *descriptor.ServiceDescriptorProto, m *descriptor.MethodDescriptorProto) error {
+	name := lroTypeName(m.GetName())
+	if op, ok := g.aux.customOps[name]; ok {
+		return errors.E(nil, "rpc %s.%s needs type %s, which is already used by custom operation %s.%s",
+			serv.GetName(), m.GetName(), name, op.serv.GetName(), op.m.GetName())
+	}
+	prev, ok := g.aux.lros[name]
+	if !ok {
+		g.aux.lros[name] = servMethod{serv: serv, m: m}
+		return nil
+	}
+
+	// Missing operation_info is reported when the type is generated.
+	prevInfo, err1 := proto.GetExtension(prev.m.GetOptions(), longrunning.E_OperationInfo)
+	info, err2 := proto.GetExtension(m.GetOptions(), longrunning.E_OperationInfo)
+	if err1 != nil || err2 != nil {
+		return nil
+	}
+	samePkg := g.descInfo.ParentFile[prev.serv].GetPackage() == g.descInfo.ParentFile[serv].GetPackage()
+	if !samePkg || !proto.Equal(prevInfo.(proto.Message), info.(proto.Message)) {
+		return errors.E(nil, "rpcs %s.%s and %s.%s both need type %s, but have different google.longrunning.operation_info",
+			prev.serv.GetName(), prev.m.GetName(), serv.GetName(), m.GetName(), name)
+	}
+	return nil
+}
+
+// addStream records that the "FooStream" type of the streaming method m, declared in serv,
+// must be generated. It errors if another service of the package has a streaming method
+// of the same name with different request or response types, or a different kind of stream.
+func (g *generator) addStream(serv *descriptor.ServiceDescriptorProto, m *descriptor.MethodDescriptorProto) error {
+	name := streamTypeName(m.GetName())
+	prev, ok := g.aux.streams[name]
+	if !ok {
+		g.aux.streams[name] = servMethod{serv: serv, m: m}
+		return nil
+	}
+	pm := prev.m
+	if pm.GetInputType() != m.GetInputType() || pm.GetOutputType() != m.GetOutputType() ||
+		pm.GetClientStreaming() != m.GetClientStreaming() || pm.GetServerStreaming() != m.GetServerStreaming() {
+		return errors.E(nil, "rpcs %s.%s and %s.%s both need type %s, but have different signatures",
+			prev.serv.GetName(), pm.GetName(), serv.GetName(), m.GetName(), name)
+	}
+	return nil
+}
+
+// genAuxFile generates the types and helpers collected in g.aux, which are shared by
+// the clients of the package. Everything is sorted by name, so that the output does not
+// depend on the order services are generated in. It reports whether anything was generated.
+func (g *generator) genAuxFile() (bool, error) {
+	var lros []string
+	for name := range g.aux.lros {
+		lros = append(lros, name)
+	}
+	var customOps []string
+	for name := range g.aux.customOps {
+		customOps = append(customOps, name)
+	}
+	sort.Strings(lros)
+	sort.Strings(customOps)
+	if len(lros) > 0 || len(customOps) > 0 {
+		g.lroHelpers()
+	}
+	for _, name := range lros {
+		lro := g.aux.lros[name]
+		if err := g.lroType(lro.serv, lro.m); err != nil {
+			return false, err
+		}
+	}
+	for _, name := range customOps {
+		if err := g.customOpType(g.aux.customOps[name]); err != nil {
+			return false, err
+		}
+	}
+
+	var streams []string
+	for name := range g.aux.streams {
+		streams = append(streams, name)
+	}
+	sort.Strings(streams)
+	var retryStreams bool
+	for _, name := range streams {
+		sm := g.aux.streams[name]
+		if err := g.streamType(sm.m); err != nil {
+			return false, err
+		}
+		retryStreams = retryStreams || !sm.m.GetClientStreaming()
+	}
+	if retryStreams {
+		g.streamRetryer()
+	}
+
+	var iters []*iterType
+	for _, iter := range g.aux.iters {
+		iters = append(iters, iter)
+	}
+	sort.Slice(iters, func(i, j int) bool {
+		return iters[i].iterTypeName < iters[j].iterTypeName
+	})
+	for _, iter := range iters {
+		g.pagingIter(iter)
+	}
+
+	validators := len(g.aux.validators) > 0 || len(g.aux.outputOnlyClearers) > 0
+	if err := g.genValidators(); err != nil {
+		return false, err
+	}
+	if err := g.genOutputOnlyClearers(); err != nil {
+		return false, err
+	}
+
+	if len(g.aux.routingRegexps) > 0 {
+		g.routingRegexpVars()
+	}
+	if g.aux.rest {
+		g.restHelpers()
+	}
+	if g.aux.hedging {
+		g.hedgingHelpers()
+	}
+	if g.aux.maxAttempts {
+		g.maxAttemptsRetryer()
+	}
+
+	return len(lros) > 0 || len(customOps) > 0 || len(streams) > 0 || len(iters) > 0 || validators ||
+		len(g.aux.routingRegexps) > 0 || g.aux.rest || g.aux.hedging || g.aux.maxAttempts, nil
+}
+
+func (g *generator) maxAttemptsRetryer() {
+	p := g.printf
+
+	p("// maxAttemptsRetryer limits the number of attempts of a call, including the first one,")
+	p("// to max, and otherwise retries like retryer.")
+	p("type maxAttemptsRetryer struct {")
+	p("  retryer gax.Retryer")
+	p("  max int")
+	p("  attempts int")
+	p("}")
+	p("")
+	p("func (r *maxAttemptsRetryer) Retry(err error) (time.Duration, bool) {")
+	p("  r.attempts++")
+	p("  if r.attempts >= r.max {")
+	p("    return 0, false")
+	p("  }")
+	p("  return r.retryer.Retry(err)")
+	p("}")
+	p("")
+
+	g.imports[pbinfo.ImportSpec{Path: "time"}] = true
+	g.imports[pbinfo.ImportSpec{Name: "gax", Path: "github.com/googleapis/gax-go/v2"}] = true
+}
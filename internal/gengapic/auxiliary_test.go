@@ -0,0 +1,142 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gengapic
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/googleapis/gapic-generator-go/internal/pbinfo"
+	"github.com/googleapis/gapic-generator-go/internal/txtdiff"
+	"google.golang.org/genproto/googleapis/longrunning"
+)
+
+func TestGenAuxFile(t *testing.T) {
+	typep := func(t descriptor.FieldDescriptorProto_Type) *descriptor.FieldDescriptorProto_Type {
+		return &t
+	}
+
+	outputType := &descriptor.DescriptorProto{
+		Name: proto.String("OutputType"),
+	}
+	file := &descriptor.FileDescriptorProto{
+		Package: proto.String("my.pkg"),
+		Options: &descriptor.FileOptions{
+			GoPackage: proto.String("mypackage"),
+		},
+	}
+
+	lroOpts := func(resp string) *descriptor.MethodOptions {
+		opts := &descriptor.MethodOptions{}
+		if err := proto.SetExtension(opts, longrunning.E_OperationInfo, &longrunning.OperationInfo{ResponseType: resp}); err != nil {
+			t.Fatal(err)
+		}
+		return opts
+	}
+	lro := func(name, resp string) *descriptor.MethodDescriptorProto {
+		return &descriptor.MethodDescriptorProto{
+			Name:       proto.String(name),
+			InputType:  proto.String(".my.pkg.OutputType"),
+			OutputType: proto.String(lroType),
+			Options:    lroOpts(resp),
+		}
+	}
+
+	// Both services have a Create LRO, which share the CreateOperation type.
+	foo := &descriptor.ServiceDescriptorProto{
+		Name:   proto.String("Foo"),
+		Method: []*descriptor.MethodDescriptorProto{lro("Update", "OutputType"), lro("Create", "OutputType")},
+	}
+	bar := &descriptor.ServiceDescriptorProto{
+		Name:   proto.String("Bar"),
+		Method: []*descriptor.MethodDescriptorProto{lro("Create", "OutputType")},
+	}
+
+	var g generator
+	g.imports = map[pbinfo.ImportSpec]bool{}
+	g.aux = newAuxTypes()
+
+	commonTypes(&g)
+	g.descInfo.Type[".my.pkg.OutputType"] = outputType
+	g.descInfo.ParentFile[outputType] = file
+	g.descInfo.ParentFile[foo] = file
+	g.descInfo.ParentFile[bar] = file
+
+	for _, serv := range []*descriptor.ServiceDescriptorProto{bar, foo} {
+		for _, m := range serv.GetMethod() {
+			if err := g.addLRO(serv, m); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for _, f := range []*descriptor.FieldDescriptorProto{
+		{Name: proto.String("names"), Type: typep(descriptor.FieldDescriptorProto_TYPE_STRING)},
+		{Name: proto.String("things"), Type: typep(descriptor.FieldDescriptorProto_TYPE_MESSAGE), TypeName: proto.String(".my.pkg.OutputType")},
+		{Name: proto.String("more_names"), Type: typep(descriptor.FieldDescriptorProto_TYPE_STRING)},
+	} {
+		if _, err := g.iterTypeOf(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Foo has a server streaming Watch method.
+	watch := func(clientStreaming bool) *descriptor.MethodDescriptorProto {
+		return &descriptor.MethodDescriptorProto{
+			Name:            proto.String("Watch"),
+			InputType:       proto.String(".my.pkg.OutputType"),
+			OutputType:      proto.String(".my.pkg.OutputType"),
+			ClientStreaming: proto.Bool(clientStreaming),
+			ServerStreaming: proto.Bool(true),
+		}
+	}
+	if err := g.addStream(foo, watch(false)); err != nil {
+		t.Fatal(err)
+	}
+	g.aux.maxAttempts = true
+
+	ok, err := g.genAuxFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Errorf("genAuxFile() = false, want true")
+	}
+	txtdiff.Diff(t, "aux_file", g.pt.String(), filepath.Join("testdata", "aux_file.want"))
+
+	// A Create LRO with a different response cannot share the type.
+	baz := &descriptor.ServiceDescriptorProto{
+		Name:   proto.String("Baz"),
+		Method: []*descriptor.MethodDescriptorProto{lro("Create", emptyValue)},
+	}
+	g.descInfo.ParentFile[baz] = file
+	if err := g.addLRO(baz, baz.GetMethod()[0]); err == nil {
+		t.Errorf("addLRO(Baz.Create) = nil error, want error for conflicting operation_info")
+	}
+
+	// A Watch stream of another kind cannot share the type.
+	if err := g.addStream(bar, watch(false)); err != nil {
+		t.Errorf("addStream(Bar.Watch) = %v, want nil error for the same signature", err)
+	}
+	if err := g.addStream(baz, watch(true)); err == nil {
+		t.Errorf("addStream(Baz.Watch) = nil error, want error for a bidi stream")
+	}
+
+	g.reset()
+	g.aux = newAuxTypes()
+	if ok, err := g.genAuxFile(); err != nil || ok {
+		t.Errorf("genAuxFile() = %t, %v, want false, nil without auxiliary types", ok, err)
+	}
+}
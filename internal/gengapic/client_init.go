@@ -33,7 +33,7 @@ func (g *generator) clientOptions(serv *descriptor.ServiceDescriptorProto, servN
 	{
 		p("// %[1]sCallOptions contains the retry settings for each method of %[1]sClient.", servName)
 		p("type %sCallOptions struct {", servName)
-		for _, m := range serv.Method {
+		for _, m := range g.clientMethods(serv) {
 			p("%s []gax.CallOption", *m.Name)
 		}
 		p("}")
@@ -75,8 +75,10 @@ func (g *generator) clientOptions(serv *descriptor.ServiceDescriptorProto, servN
 	{
 		sFQN := fmt.Sprintf("%s.%s", g.descInfo.ParentFile[serv].GetPackage(), serv.GetName())
 		policies := map[string]*conf.MethodConfig_RetryPolicy{}
+		hedging := map[string]*conf.MethodConfig_HedgingPolicy{}
 		reqLimits := map[string]int{}
 		resLimits := map[string]int{}
+		timeouts := map[string]*duration.Duration{}
 
 		var methCfgs []*conf.MethodConfig
 		if g.grpcConf != nil {
@@ -97,6 +99,7 @@ func (g *generator) clientOptions(serv *descriptor.ServiceDescriptorProto, servN
 				if name.GetMethod() != "" {
 					base = base + "." + name.GetMethod()
 					policies[base] = mc.GetRetryPolicy()
+					hedging[base] = mc.GetHedgingPolicy()
 
 					if maxReq := mc.GetMaxRequestMessageBytes(); maxReq != nil {
 						reqLimits[base] = int(maxReq.GetValue())
@@ -106,6 +109,10 @@ func (g *generator) clientOptions(serv *descriptor.ServiceDescriptorProto, servN
 						resLimits[base] = int(maxRes.GetValue())
 					}
 
+					if timeout := mc.GetTimeout(); timeout != nil {
+						timeouts[base] = timeout
+					}
+
 					continue
 				}
 
@@ -119,6 +126,11 @@ func (g *generator) clientOptions(serv *descriptor.ServiceDescriptorProto, servN
 						policies[fqn] = mc.GetRetryPolicy()
 					}
 
+					// set hedging config
+					if _, ok := hedging[fqn]; !ok {
+						hedging[fqn] = mc.GetHedgingPolicy()
+					}
+
 					// set max request size limit
 					if maxReq := mc.GetMaxRequestMessageBytes(); maxReq != nil {
 						if _, ok := reqLimits[fqn]; !ok {
@@ -132,11 +144,38 @@ func (g *generator) clientOptions(serv *descriptor.ServiceDescriptorProto, servN
 							resLimits[fqn] = int(maxRes.GetValue())
 						}
 					}
+
+					// set timeout
+					if timeout := mc.GetTimeout(); timeout != nil {
+						if _, ok := timeouts[fqn]; !ok {
+							timeouts[fqn] = timeout
+						}
+					}
 				}
 			}
 		}
 
-		if len(policies) > 0 {
+		// timeouts are applied by the methods themselves, see applyTimeout
+		g.timeouts = map[*descriptor.MethodDescriptorProto]int64{}
+		// hedged methods call invokeHedged instead of gax.Invoke
+		g.hedged = map[*descriptor.MethodDescriptorProto]bool{}
+		var anyHedged bool
+		for _, m := range g.clientMethods(serv) {
+			mFQN := sFQN + "." + m.GetName()
+			if timeout, ok := timeouts[mFQN]; ok {
+				g.timeouts[m] = durationToMillis(timeout)
+			} else if deadline, ok := g.backendDeadline(g.methodName(serv, m)); ok {
+				// the gRPC ServiceConfig takes precedence over the backend rules of the service config
+				g.timeouts[m] = deadline
+			}
+			if hedging[mFQN] != nil {
+				g.hedged[m] = true
+				g.aux.hedging = true
+				anyHedged = true
+			}
+		}
+
+		if len(policies) > 0 || anyHedged {
 			g.imports[pbinfo.ImportSpec{Path: "time"}] = true
 			g.imports[pbinfo.ImportSpec{Path: "google.golang.org/grpc/codes"}] = true
 		}
@@ -144,7 +183,7 @@ func (g *generator) clientOptions(serv *descriptor.ServiceDescriptorProto, servN
 		// read retry params from gRPC ServiceConfig
 		p("func default%[1]sCallOptions() *%[1]sCallOptions {", servName)
 		p("  return &%sCallOptions{", servName)
-		for _, m := range serv.GetMethod() {
+		for _, m := range g.clientMethods(serv) {
 			mFQN := sFQN + "." + m.GetName()
 			p("%s: []gax.CallOption{", m.GetName())
 
@@ -158,18 +197,47 @@ func (g *generator) clientOptions(serv *descriptor.ServiceDescriptorProto, servN
 
 			if rp, ok := policies[mFQN]; ok && rp != nil {
 				p("gax.WithRetry(func() gax.Retryer {")
-				p("  return gax.OnCodes([]codes.Code{")
+				// max_attempts includes the original attempt,
+				// so there is nothing to limit unless it allows retries.
+				if rp.GetMaxAttempts() > 1 {
+					g.aux.maxAttempts = true
+					p("  return &maxAttemptsRetryer{")
+					p("    max: %d,", rp.GetMaxAttempts())
+					p("    retryer: gax.OnCodes([]codes.Code{")
+				} else {
+					p("  return gax.OnCodes([]codes.Code{")
+				}
 				for _, c := range rp.GetRetryableStatusCodes() {
 					p("    codes.%s,", snakeToCamel(c.String()))
 				}
 				p("	 }, gax.Backoff{")
-				// this ignores max_attempts
 				p("		Initial:    %d * time.Millisecond,", durationToMillis(rp.GetInitialBackoff()))
 				p("		Max:        %d * time.Millisecond,", durationToMillis(rp.GetMaxBackoff()))
 				p("		Multiplier: %.2f,", rp.GetBackoffMultiplier())
-				p("	 })")
+				if rp.GetMaxAttempts() > 1 {
+					p("	 }),")
+					p("  }")
+				} else {
+					p("	 })")
+				}
 				p("}),")
 			}
+
+			if hp := hedging[mFQN]; hp != nil {
+				maxAttempts := hp.GetMaxAttempts()
+				if maxAttempts < 1 {
+					maxAttempts = 1
+				}
+				p("&hedgingPolicy{")
+				p("  maxAttempts: %d,", maxAttempts)
+				p("  delay: %d * time.Millisecond,", durationToMillis(hp.GetHedgingDelay()))
+				p("  nonFatalCodes: []codes.Code{")
+				for _, c := range hp.GetNonFatalStatusCodes() {
+					p("    codes.%s,", snakeToCamel(c.String()))
+				}
+				p("  },")
+				p("},")
+			}
 			p("},")
 		}
 		p("  }")
@@ -189,7 +257,7 @@ func (g *generator) clientInit(serv *descriptor.ServiceDescriptorProto, servName
 
 	var hasLRO bool
 	for _, m := range serv.Method {
-		if *m.OutputType == lroType {
+		if g.isLRO(m) {
 			hasLRO = true
 			break
 		}
@@ -205,6 +273,7 @@ func (g *generator) clientInit(serv *descriptor.ServiceDescriptorProto, servName
 		p("// %sClient is a client for interacting with %s.", servName, g.apiName)
 		p("//")
 		p("// Methods, except Close, may be called concurrently. However, fields must not be modified concurrently with method calls.")
+		g.deprecationNotice(serv, servName+"Client")
 		p("type %sClient struct {", servName)
 
 		p("// The connection to the service.")
@@ -215,6 +284,17 @@ func (g *generator) clientInit(serv *descriptor.ServiceDescriptorProto, servName
 		p("%s %s.%sClient", grpcClientField(servName), imp.Name, serv.GetName())
 		p("")
 
+		for _, mix := range g.clientMixins(serv) {
+			mixImp, err := g.descInfo.ImportSpec(mix.serv)
+			if err != nil {
+				return err
+			}
+			g.imports[mixImp] = true
+			p("// The gRPC client of the %s.%s mixin.", g.descInfo.ParentFile[mix.serv].GetPackage(), mix.serv.GetName())
+			p("%s %s.%sClient", mix.field, mixImp.Name, mix.serv.GetName())
+			p("")
+		}
+
 		if hasLRO {
 			p("// LROClient is used internally to handle longrunning operations.")
 			p("// It is exposed so that its CallOptions can be modified if required.")
@@ -246,6 +326,7 @@ func (g *generator) clientInit(serv *descriptor.ServiceDescriptorProto, servName
 		p("// New%sClient creates a new %s client.", servName, clientName)
 		p("//")
 		g.comment(g.comments[serv])
+		g.deprecationNotice(serv, "New"+servName+"Client")
 		p("func New%[1]sClient(ctx context.Context, opts ...option.ClientOption) (*%[1]sClient, error) {", servName)
 		p("  conn, err := transport.DialGRPC(ctx, append(default%sClientOptions(), opts...)...)", servName)
 		p("  if err != nil {")
@@ -256,6 +337,13 @@ func (g *generator) clientInit(serv *descriptor.ServiceDescriptorProto, servName
 		p("    CallOptions: default%sCallOptions(),", servName)
 		p("")
 		p("    %s: %s.New%sClient(conn),", grpcClientField(servName), imp.Name, serv.GetName())
+		for _, mix := range g.clientMixins(serv) {
+			mixImp, err := g.descInfo.ImportSpec(mix.serv)
+			if err != nil {
+				return err
+			}
+			p("    %s: %s.New%sClient(conn),", mix.field, mixImp.Name, mix.serv.GetName())
+		}
 		p("  }")
 		p("  c.setGoogleClientInfo()")
 		p("")
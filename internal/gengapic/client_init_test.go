@@ -22,6 +22,7 @@ import (
 	"github.com/golang/protobuf/protoc-gen-go/descriptor"
 	"github.com/golang/protobuf/ptypes/duration"
 	wrappers "github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/google/go-cmp/cmp"
 	conf "github.com/googleapis/gapic-generator-go/internal/grpc_service_config"
 	"github.com/googleapis/gapic-generator-go/internal/pbinfo"
 	"github.com/googleapis/gapic-generator-go/internal/txtdiff"
@@ -32,6 +33,7 @@ import (
 func TestClientOpt(t *testing.T) {
 	var g generator
 	g.imports = map[pbinfo.ImportSpec]bool{}
+	g.aux = &auxTypes{}
 	g.grpcConf = &conf.ServiceConfig{
 		MethodConfig: []*conf.MethodConfig{
 			&conf.MethodConfig{
@@ -43,8 +45,10 @@ func TestClientOpt(t *testing.T) {
 				},
 				MaxRequestMessageBytes:  &wrappers.UInt32Value{Value: 123456},
 				MaxResponseMessageBytes: &wrappers.UInt32Value{Value: 123456},
+				Timeout:                 &duration.Duration{Seconds: 10},
 				RetryOrHedgingPolicy: &conf.MethodConfig_RetryPolicy_{
 					RetryPolicy: &conf.MethodConfig_RetryPolicy{
+						MaxAttempts:       5,
 						InitialBackoff:    &duration.Duration{Nanos: 100000000},
 						MaxBackoff:        &duration.Duration{Seconds: 60},
 						BackoffMultiplier: 1.3,
@@ -54,6 +58,23 @@ func TestClientOpt(t *testing.T) {
 					},
 				},
 			},
+			&conf.MethodConfig{
+				Name: []*conf.MethodConfig_Name{
+					&conf.MethodConfig_Name{
+						Service: "bar.FooService",
+						Method:  "Smack",
+					},
+				},
+				RetryOrHedgingPolicy: &conf.MethodConfig_HedgingPolicy_{
+					HedgingPolicy: &conf.MethodConfig_HedgingPolicy{
+						MaxAttempts:  3,
+						HedgingDelay: &duration.Duration{Nanos: 50000000},
+						NonFatalStatusCodes: []code.Code{
+							code.Code_UNAVAILABLE,
+						},
+					},
+				},
+			},
 			&conf.MethodConfig{
 				Name: []*conf.MethodConfig_Name{
 					&conf.MethodConfig_Name{
@@ -62,6 +83,7 @@ func TestClientOpt(t *testing.T) {
 				},
 				MaxRequestMessageBytes:  &wrappers.UInt32Value{Value: 654321},
 				MaxResponseMessageBytes: &wrappers.UInt32Value{Value: 654321},
+				Timeout:                 &duration.Duration{Seconds: 60},
 				RetryOrHedgingPolicy: &conf.MethodConfig_RetryPolicy_{
 					RetryPolicy: &conf.MethodConfig_RetryPolicy{
 						InitialBackoff:    &duration.Duration{Nanos: 10000000},
@@ -138,6 +160,32 @@ func TestClientOpt(t *testing.T) {
 		}
 		txtdiff.Diff(t, tst.tstName, g.pt.String(), filepath.Join("testdata", tst.tstName+".want"))
 	}
+
+	if !g.aux.maxAttempts {
+		t.Error("clientOptions did not record the use of maxAttemptsRetryer")
+	}
+	if !g.aux.hedging {
+		t.Error("clientOptions did not record the use of hedgingPolicy")
+	}
+
+	g.reset()
+	if err := g.clientOptions(serv, "Foo"); err != nil {
+		t.Fatal(err)
+	}
+	wantTimeouts := map[*descriptor.MethodDescriptorProto]int64{
+		serv.Method[0]: 10000,
+		serv.Method[1]: 60000,
+		serv.Method[2]: 60000,
+	}
+	if diff := cmp.Diff(g.timeouts, wantTimeouts); diff != "" {
+		t.Errorf("clientOptions timeouts got(-),want(+):\n%s", diff)
+	}
+	wantHedged := map[*descriptor.MethodDescriptorProto]bool{
+		serv.Method[2]: true,
+	}
+	if diff := cmp.Diff(g.hedged, wantHedged); diff != "" {
+		t.Errorf("clientOptions hedged got(-),want(+):\n%s", diff)
+	}
 }
 
 func TestClientInit(t *testing.T) {
@@ -151,6 +199,13 @@ func TestClientInit(t *testing.T) {
 			{Name: proto.String("Zip"), OutputType: proto.String("Foo")},
 		},
 	}
+	servDeprecated := &descriptor.ServiceDescriptorProto{
+		Name: proto.String("Foo"),
+		Method: []*descriptor.MethodDescriptorProto{
+			{Name: proto.String("Zip"), OutputType: proto.String("Foo")},
+		},
+		Options: &descriptor.ServiceOptions{Deprecated: proto.Bool(true)},
+	}
 	servLRO := &descriptor.ServiceDescriptorProto{
 		Name: proto.String("Foo"),
 		Method: []*descriptor.MethodDescriptorProto{
@@ -167,6 +222,7 @@ func TestClientInit(t *testing.T) {
 		{tstName: "foo_client_init", servName: "Foo", serv: servPlain},
 		{tstName: "empty_client_init", servName: "", serv: servPlain},
 		{tstName: "lro_client_init", servName: "Foo", serv: servLRO},
+		{tstName: "deprecated_client_init", servName: "Foo", serv: servDeprecated},
 	} {
 		g.descInfo.ParentFile = map[proto.Message]*descriptor.FileDescriptorProto{
 			tst.serv: &descriptor.FileDescriptorProto{
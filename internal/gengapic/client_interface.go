@@ -0,0 +1,133 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gengapic
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/googleapis/gapic-generator-go/internal/pbinfo"
+)
+
+// clientInterface generates the FooClientAPI interface, covering the methods of FooClient
+// that users call, so that they can substitute fakes for the client in tests.
+func (g *generator) clientInterface(serv *descriptor.ServiceDescriptorProto, servName string) error {
+	p := g.printf
+
+	var hasLRO bool
+	var sigs []string
+	for _, m := range g.clientMethods(serv) {
+		sig, err := g.methodSignature(serv, m)
+		if err != nil {
+			return err
+		}
+		sigs = append(sigs, sig)
+
+		flats, err := g.flattenings(serv, m)
+		if err != nil {
+			return err
+		}
+		for _, fl := range flats {
+			sig, err := g.flattenedSignature(serv, m, fl)
+			if err != nil {
+				return err
+			}
+			sigs = append(sigs, sig)
+		}
+
+		if g.isLRO(m) {
+			hasLRO = true
+			sigs = append(sigs, fmt.Sprintf("%[1]s(name string) *%[1]s", lroTypeName(m.GetName())))
+		}
+	}
+
+	p("// %[1]sClientAPI is the interface implemented by %[1]sClient.", servName)
+	p("// It can be used to substitute a fake for the client in tests.")
+	p("type %sClientAPI interface {", servName)
+	p("Close() error")
+	for _, sig := range sigs {
+		p("%s", sig)
+	}
+	p("}")
+	p("")
+
+	p("var _ %[1]sClientAPI = (*%[1]sClient)(nil)", servName)
+	if g.hasTransport(restTransport) && !hasLRO {
+		// FooRESTClient does not support long-running operations,
+		// so it lacks the accessors for them.
+		p("var _ %[1]sClientAPI = (*%[1]sRESTClient)(nil)", servName)
+	}
+	p("")
+
+	return nil
+}
+
+// methodSignature reports the signature, without the receiver, of the client method
+// generated for m. m must be a method declared in serv.
+func (g *generator) methodSignature(serv *descriptor.ServiceDescriptorProto, m *descriptor.MethodDescriptorProto) (string, error) {
+	ret, err := g.methodReturn(serv, m)
+	if err != nil {
+		return "", err
+	}
+
+	// Client and bidi streaming methods receive requests on the stream.
+	var reqParam string
+	if !m.GetClientStreaming() {
+		inType := g.descInfo.Type[m.GetInputType()]
+		inSpec, err := g.descInfo.ImportSpec(inType)
+		if err != nil {
+			return "", err
+		}
+		g.imports[inSpec] = true
+		reqParam = fmt.Sprintf("req *%s.%s, ", inSpec.Name, inType.GetName())
+	}
+
+	g.imports[pbinfo.ImportSpec{Path: "context"}] = true
+	g.imports[pbinfo.ImportSpec{Name: "gax", Path: "github.com/googleapis/gax-go/v2"}] = true
+	return fmt.Sprintf("%s(ctx context.Context, %sopts ...gax.CallOption) %s", m.GetName(), reqParam, ret), nil
+}
+
+// methodReturn reports the result list of the client method generated for m.
+// m must be a method declared in serv.
+func (g *generator) methodReturn(serv *descriptor.ServiceDescriptorProto, m *descriptor.MethodDescriptorProto) (string, error) {
+	switch {
+	case m.GetClientStreaming() || m.GetServerStreaming():
+		return fmt.Sprintf("(*%s, error)", streamTypeName(m.GetName())), nil
+	case g.isLRO(m), g.customOps[m] != nil:
+		return fmt.Sprintf("(*%s, error)", lroTypeName(m.GetName())), nil
+	case m.GetOutputType() == emptyType:
+		return "error", nil
+	}
+
+	pf, err := g.pagingField(m)
+	if err != nil {
+		return "", err
+	}
+	if pf != nil {
+		iter, err := g.iterTypeOf(pf)
+		if err != nil {
+			return "", err
+		}
+		return "*" + iter.iterTypeName, nil
+	}
+
+	outType := g.descInfo.Type[m.GetOutputType()]
+	outSpec, err := g.descInfo.ImportSpec(outType)
+	if err != nil {
+		return "", err
+	}
+	g.imports[outSpec] = true
+	return fmt.Sprintf("(*%s.%s, error)", outSpec.Name, outType.GetName()), nil
+}
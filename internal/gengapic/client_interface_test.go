@@ -0,0 +1,109 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gengapic
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/googleapis/gapic-generator-go/internal/pbinfo"
+	"github.com/googleapis/gapic-generator-go/internal/txtdiff"
+)
+
+func TestClientInterface(t *testing.T) {
+	inputType := &descriptor.DescriptorProto{
+		Name: proto.String("InputType"),
+	}
+	outputType := &descriptor.DescriptorProto{
+		Name: proto.String("OutputType"),
+	}
+
+	file := &descriptor.FileDescriptorProto{
+		Package: proto.String("my.pkg"),
+		Options: &descriptor.FileOptions{
+			GoPackage: proto.String("mypackage"),
+		},
+	}
+
+	unary := &descriptor.MethodDescriptorProto{
+		Name:       proto.String("GetOneThing"),
+		InputType:  proto.String(".my.pkg.InputType"),
+		OutputType: proto.String(".my.pkg.OutputType"),
+	}
+	empty := &descriptor.MethodDescriptorProto{
+		Name:       proto.String("GetEmptyThing"),
+		InputType:  proto.String(".my.pkg.InputType"),
+		OutputType: proto.String(emptyType),
+	}
+	serverStream := &descriptor.MethodDescriptorProto{
+		Name:            proto.String("ServerThings"),
+		InputType:       proto.String(".my.pkg.InputType"),
+		OutputType:      proto.String(".my.pkg.OutputType"),
+		ServerStreaming: proto.Bool(true),
+	}
+	bidiStream := &descriptor.MethodDescriptorProto{
+		Name:            proto.String("BidiThings"),
+		InputType:       proto.String(".my.pkg.InputType"),
+		OutputType:      proto.String(".my.pkg.OutputType"),
+		ServerStreaming: proto.Bool(true),
+		ClientStreaming: proto.Bool(true),
+	}
+	lro := &descriptor.MethodDescriptorProto{
+		Name:       proto.String("LongThing"),
+		InputType:  proto.String(".my.pkg.InputType"),
+		OutputType: proto.String(lroType),
+	}
+
+	servPlain := &descriptor.ServiceDescriptorProto{
+		Name:   proto.String("Foo"),
+		Method: []*descriptor.MethodDescriptorProto{unary, empty, serverStream, bidiStream},
+	}
+	servLRO := &descriptor.ServiceDescriptorProto{
+		Name:   proto.String("Foo"),
+		Method: []*descriptor.MethodDescriptorProto{unary, lro},
+	}
+
+	var g generator
+	g.imports = map[pbinfo.ImportSpec]bool{}
+	g.aux = &auxTypes{
+		iters: map[string]*iterType{},
+	}
+	g.transports = []string{grpcTransport, restTransport}
+
+	commonTypes(&g)
+	for _, typ := range []*descriptor.DescriptorProto{inputType, outputType} {
+		g.descInfo.Type[".my.pkg."+*typ.Name] = typ
+		g.descInfo.ParentFile[typ] = file
+	}
+	g.descInfo.ParentFile[servPlain] = file
+	g.descInfo.ParentFile[servLRO] = file
+
+	for _, tst := range []struct {
+		tstName string
+		serv    *descriptor.ServiceDescriptorProto
+	}{
+		{tstName: "foo_client_interface", serv: servPlain},
+		{tstName: "lro_client_interface", serv: servLRO},
+	} {
+		g.reset()
+		if err := g.clientInterface(tst.serv, "Foo"); err != nil {
+			t.Error(err)
+			continue
+		}
+		txtdiff.Diff(t, tst.tstName, g.pt.String(), filepath.Join("testdata", tst.tstName+".want"))
+	}
+}
@@ -0,0 +1,517 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gengapic
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/googleapis/gapic-generator-go/internal/errors"
+	"github.com/googleapis/gapic-generator-go/internal/pbinfo"
+	"google.golang.org/genproto/googleapis/longrunning"
+)
+
+// genTestFile generates the tests of the client of serv, which call each method of the client
+// against the fake server of serv, in the package with the import path pkgPath and name pkgName.
+func (g *generator) genTestFile(serv *descriptor.ServiceDescriptorProto, pkgPath, pkgName string) error {
+	for _, m := range g.clientMethods(serv) {
+		// The fake server only implements the methods of serv itself, and the fake Operations
+		// service doesn't poll the custom operations of REST APIs.
+		if _, ok := g.mixinFields[m]; ok || g.customOps[m] != nil {
+			continue
+		}
+		if err := g.testMethod(serv, pkgName, m); err != nil {
+			return errors.E(err, "method: %s", m.GetName())
+		}
+	}
+
+	g.imports[pbinfo.ImportSpec{Path: "context"}] = true
+	g.imports[pbinfo.ImportSpec{Path: "testing"}] = true
+	g.imports[pbinfo.ImportSpec{Path: pkgPath + "/" + fakePkgName(pkgName)}] = true
+	g.imports[pbinfo.ImportSpec{Path: "google.golang.org/grpc/codes"}] = true
+	g.imports[pbinfo.ImportSpec{Path: "google.golang.org/grpc/status"}] = true
+	return nil
+}
+
+// testMethod generates the test of the client method of the method m of serv, which calls it
+// once successfully, and once failing with an error status, through pages, operations and streams
+// as m needs.
+func (g *generator) testMethod(serv *descriptor.ServiceDescriptorProto, pkgName string, m *descriptor.MethodDescriptorProto) error {
+	inMsg, ok := g.descInfo.Type[m.GetInputType()].(*descriptor.DescriptorProto)
+	if !ok {
+		return errors.E(nil, "cannot find message type %q, malformed descriptor?", m.GetInputType())
+	}
+	req, err := g.testMessage(inMsg, map[*descriptor.DescriptorProto]bool{})
+	if err != nil {
+		return err
+	}
+	outType := g.descInfo.Type[m.GetOutputType()]
+	outName, outSpec, err := g.descInfo.NameSpec(outType)
+	if err != nil {
+		return err
+	}
+	out := fmt.Sprintf("%s.%s", outSpec.Name, outName)
+	// The tests of long-running methods use the response of the operation instead.
+	if !g.isLRO(m) {
+		g.imports[outSpec] = true
+	}
+
+	pf, err := g.pagingField(m)
+	if err != nil {
+		return err
+	}
+
+	servName := pbinfo.ReduceServName(serv.GetName(), pkgName)
+	name := m.GetName()
+	p := g.printf
+
+	p("func Test%sClient_%s(t *testing.T) {", servName, name)
+	p("  ctx := context.Background()")
+	p("  srv := &%s.%sServer{}", fakePkgName(pkgName), servName)
+	p("  c, stop, err := %s.New%sClient(ctx, srv)", fakePkgName(pkgName), servName)
+	p("  if err != nil {")
+	p("    t.Fatal(err)")
+	p("  }")
+	p("  defer stop()")
+	p("")
+	p("  req := %s", req)
+
+	switch {
+	case m.GetClientStreaming() && m.GetServerStreaming():
+		g.testBidiCall(name, out)
+	case m.GetClientStreaming():
+		g.testClientStreamingCall(name, out)
+	case m.GetServerStreaming():
+		g.testServerStreamingCall(name, out)
+	case pf != nil:
+		if err := g.testPagingCall(name, out, pf); err != nil {
+			return err
+		}
+	case g.isLRO(m):
+		if err := g.testLROCall(serv, m); err != nil {
+			return err
+		}
+	case m.GetOutputType() == emptyType:
+		g.testEmptyCall(name, out)
+	default:
+		g.testUnaryCall(name, out)
+	}
+
+	p("}")
+	p("")
+	return nil
+}
+
+// testErrorStatus generates the check that err, returned by a call of method that failed,
+// has the code of the error status queued by the test.
+func (g *generator) testErrorStatus(method string) {
+	p := g.printf
+
+	p("  if status.Code(err) != codes.InvalidArgument {")
+	p("    t.Errorf(\"%s: got error %%v, want code %%v\", err, codes.InvalidArgument)", method)
+	p("  }")
+}
+
+func (g *generator) testUnaryCall(name, out string) {
+	p := g.printf
+
+	p("  want := &%s{}", out)
+	p("  srv.AddResponse(%q, want)", name)
+	p("  got, err := c.%s(ctx, req)", name)
+	p("  if err != nil {")
+	p("    t.Fatal(err)")
+	p("  }")
+	p("  if !proto.Equal(got, want) {")
+	p("    t.Errorf(\"%s: got %%v, want %%v\", got, want)", name)
+	p("  }")
+	g.testRequests(name, 1)
+	p("")
+	p("  srv.AddError(%q, status.Error(codes.InvalidArgument, \"test error\"))", name)
+	p("  _, err = c.%s(ctx, req)", name)
+	g.testErrorStatus(name)
+}
+
+func (g *generator) testEmptyCall(name, out string) {
+	p := g.printf
+
+	p("  srv.AddResponse(%q, &%s{})", name, out)
+	p("  if err := c.%s(ctx, req); err != nil {", name)
+	p("    t.Fatal(err)")
+	p("  }")
+	g.testRequests(name, 1)
+	p("")
+	p("  srv.AddError(%q, status.Error(codes.InvalidArgument, \"test error\"))", name)
+	p("  err = c.%s(ctx, req)", name)
+	g.testErrorStatus(name)
+}
+
+// testRequests generates the check that the fake server received n copies of req for method.
+func (g *generator) testRequests(method string, n int) {
+	p := g.printf
+
+	p("  reqs := srv.Requests(%q)", method)
+	p("  if len(reqs) != %d {", n)
+	p("    t.Fatalf(\"%s: server got %%d requests, want %d\", len(reqs))", method, n)
+	p("  }")
+	p("  for _, r := range reqs {")
+	p("    if !proto.Equal(r, req) {")
+	p("      t.Errorf(\"%s: server got request %%v, want %%v\", r, req)", method)
+	p("    }")
+	p("  }")
+
+	g.imports[pbinfo.ImportSpec{Path: "github.com/golang/protobuf/proto"}] = true
+}
+
+// testPagingCall generates a call of the paging method name, which iterates through two pages
+// of the response type out, each with one element in the paging field pf.
+func (g *generator) testPagingCall(name, out string, pf *descriptor.FieldDescriptorProto) error {
+	typ, err := g.fieldGoType(pf)
+	if err != nil {
+		return err
+	}
+	var elems string
+	if entry := g.mapEntry(pf); entry != nil {
+		key, err := g.testValue(fieldByName(entry, "key"), map[*descriptor.DescriptorProto]bool{})
+		if err != nil {
+			return err
+		}
+		val, err := g.testValue(fieldByName(entry, "value"), map[*descriptor.DescriptorProto]bool{})
+		if err != nil {
+			return err
+		}
+		elems = fmt.Sprintf("%s{%s: %s}", typ, key, val)
+	} else {
+		elem, err := g.testValue(pf, map[*descriptor.DescriptorProto]bool{})
+		if err != nil {
+			return err
+		}
+		elems = fmt.Sprintf("%s{%s}", typ, elem)
+	}
+	field := snakeToCamel(pf.GetName())
+	p := g.printf
+
+	p("  srv.AddResponse(%q, &%s{%s: %s, NextPageToken: \"next\"})", name, out, field, elems)
+	p("  srv.AddResponse(%q, &%s{%s: %s})", name, out, field, elems)
+	p("  it := c.%s(ctx, req)", name)
+	p("  var n int")
+	p("  for {")
+	p("    _, err := it.Next()")
+	p("    if err == iterator.Done {")
+	p("      break")
+	p("    }")
+	p("    if err != nil {")
+	p("      t.Fatal(err)")
+	p("    }")
+	p("    n++")
+	p("  }")
+	p("  if n != 2 {")
+	p("    t.Errorf(\"%s: got %%d results, want 2\", n)", name)
+	p("  }")
+	p("  if reqs := srv.Requests(%q); len(reqs) != 2 {", name)
+	p("    t.Errorf(\"%s: server got %%d requests, want 2\", len(reqs))", name)
+	p("  }")
+	p("")
+	p("  srv.AddError(%q, status.Error(codes.InvalidArgument, \"test error\"))", name)
+	p("  _, err = c.%s(ctx, req).Next()", name)
+	g.testErrorStatus(name)
+
+	g.imports[pbinfo.ImportSpec{Path: "google.golang.org/api/iterator"}] = true
+	return nil
+}
+
+// testLROCall generates a call of the long-running method m, waiting for an operation that is done,
+// and for one that failed.
+func (g *generator) testLROCall(serv *descriptor.ServiceDescriptorProto, m *descriptor.MethodDescriptorProto) error {
+	eLRO, err := proto.GetExtension(m.GetOptions(), longrunning.E_OperationInfo)
+	if err != nil {
+		return errors.E(nil, "rpc %q returns google.longrunning.Operation but is missing option google.longrunning.operation_info", m.GetName())
+	}
+	respName := eLRO.(*longrunning.OperationInfo).GetResponseType()
+	resp, err := g.lroInfoType(serv, respName)
+	if err != nil {
+		return err
+	}
+	name := m.GetName()
+	p := g.printf
+
+	p("  want := &%s{}", resp)
+	p("  if err := srv.AddLROResponse(%q, want); err != nil {", name)
+	p("    t.Fatal(err)")
+	p("  }")
+	p("  op, err := c.%s(ctx, req)", name)
+	p("  if err != nil {")
+	p("    t.Fatal(err)")
+	p("  }")
+	if respName == emptyValue {
+		p("  if err := op.Wait(ctx); err != nil {")
+		p("    t.Fatal(err)")
+		p("  }")
+	} else {
+		p("  got, err := op.Wait(ctx)")
+		p("  if err != nil {")
+		p("    t.Fatal(err)")
+		p("  }")
+		p("  if !proto.Equal(got, want) {")
+		p("    t.Errorf(\"%s: got %%v, want %%v\", got, want)", name)
+		p("  }")
+	}
+	g.testRequests(name, 1)
+	p("")
+	p("  srv.AddLROError(%q, status.Error(codes.InvalidArgument, \"test error\"))", name)
+	p("  op, err = c.%s(ctx, req)", name)
+	p("  if err != nil {")
+	p("    t.Fatal(err)")
+	p("  }")
+	if respName == emptyValue {
+		p("  err = op.Wait(ctx)")
+	} else {
+		p("  _, err = op.Wait(ctx)")
+	}
+	g.testErrorStatus(name)
+	return nil
+}
+
+// testServerStreamingCall generates a call of the server streaming method name, receiving two responses.
+func (g *generator) testServerStreamingCall(name, out string) {
+	p := g.printf
+
+	p("  want := &%s{}", out)
+	p("  srv.AddResponse(%q, want)", name)
+	p("  srv.AddResponse(%q, want)", name)
+	p("  stream, err := c.%s(ctx, req)", name)
+	p("  if err != nil {")
+	p("    t.Fatal(err)")
+	p("  }")
+	p("  var n int")
+	p("  for {")
+	p("    got, err := stream.Recv()")
+	p("    if err == io.EOF {")
+	p("      break")
+	p("    }")
+	p("    if err != nil {")
+	p("      t.Fatal(err)")
+	p("    }")
+	p("    if !proto.Equal(got, want) {")
+	p("      t.Errorf(\"%s: got %%v, want %%v\", got, want)", name)
+	p("    }")
+	p("    n++")
+	p("  }")
+	p("  if n != 2 {")
+	p("    t.Errorf(\"%s: got %%d responses, want 2\", n)", name)
+	p("  }")
+	g.testRequests(name, 1)
+	p("")
+	p("  srv.AddError(%q, status.Error(codes.InvalidArgument, \"test error\"))", name)
+	p("  stream, err = c.%s(ctx, req)", name)
+	p("  if err != nil {")
+	p("    t.Fatal(err)")
+	p("  }")
+	p("  _, err = stream.Recv()")
+	g.testErrorStatus(name)
+
+}
+
+// testClientStreamingCall generates a call of the client streaming method name, sending two requests.
+func (g *generator) testClientStreamingCall(name, out string) {
+	p := g.printf
+
+	p("  want := &%s{}", out)
+	p("  srv.AddResponse(%q, want)", name)
+	p("  stream, err := c.%s(ctx)", name)
+	p("  if err != nil {")
+	p("    t.Fatal(err)")
+	p("  }")
+	p("  for i := 0; i < 2; i++ {")
+	p("    if err := stream.Send(req); err != nil {")
+	p("      t.Fatal(err)")
+	p("    }")
+	p("  }")
+	p("  got, err := stream.CloseAndRecv()")
+	p("  if err != nil {")
+	p("    t.Fatal(err)")
+	p("  }")
+	p("  if !proto.Equal(got, want) {")
+	p("    t.Errorf(\"%s: got %%v, want %%v\", got, want)", name)
+	p("  }")
+	g.testRequests(name, 2)
+	p("")
+	p("  srv.AddError(%q, status.Error(codes.InvalidArgument, \"test error\"))", name)
+	p("  stream, err = c.%s(ctx)", name)
+	p("  if err != nil {")
+	p("    t.Fatal(err)")
+	p("  }")
+	p("  _, err = stream.CloseAndRecv()")
+	g.testErrorStatus(name)
+
+}
+
+// testBidiCall generates a call of the bidi streaming method name, exchanging two requests
+// for two responses.
+func (g *generator) testBidiCall(name, out string) {
+	p := g.printf
+
+	p("  want := &%s{}", out)
+	p("  srv.AddResponse(%q, want)", name)
+	p("  srv.AddResponse(%q, want)", name)
+	p("  stream, err := c.%s(ctx)", name)
+	p("  if err != nil {")
+	p("    t.Fatal(err)")
+	p("  }")
+	p("  for i := 0; i < 2; i++ {")
+	p("    if err := stream.Send(req); err != nil {")
+	p("      t.Fatal(err)")
+	p("    }")
+	p("    got, err := stream.Recv()")
+	p("    if err != nil {")
+	p("      t.Fatal(err)")
+	p("    }")
+	p("    if !proto.Equal(got, want) {")
+	p("      t.Errorf(\"%s: got %%v, want %%v\", got, want)", name)
+	p("    }")
+	p("  }")
+	p("  if err := stream.CloseSend(); err != nil {")
+	p("    t.Fatal(err)")
+	p("  }")
+	p("  if _, err := stream.Recv(); err != io.EOF {")
+	p("    t.Errorf(\"%s: got error %%v after closing, want io.EOF\", err)", name)
+	p("  }")
+	g.testRequests(name, 2)
+	p("")
+	p("  srv.AddError(%q, status.Error(codes.InvalidArgument, \"test error\"))", name)
+	p("  stream, err = c.%s(ctx)", name)
+	p("  if err != nil {")
+	p("    t.Fatal(err)")
+	p("  }")
+	p("  if err := stream.Send(req); err != nil {")
+	p("    t.Fatal(err)")
+	p("  }")
+	p("  _, err = stream.Recv()")
+	g.testErrorStatus(name)
+
+	g.imports[pbinfo.ImportSpec{Path: "io"}] = true
+}
+
+// testMessage reports a Go expression for a message of type msg with its REQUIRED fields set,
+// and those of the messages in them, so that it passes the checks of validate-required.
+// Messages in seen are left empty, so that recursive messages end.
+func (g *generator) testMessage(msg *descriptor.DescriptorProto, seen map[*descriptor.DescriptorProto]bool) (string, error) {
+	goName, spec, err := g.descInfo.NameSpec(msg)
+	if err != nil {
+		return "", err
+	}
+	g.imports[spec] = true
+	if seen[msg] {
+		return fmt.Sprintf("&%s.%s{}", spec.Name, goName), nil
+	}
+	seen[msg] = true
+	defer delete(seen, msg)
+
+	var fields []string
+	oneofs := map[int32]bool{}
+	for _, f := range msg.GetField() {
+		if !isRequired(f) {
+			continue
+		}
+		val, err := g.testFieldValue(msg, f, seen)
+		if err != nil {
+			return "", err
+		}
+		if f.OneofIndex == nil {
+			fields = append(fields, fmt.Sprintf("%s: %s", snakeToCamel(f.GetName()), val))
+			continue
+		}
+		// Only one field of a oneof can be set.
+		if oneofs[f.GetOneofIndex()] {
+			continue
+		}
+		oneofs[f.GetOneofIndex()] = true
+		oneof := msg.GetOneofDecl()[f.GetOneofIndex()]
+		fields = append(fields, fmt.Sprintf("%s: &%s.%s_%s{%s: %s}",
+			snakeToCamel(oneof.GetName()), spec.Name, goName, snakeToCamel(f.GetName()), snakeToCamel(f.GetName()), val))
+	}
+	return fmt.Sprintf("&%s.%s{%s}", spec.Name, goName, strings.Join(fields, ", ")), nil
+}
+
+// testFieldValue reports a Go expression for a set value of the field f of msg.
+func (g *generator) testFieldValue(msg *descriptor.DescriptorProto, f *descriptor.FieldDescriptorProto, seen map[*descriptor.DescriptorProto]bool) (string, error) {
+	if entry := g.mapEntry(f); entry != nil {
+		typ, err := g.fieldGoType(f)
+		if err != nil {
+			return "", err
+		}
+		key, err := g.testValue(fieldByName(entry, "key"), seen)
+		if err != nil {
+			return "", err
+		}
+		val, err := g.testValue(fieldByName(entry, "value"), seen)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s{%s: %s}", typ, key, val), nil
+	}
+
+	val, err := g.testValue(f, seen)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case f.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+		typ, err := g.fieldGoType(f)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s{%s}", typ, val), nil
+	case !g.hasPresence(msg, f):
+		return val, nil
+	case f.GetType() == descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return val + ".Enum()", nil
+	}
+	// Singular scalar fields of proto2 are pointers.
+	g.imports[pbinfo.ImportSpec{Path: "github.com/golang/protobuf/proto"}] = true
+	typ := upperFirst(pbinfo.GoTypeForPrim[f.GetType()])
+	return fmt.Sprintf("proto.%s(%s)", typ, val), nil
+}
+
+// testValue reports a Go expression for a single value of the field f, that is not the zero value.
+func (g *generator) testValue(f *descriptor.FieldDescriptorProto, seen map[*descriptor.DescriptorProto]bool) (string, error) {
+	switch f.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		msg, ok := g.descInfo.Type[f.GetTypeName()].(*descriptor.DescriptorProto)
+		if !ok {
+			return "", errors.E(nil, "cannot find message type %q, malformed descriptor?", f.GetTypeName())
+		}
+		return g.testMessage(msg, seen)
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		typ := g.descInfo.Type[f.GetTypeName()]
+		if typ == nil {
+			return "", errors.E(nil, "cannot find type %q, malformed descriptor?", f.GetTypeName())
+		}
+		name, spec, err := g.descInfo.NameSpec(typ)
+		if err != nil {
+			return "", err
+		}
+		g.imports[spec] = true
+		return fmt.Sprintf("%s.%s(1)", spec.Name, name), nil
+	case descriptor.FieldDescriptorProto_TYPE_STRING:
+		return `"test"`, nil
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return `[]byte("test")`, nil
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return "true", nil
+	}
+	return "1", nil
+}
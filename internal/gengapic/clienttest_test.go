@@ -0,0 +1,157 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gengapic
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/googleapis/gapic-generator-go/internal/txtdiff"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/genproto/googleapis/longrunning"
+)
+
+func TestTestFile(t *testing.T) {
+	required := func(f *descriptor.FieldDescriptorProto) *descriptor.FieldDescriptorProto {
+		f.Options = &descriptor.FieldOptions{}
+		if err := proto.SetExtension(f.Options, annotations.E_FieldBehavior, []annotations.FieldBehavior{annotations.FieldBehavior_REQUIRED}); err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+	field := func(name string, typ descriptor.FieldDescriptorProto_Type, label descriptor.FieldDescriptorProto_Label, typeName string) *descriptor.FieldDescriptorProto {
+		f := &descriptor.FieldDescriptorProto{
+			Name:  proto.String(name),
+			Type:  typ.Enum(),
+			Label: label.Enum(),
+		}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	optional := descriptor.FieldDescriptorProto_LABEL_OPTIONAL
+	repeated := descriptor.FieldDescriptorProto_LABEL_REPEATED
+	str := descriptor.FieldDescriptorProto_TYPE_STRING
+	msg := descriptor.FieldDescriptorProto_TYPE_MESSAGE
+
+	oneofField := required(field("id", descriptor.FieldDescriptorProto_TYPE_INT64, optional, ""))
+	oneofField.OneofIndex = proto.Int32(0)
+	file := &descriptor.FileDescriptorProto{
+		Package: proto.String("my.pkg"),
+		Syntax:  proto.String("proto3"),
+		Options: &descriptor.FileOptions{GoPackage: proto.String("mypackage")},
+		MessageType: []*descriptor.DescriptorProto{
+			{
+				Name: proto.String("InputType"),
+				Field: []*descriptor.FieldDescriptorProto{
+					required(field("name", str, optional, "")),
+					required(field("kind", descriptor.FieldDescriptorProto_TYPE_ENUM, optional, ".my.pkg.Kind")),
+					required(field("labels", msg, repeated, ".my.pkg.InputType.LabelsEntry")),
+					required(field("parts", msg, repeated, ".my.pkg.Part")),
+					oneofField,
+					field("comment", str, optional, ""),
+					field("page_size", descriptor.FieldDescriptorProto_TYPE_INT32, optional, ""),
+					field("page_token", str, optional, ""),
+				},
+				NestedType: []*descriptor.DescriptorProto{{
+					Name: proto.String("LabelsEntry"),
+					Field: []*descriptor.FieldDescriptorProto{
+						field("key", str, optional, ""),
+						field("value", str, optional, ""),
+					},
+					Options: &descriptor.MessageOptions{MapEntry: proto.Bool(true)},
+				}},
+				OneofDecl: []*descriptor.OneofDescriptorProto{{Name: proto.String("ref")}},
+			},
+			{Name: proto.String("OutputType")},
+			{
+				Name: proto.String("PageOutputType"),
+				Field: []*descriptor.FieldDescriptorProto{
+					field("things", msg, repeated, ".my.pkg.OutputType"),
+					field("next_page_token", str, optional, ""),
+				},
+			},
+		},
+		EnumType: []*descriptor.EnumDescriptorProto{{
+			Name:  proto.String("Kind"),
+			Value: []*descriptor.EnumValueDescriptorProto{{Name: proto.String("KIND_UNSPECIFIED"), Number: proto.Int32(0)}},
+		}},
+	}
+	// A proto2 message, whose scalar fields are pointers.
+	partFile := &descriptor.FileDescriptorProto{
+		Package: proto.String("my.pkg"),
+		Syntax:  proto.String("proto2"),
+		Options: &descriptor.FileOptions{GoPackage: proto.String("mypackage")},
+		MessageType: []*descriptor.DescriptorProto{{
+			Name: proto.String("Part"),
+			Field: []*descriptor.FieldDescriptorProto{
+				required(field("size", descriptor.FieldDescriptorProto_TYPE_UINT32, optional, "")),
+				required(field("parent", msg, optional, ".my.pkg.Part")),
+			},
+		}},
+	}
+	emptyFile := &descriptor.FileDescriptorProto{
+		Package:     proto.String("google.protobuf"),
+		Options:     &descriptor.FileOptions{GoPackage: proto.String("github.com/golang/protobuf/ptypes/empty;empty")},
+		MessageType: []*descriptor.DescriptorProto{{Name: proto.String("Empty")}},
+	}
+	lroFile := &descriptor.FileDescriptorProto{
+		Package:     proto.String("google.longrunning"),
+		Options:     &descriptor.FileOptions{GoPackage: proto.String("google.golang.org/genproto/googleapis/longrunning;longrunning")},
+		MessageType: []*descriptor.DescriptorProto{{Name: proto.String("Operation")}},
+	}
+
+	method := func(name, in, out string, clientStreaming, serverStreaming bool) *descriptor.MethodDescriptorProto {
+		return &descriptor.MethodDescriptorProto{
+			Name:            proto.String(name),
+			InputType:       proto.String(in),
+			OutputType:      proto.String(out),
+			ClientStreaming: proto.Bool(clientStreaming),
+			ServerStreaming: proto.Bool(serverStreaming),
+			Options:         &descriptor.MethodOptions{},
+		}
+	}
+	lro := func(name, resp string) *descriptor.MethodDescriptorProto {
+		m := method(name, ".my.pkg.InputType", ".google.longrunning.Operation", false, false)
+		if err := proto.SetExtension(m.Options, longrunning.E_OperationInfo, &longrunning.OperationInfo{ResponseType: resp}); err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+	serv := &descriptor.ServiceDescriptorProto{
+		Name: proto.String("Foo"),
+		Method: []*descriptor.MethodDescriptorProto{
+			method("GetThing", ".my.pkg.InputType", ".my.pkg.OutputType", false, false),
+			method("DeleteThing", ".my.pkg.InputType", ".google.protobuf.Empty", false, false),
+			method("ListThings", ".my.pkg.InputType", ".my.pkg.PageOutputType", false, false),
+			lro("MakeThing", "OutputType"),
+			lro("RemoveThing", "google.protobuf.Empty"),
+			method("ServerThings", ".my.pkg.InputType", ".my.pkg.OutputType", false, true),
+			method("ClientThings", ".my.pkg.InputType", ".my.pkg.OutputType", true, false),
+			method("BidiThings", ".my.pkg.InputType", ".my.pkg.OutputType", true, true),
+		},
+	}
+	file.Service = []*descriptor.ServiceDescriptorProto{serv}
+
+	var g generator
+	g.init([]*descriptor.FileDescriptorProto{file, partFile, emptyFile, lroFile})
+	if err := g.genTestFile(serv, "github.com/googleapis/foo/apiv1", "foo"); err != nil {
+		t.Fatal(err)
+	}
+	txtdiff.Diff(t, "test_file", g.pt.String(), filepath.Join("testdata", "test_file.want"))
+}
@@ -0,0 +1,348 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gengapic
+
+import (
+	"sort"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/googleapis/gapic-generator-go/internal/errors"
+	"github.com/googleapis/gapic-generator-go/internal/gensample"
+	"github.com/googleapis/gapic-generator-go/internal/pbinfo"
+)
+
+// customOp is a method configured as long-running that returns its own operation message,
+// instead of google.longrunning.Operation. See gensample.CustomOperationConfig.
+type customOp struct {
+	serv *descriptor.ServiceDescriptorProto
+	m    *descriptor.MethodDescriptorProto
+	conf *gensample.CustomOperationConfig
+
+	// The operation message returned by m and the polling method.
+	op *descriptor.DescriptorProto
+
+	// The method fetching the latest state of the operation.
+	pollingMethod *descriptor.MethodDescriptorProto
+
+	// The enum of the status field of op.
+	status *descriptor.EnumDescriptorProto
+}
+
+// collectCustomOps sets g.customOps to the custom operation methods of serv,
+// and records their "FooOperation" types in g.aux.
+func (g *generator) collectCustomOps(serv *descriptor.ServiceDescriptorProto) error {
+	g.customOps = map[*descriptor.MethodDescriptorProto]*customOp{}
+
+	for _, m := range serv.GetMethod() {
+		conf := g.customOpConfig(serv, m)
+		if conf == nil {
+			continue
+		}
+		op, err := g.customOpOf(serv, m, conf)
+		if err != nil {
+			return errors.E(err, "custom operation: %s", m.GetName())
+		}
+		if err := g.addCustomOp(op); err != nil {
+			return err
+		}
+		g.customOps[m] = op
+	}
+	return nil
+}
+
+// customOpOf validates conf, the custom operation config of m, against the descriptors.
+func (g *generator) customOpOf(serv *descriptor.ServiceDescriptorProto, m *descriptor.MethodDescriptorProto, conf *gensample.CustomOperationConfig) (*customOp, error) {
+	if m.GetClientStreaming() || m.GetServerStreaming() || m.GetOutputType() == lroType || m.GetOutputType() == emptyType {
+		return nil, errors.E(nil, "must be a unary method returning an operation message")
+	}
+
+	op, ok := g.descInfo.Type[m.GetOutputType()].(*descriptor.DescriptorProto)
+	if !ok {
+		return nil, errors.E(nil, "cannot find message type %q, malformed descriptor?", m.GetOutputType())
+	}
+	c := &customOp{serv: serv, m: m, conf: conf, op: op}
+
+	for _, pm := range serv.GetMethod() {
+		if pm.GetName() == conf.PollingMethod {
+			c.pollingMethod = pm
+		}
+	}
+	pm := c.pollingMethod
+	if pm == nil {
+		return nil, errors.E(nil, "no polling method %q in %s", conf.PollingMethod, serv.GetName())
+	}
+	if pm.GetClientStreaming() || pm.GetServerStreaming() || pm.GetOutputType() != m.GetOutputType() {
+		return nil, errors.E(nil, "polling method %s must be unary and return %s", pm.GetName(), m.GetOutputType())
+	}
+	if g.customOpConfig(serv, pm) != nil {
+		return nil, errors.E(nil, "polling method %s cannot be a custom operation itself", pm.GetName())
+	}
+
+	in, ok := g.descInfo.Type[m.GetInputType()].(*descriptor.DescriptorProto)
+	if !ok {
+		return nil, errors.E(nil, "cannot find message type %q, malformed descriptor?", m.GetInputType())
+	}
+	pollIn, ok := g.descInfo.Type[pm.GetInputType()].(*descriptor.DescriptorProto)
+	if !ok {
+		return nil, errors.E(nil, "cannot find message type %q, malformed descriptor?", pm.GetInputType())
+	}
+
+	type fieldCheck struct {
+		msg   *descriptor.DescriptorProto
+		name  string
+		typ   descriptor.FieldDescriptorProto_Type
+		label string
+	}
+	checks := []fieldCheck{
+		{op, conf.NameField, descriptor.FieldDescriptorProto_TYPE_STRING, "name_field"},
+		{op, conf.StatusField, descriptor.FieldDescriptorProto_TYPE_ENUM, "status_field"},
+		{pollIn, conf.PollingNameField, descriptor.FieldDescriptorProto_TYPE_STRING, "polling_name_field"},
+	}
+	if conf.ErrorCodeField != "" {
+		checks = append(checks, fieldCheck{op, conf.ErrorCodeField, descriptor.FieldDescriptorProto_TYPE_INT32, "error_code_field"})
+	}
+	if conf.ErrorMessageField != "" {
+		checks = append(checks, fieldCheck{op, conf.ErrorMessageField, descriptor.FieldDescriptorProto_TYPE_STRING, "error_message_field"})
+	}
+	for _, chk := range checks {
+		f := fieldByName(chk.msg, chk.name)
+		if f == nil || f.GetType() != chk.typ || f.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+			return nil, errors.E(nil, "%s: %s has no singular %s field %q", chk.label, chk.msg.GetName(), chk.typ, chk.name)
+		}
+	}
+
+	for _, name := range conf.PollingRequestFields {
+		f, pf := fieldByName(in, name), fieldByName(pollIn, name)
+		if f == nil || pf == nil || f.GetType() != pf.GetType() || f.GetTypeName() != pf.GetTypeName() || f.GetLabel() != pf.GetLabel() {
+			return nil, errors.E(nil, "polling_request_fields: %q must be a field of the same type in %s and %s", name, in.GetName(), pollIn.GetName())
+		}
+	}
+
+	status, ok := g.descInfo.Type[fieldByName(op, conf.StatusField).GetTypeName()].(*descriptor.EnumDescriptorProto)
+	if !ok {
+		return nil, errors.E(nil, "cannot find enum type of %s.%s, malformed descriptor?", op.GetName(), conf.StatusField)
+	}
+	found := false
+	for _, v := range status.GetValue() {
+		found = found || v.GetName() == conf.DoneValue
+	}
+	if !found {
+		return nil, errors.E(nil, "done_value: no value %q in enum %s", conf.DoneValue, status.GetName())
+	}
+	c.status = status
+
+	return c, nil
+}
+
+// addCustomOp records that the "FooOperation" type of op must be generated. It errors if
+// another service of the package has a custom operation of the same name that differs.
+func (g *generator) addCustomOp(op *customOp) error {
+	name := lroTypeName(op.m.GetName())
+	if _, ok := g.aux.lros[name]; ok {
+		return errors.E(nil, "rpc %s.%s needs type %s, which is already used by a google.longrunning.Operation method",
+			op.serv.GetName(), op.m.GetName(), name)
+	}
+
+	prev, ok := g.aux.customOps[name]
+	if !ok {
+		g.aux.customOps[name] = op
+		return nil
+	}
+	same := prev.op == op.op &&
+		prev.conf.NameField == op.conf.NameField &&
+		prev.conf.StatusField == op.conf.StatusField &&
+		prev.conf.DoneValue == op.conf.DoneValue &&
+		prev.conf.ErrorCodeField == op.conf.ErrorCodeField &&
+		prev.conf.ErrorMessageField == op.conf.ErrorMessageField
+	if !same {
+		return errors.E(nil, "rpcs %s.%s and %s.%s both need type %s, but have different custom operations",
+			prev.serv.GetName(), prev.m.GetName(), op.serv.GetName(), op.m.GetName(), name)
+	}
+	return nil
+}
+
+// customOpResult prints the statement returning the "FooOperation" wrapping resp,
+// the operation returned by the custom operation method m, from the client method.
+func (g *generator) customOpResult(m *descriptor.MethodDescriptorProto, resp string) {
+	g.printf("return c.new%s(req, %s), nil", lroTypeName(m.GetName()), resp)
+}
+
+// genCustomOpConstructors generates the methods of clientType, e.g. "FooClient",
+// that wrap the operations returned by the custom operation methods of the current service.
+func (g *generator) genCustomOpConstructors(clientType string) error {
+	var ops []*customOp
+	for _, op := range g.customOps {
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		return ops[i].m.GetName() < ops[j].m.GetName()
+	})
+
+	p := g.printf
+	for _, op := range ops {
+		inName, inSpec, err := g.descInfo.NameSpec(g.descInfo.Type[op.m.GetInputType()])
+		if err != nil {
+			return err
+		}
+		opName, opSpec, err := g.descInfo.NameSpec(op.op)
+		if err != nil {
+			return err
+		}
+		pollInName, pollInSpec, err := g.descInfo.NameSpec(g.descInfo.Type[op.pollingMethod.GetInputType()])
+		if err != nil {
+			return err
+		}
+		typeName := lroTypeName(op.m.GetName())
+
+		p("// new%[1]s returns the %[1]s of the operation resp, started with req.", typeName)
+		p("func (c *%s) new%s(req *%s.%s, resp *%s.%s) *%s {",
+			clientType, typeName, inSpec.Name, inName, opSpec.Name, opName, typeName)
+		p("  pollReq := &%s.%s{", pollInSpec.Name, pollInName)
+		p("    %s: resp.Get%s(),", snakeToCamel(op.conf.PollingNameField), snakeToCamel(op.conf.NameField))
+		for _, f := range op.conf.PollingRequestFields {
+			p("    %s: req.Get%[1]s(),", snakeToCamel(f))
+		}
+		p("  }")
+		p("  return &%s{", typeName)
+		p("    proto: resp,")
+		p("    poll: func(ctx context.Context, opts ...gax.CallOption) (*%s.%s, error) {", opSpec.Name, opName)
+		p("      return c.%s(ctx, pollReq, opts...)", op.pollingMethod.GetName())
+		p("    },")
+		g.pollSettingsField(op.serv, op.m)
+		p("  }")
+		p("}")
+		p("")
+
+		g.imports[inSpec] = true
+		g.imports[opSpec] = true
+		g.imports[pollInSpec] = true
+	}
+	return nil
+}
+
+// customOpType generates the "FooOperation" type of the custom operation op.
+func (g *generator) customOpType(op *customOp) error {
+	opName, opSpec, err := g.descInfo.NameSpec(op.op)
+	if err != nil {
+		return err
+	}
+	opType := opSpec.Name + "." + opName
+	typeName := lroTypeName(op.m.GetName())
+
+	// Values of nested enums are prefixed with the name of the parent message, not the enum.
+	var prefix string
+	if parent, ok := g.descInfo.ParentElement[op.status]; ok && parent != nil {
+		prefix, _, err = g.descInfo.NameSpec(parent)
+	} else {
+		prefix, _, err = g.descInfo.NameSpec(op.status)
+	}
+	if err != nil {
+		return err
+	}
+	doneValue := opSpec.Name + "." + prefix + "_" + op.conf.DoneValue
+	p := g.printf
+
+	p("// %s manages a long-running operation from %s.", typeName, op.m.GetName())
+	g.deprecationNotice(op.m, typeName)
+	p("type %s struct {", typeName)
+	p("  proto *%s", opType)
+	p("  poll func(context.Context, ...gax.CallOption) (*%s, error)", opType)
+	p("  pollSettings PollSettings")
+	p("}")
+	p("")
+
+	p("// Wait blocks until the long-running operation is completed, returning the final state")
+	p("// of the operation and any errors encountered.")
+	p("// It polls the operation with the PollSettings configured for the method that started it.")
+	p("//")
+	p("// See documentation of Poll for error-handling information.")
+	p("func (op *%s) Wait(ctx context.Context, opts ...gax.CallOption) (*%s, error) {", typeName, opType)
+	p("  return op.WaitWithSettings(ctx, op.pollSettings, opts...)")
+	p("}")
+	p("")
+
+	p("// WaitWithSettings is like Wait, but polls the operation according to settings.")
+	p("func (op *%s) WaitWithSettings(ctx context.Context, settings PollSettings, opts ...gax.CallOption) (*%s, error) {", typeName, opType)
+	p("  var resp *%s", opType)
+	p("  err := waitOperation(ctx, settings, func(ctx context.Context) error {")
+	p("    var err error")
+	p("    resp, err = op.Poll(ctx, opts...)")
+	p("    return err")
+	p("  }, op.Done)")
+	p("  if err != nil {")
+	p("    return nil, err")
+	p("  }")
+	p("  return resp, nil")
+	p("}")
+	p("")
+
+	p("// Poll fetches the latest state of the long-running operation.")
+	p("//")
+	p("// If Poll fails, the error is returned and op is unmodified. If Poll succeeds and")
+	p("// the operation has completed with failure, the error is returned and op.Done will return true.")
+	p("// If Poll succeeds and the operation has completed successfully,")
+	p("// op.Done will return true, and the final state of the operation is returned.")
+	p("// If Poll succeeds and the operation has not completed, the returned operation and error are both nil.")
+	p("func (op *%s) Poll(ctx context.Context, opts ...gax.CallOption) (*%s, error) {", typeName, opType)
+	p("  if !op.Done() {")
+	p("    resp, err := op.poll(ctx, opts...)")
+	p("    if err != nil {")
+	p("      return nil, err")
+	p("    }")
+	p("    op.proto = resp")
+	p("  }")
+	p("  if !op.Done() {")
+	p("    return nil, nil")
+	p("  }")
+	switch code, msg := op.conf.ErrorCodeField, op.conf.ErrorMessageField; {
+	case code != "" && msg != "":
+		p("  if c := op.proto.Get%s(); c != 0 {", snakeToCamel(code))
+		p("    return nil, status.Error(codes.Code(c), op.proto.Get%s())", snakeToCamel(msg))
+		p("  }")
+	case code != "":
+		p("  if c := op.proto.Get%s(); c != 0 {", snakeToCamel(code))
+		p("    return nil, status.Errorf(codes.Code(c), %q, op.Name())", "operation %s failed")
+		p("  }")
+	case msg != "":
+		p("  if msg := op.proto.Get%s(); msg != \"\" {", snakeToCamel(msg))
+		p("    return nil, status.Error(codes.Unknown, msg)")
+		p("  }")
+	}
+	if op.conf.ErrorCodeField != "" || op.conf.ErrorMessageField != "" {
+		g.imports[pbinfo.ImportSpec{Path: "google.golang.org/grpc/codes"}] = true
+		g.imports[pbinfo.ImportSpec{Path: "google.golang.org/grpc/status"}] = true
+	}
+	p("  return op.proto, nil")
+	p("}")
+	p("")
+
+	p("// Done reports whether the long-running operation has completed.")
+	p("func (op *%s) Done() bool {", typeName)
+	p("  return op.proto.Get%s() == %s", snakeToCamel(op.conf.StatusField), doneValue)
+	p("}")
+	p("")
+
+	p("// Name returns the name of the long-running operation.")
+	p("// The name is assigned by the server and is unique within the service from which the operation is created.")
+	p("func (op *%s) Name() string {", typeName)
+	p("  return op.proto.Get%s()", snakeToCamel(op.conf.NameField))
+	p("}")
+	p("")
+
+	g.imports[opSpec] = true
+	g.imports[pbinfo.ImportSpec{Path: "context"}] = true
+	g.imports[pbinfo.ImportSpec{Name: "gax", Path: "github.com/googleapis/gax-go/v2"}] = true
+	return nil
+}
@@ -0,0 +1,175 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gengapic
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/googleapis/gapic-generator-go/internal/gensample"
+	"github.com/googleapis/gapic-generator-go/internal/pbinfo"
+	"github.com/googleapis/gapic-generator-go/internal/txtdiff"
+)
+
+func TestCustomOperation(t *testing.T) {
+	typep := func(t descriptor.FieldDescriptorProto_Type) *descriptor.FieldDescriptorProto_Type {
+		return &t
+	}
+	field := func(name string, typ descriptor.FieldDescriptorProto_Type, typeName string) *descriptor.FieldDescriptorProto {
+		f := &descriptor.FieldDescriptorProto{
+			Name:  proto.String(name),
+			Type:  typep(typ),
+			Label: descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+
+	status := &descriptor.EnumDescriptorProto{
+		Name: proto.String("Status"),
+		Value: []*descriptor.EnumValueDescriptorProto{
+			{Name: proto.String("PENDING"), Number: proto.Int32(0)},
+			{Name: proto.String("DONE"), Number: proto.Int32(1)},
+		},
+	}
+	operation := &descriptor.DescriptorProto{
+		Name: proto.String("Operation"),
+		Field: []*descriptor.FieldDescriptorProto{
+			field("name", descriptor.FieldDescriptorProto_TYPE_STRING, ""),
+			field("status", descriptor.FieldDescriptorProto_TYPE_ENUM, ".my.pkg.Operation.Status"),
+			field("error_code", descriptor.FieldDescriptorProto_TYPE_INT32, ""),
+			field("error_message", descriptor.FieldDescriptorProto_TYPE_STRING, ""),
+		},
+		EnumType: []*descriptor.EnumDescriptorProto{status},
+	}
+	insertRequest := &descriptor.DescriptorProto{
+		Name: proto.String("InsertRequest"),
+		Field: []*descriptor.FieldDescriptorProto{
+			field("project", descriptor.FieldDescriptorProto_TYPE_STRING, ""),
+		},
+	}
+	getOperationRequest := &descriptor.DescriptorProto{
+		Name: proto.String("GetOperationRequest"),
+		Field: []*descriptor.FieldDescriptorProto{
+			field("project", descriptor.FieldDescriptorProto_TYPE_STRING, ""),
+			field("operation", descriptor.FieldDescriptorProto_TYPE_STRING, ""),
+		},
+	}
+
+	file := &descriptor.FileDescriptorProto{
+		Package: proto.String("my.pkg"),
+		Options: &descriptor.FileOptions{
+			GoPackage: proto.String("mypackage"),
+		},
+	}
+	insert := &descriptor.MethodDescriptorProto{
+		Name:       proto.String("Insert"),
+		InputType:  proto.String(".my.pkg.InsertRequest"),
+		OutputType: proto.String(".my.pkg.Operation"),
+	}
+	getOperation := &descriptor.MethodDescriptorProto{
+		Name:       proto.String("GetOperation"),
+		InputType:  proto.String(".my.pkg.GetOperationRequest"),
+		OutputType: proto.String(".my.pkg.Operation"),
+	}
+	serv := &descriptor.ServiceDescriptorProto{
+		Name:   proto.String("Foo"),
+		Method: []*descriptor.MethodDescriptorProto{insert, getOperation},
+	}
+
+	var g generator
+	g.imports = map[pbinfo.ImportSpec]bool{}
+
+	commonTypes(&g)
+	for _, typ := range []*descriptor.DescriptorProto{operation, insertRequest, getOperationRequest} {
+		g.descInfo.Type[".my.pkg."+typ.GetName()] = typ
+		g.descInfo.ParentFile[typ] = file
+	}
+	g.descInfo.Type[".my.pkg.Operation.Status"] = status
+	g.descInfo.ParentElement = map[pbinfo.ProtoType]pbinfo.ProtoType{
+		status: operation,
+	}
+	g.descInfo.ParentFile[serv] = file
+
+	conf := func(op gensample.CustomOperationConfig) *gensample.GAPICConfig {
+		return &gensample.GAPICConfig{
+			Interfaces: []gensample.GAPICInterface{{
+				Name: "my.pkg.Foo",
+				Methods: []gensample.GAPICMethod{{
+					Name:            "Insert",
+					CustomOperation: &op,
+				}},
+			}},
+		}
+	}
+
+	for _, tst := range []struct {
+		name string
+		conf gensample.CustomOperationConfig
+	}{
+		{
+			name: "missing polling method",
+			conf: gensample.CustomOperationConfig{PollingMethod: "Missing"},
+		},
+		{
+			name: "unknown done value",
+			conf: gensample.CustomOperationConfig{PollingMethod: "GetOperation", PollingNameField: "operation", DoneValue: "FINISHED"},
+		},
+		{
+			name: "polling name field",
+			conf: gensample.CustomOperationConfig{PollingMethod: "GetOperation"},
+		},
+		{
+			name: "polling request field",
+			conf: gensample.CustomOperationConfig{PollingMethod: "GetOperation", PollingNameField: "operation", PollingRequestFields: []string{"zone"}},
+		},
+		{
+			name: "error code field",
+			conf: gensample.CustomOperationConfig{PollingMethod: "GetOperation", PollingNameField: "operation", ErrorCodeField: "error_message"},
+		},
+	} {
+		g.gapicConf = conf(tst.conf)
+		g.aux = newAuxTypes()
+		if err := g.collectCustomOps(serv); err == nil {
+			t.Errorf("%s: collectCustomOps() = nil error, want error", tst.name)
+		}
+	}
+
+	g.gapicConf = conf(gensample.CustomOperationConfig{
+		PollingMethod:        "GetOperation",
+		PollingNameField:     "operation",
+		PollingRequestFields: []string{"project"},
+		ErrorCodeField:       "error_code",
+		ErrorMessageField:    "error_message",
+	})
+	g.aux = newAuxTypes()
+	if err := g.collectCustomOps(serv); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.genMethod("Foo", serv, insert); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.genCustomOpConstructors("FooClient"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.customOpType(g.aux.customOps["InsertOperation"]); err != nil {
+		t.Fatal(err)
+	}
+	txtdiff.Diff(t, "custom_operation", g.pt.String(), filepath.Join("testdata", "custom_operation.want"))
+}
@@ -0,0 +1,57 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gengapic
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// deprecated reports whether e, a service, method, message, enum or field, is marked deprecated.
+func deprecated(e proto.Message) bool {
+	switch e := e.(type) {
+	case *descriptor.ServiceDescriptorProto:
+		return e.GetOptions().GetDeprecated()
+	case *descriptor.MethodDescriptorProto:
+		return e.GetOptions().GetDeprecated()
+	case *descriptor.DescriptorProto:
+		return e.GetOptions().GetDeprecated()
+	case *descriptor.EnumDescriptorProto:
+		return e.GetOptions().GetDeprecated()
+	case *descriptor.FieldDescriptorProto:
+		return e.GetOptions().GetDeprecated()
+	}
+	return false
+}
+
+// deprecationNotice generates the "Deprecated:" paragraph ending the doc comment of name,
+// a Go element generated for the proto element e, if e is deprecated.
+func (g *generator) deprecationNotice(e proto.Message, name string) {
+	if !deprecated(e) {
+		return
+	}
+	g.printf("//")
+	g.deprecationParagraph(g.deprecations[e], name)
+}
+
+// deprecationParagraph generates the "Deprecated:" paragraph of name, with the deprecation
+// description of the service config, or a default one if desc is empty.
+func (g *generator) deprecationParagraph(desc, name string) {
+	if desc == "" {
+		g.printf("// Deprecated: %s may be removed in a future version.", name)
+		return
+	}
+	g.comment("Deprecated: " + desc)
+}
@@ -42,7 +42,7 @@ func (g *generator) genDocFile(pkgPath, pkgName string, year int, scopes []strin
 
 	// TODO(ndietz) figure out how to include this without the service config
 	if g.serviceConfig != nil && g.serviceConfig.Documentation != nil {
-		wrapped := wrapString(g.serviceConfig.Documentation.Summary, 75)
+		wrapped := wrapString(g.serviceConfig.GetDocumentation().GetSummary(), 75)
 
 		if len(wrapped) > 0 && g.apiName != "" {
 			p("//")
@@ -155,9 +155,21 @@ func (g *generator) genDocFile(pkgPath, pkgName string, year int, scopes []strin
 	}
 }
 
-func collectScopes(servs []*descriptor.ServiceDescriptorProto, config *serviceConfig) ([]string, error) {
+// collectScopes reports the OAuth scopes needed by the clients of servs: those of their
+// google.api.oauth_scopes annotations, and those the authentication rules of the service config
+// require for their methods.
+func (g *generator) collectScopes(servs []*descriptor.ServiceDescriptorProto) ([]string, error) {
 	scopeSet := map[string]bool{}
 	for _, s := range servs {
+		for _, m := range g.clientMethods(s) {
+			for _, sc := range g.authScopes(g.methodName(s, m)) {
+				scopeSet[sc] = true
+			}
+		}
+
+		if s.GetOptions() == nil {
+			continue
+		}
 		eOauthScopes, err := proto.GetExtension(s.Options, annotations.E_OauthScopes)
 		if err == proto.ErrMissingExtension {
 			continue
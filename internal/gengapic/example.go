@@ -15,6 +15,7 @@
 package gengapic
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/golang/protobuf/proto"
@@ -25,6 +26,10 @@ import (
 )
 
 func (g *generator) genExampleFile(serv *descriptor.ServiceDescriptorProto, pkgName string) error {
+	// Examples must not encourage the use of deprecated clients, so they have none.
+	if deprecated(serv) {
+		return nil
+	}
 	servName := pbinfo.ReduceServName(*serv.Name, pkgName)
 	p := g.printf
 
@@ -36,10 +41,24 @@ func (g *generator) genExampleFile(serv *descriptor.ServiceDescriptorProto, pkgN
 	p("")
 	g.imports[pbinfo.ImportSpec{Path: "context"}] = true
 
-	for _, m := range serv.Method {
+	for _, m := range g.clientMethods(serv) {
+		// Examples must not encourage the use of deprecated methods.
+		if deprecated(m) {
+			continue
+		}
 		if err := g.exampleMethod(pkgName, servName, m); err != nil {
 			return err
 		}
+
+		flats, err := g.flattenings(serv, m)
+		if err != nil {
+			return err
+		}
+		for _, fl := range flats {
+			if err := g.exampleFlattenedMethod(pkgName, servName, m, fl); err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
@@ -102,16 +121,66 @@ func (g *generator) exampleMethod(pkgName, servName string, m *descriptor.Method
 		p("}")
 	}
 
+	call := fmt.Sprintf("c.%s(ctx, req)", m.GetName())
 	if pf != nil {
-		g.examplePagingCall(m)
-	} else if *m.OutputType == lroType {
-		g.exampleLROCall(m)
+		g.examplePagingCall(call)
+	} else if g.isLRO(m) || g.customOps[m] != nil {
+		g.exampleLROCall(m, call)
 	} else if *m.OutputType == emptyType {
-		g.exampleEmptyCall(m)
+		g.exampleEmptyCall(call)
 	} else if m.GetClientStreaming() && m.GetServerStreaming() {
 		g.exampleBidiCall(m, inType, inSpec)
 	} else {
-		g.exampleUnaryCall(m)
+		g.exampleUnaryCall(call)
+	}
+
+	p("}")
+	p("")
+	return nil
+}
+
+// exampleFlattenedMethod generates an example of the flattened method fl of m.
+func (g *generator) exampleFlattenedMethod(pkgName, servName string, m *descriptor.MethodDescriptorProto, fl flattening) error {
+	// Server streaming examples are not implemented, see exampleMethod.
+	if m.GetServerStreaming() {
+		return nil
+	}
+
+	p := g.printf
+
+	pf, err := g.pagingField(m)
+	if err != nil {
+		return err
+	}
+
+	p("func Example%sClient_%s() {", servName, fl.name)
+	if pf != nil {
+		p("// import \"google.golang.org/api/iterator\"")
+		p("")
+	}
+
+	g.exampleInitClient(pkgName, servName)
+	p("")
+
+	args := []string{"ctx"}
+	for _, ff := range fl.fields {
+		typ, err := g.fieldGoType(ff.field())
+		if err != nil {
+			return err
+		}
+		p("var %s %s // TODO: Set %s.", paramName(ff), typ, paramName(ff))
+		args = append(args, paramName(ff))
+	}
+
+	call := fmt.Sprintf("c.%s(%s)", fl.name, strings.Join(args, ", "))
+	if pf != nil {
+		g.examplePagingCall(call)
+	} else if g.isLRO(m) || g.customOps[m] != nil {
+		g.exampleLROCall(m, call)
+	} else if *m.OutputType == emptyType {
+		g.exampleEmptyCall(call)
+	} else {
+		g.exampleUnaryCall(call)
 	}
 
 	p("}")
@@ -119,7 +188,7 @@ func (g *generator) exampleMethod(pkgName, servName string, m *descriptor.Method
 	return nil
 }
 
-func (g *generator) exampleLROCall(m *descriptor.MethodDescriptorProto) {
+func (g *generator) exampleLROCall(m *descriptor.MethodDescriptorProto, call string) {
 	p := g.printf
 	retVars := "resp, err :="
 
@@ -134,7 +203,7 @@ func (g *generator) exampleLROCall(m *descriptor.MethodDescriptorProto) {
 		}
 	}
 
-	p("op, err := c.%s(ctx, req)", *m.Name)
+	p("op, err := %s", call)
 	p("if err != nil {")
 	p("  // TODO: Handle error.")
 	p("}")
@@ -151,10 +220,10 @@ func (g *generator) exampleLROCall(m *descriptor.MethodDescriptorProto) {
 	}
 }
 
-func (g *generator) exampleUnaryCall(m *descriptor.MethodDescriptorProto) {
+func (g *generator) exampleUnaryCall(call string) {
 	p := g.printf
 
-	p("resp, err := c.%s(ctx, req)", *m.Name)
+	p("resp, err := %s", call)
 	p("if err != nil {")
 	p("  // TODO: Handle error.")
 	p("}")
@@ -162,19 +231,19 @@ func (g *generator) exampleUnaryCall(m *descriptor.MethodDescriptorProto) {
 	p("_ = resp")
 }
 
-func (g *generator) exampleEmptyCall(m *descriptor.MethodDescriptorProto) {
+func (g *generator) exampleEmptyCall(call string) {
 	p := g.printf
 
-	p("err = c.%s(ctx, req)", *m.Name)
+	p("err = %s", call)
 	p("if err != nil {")
 	p("  // TODO: Handle error.")
 	p("}")
 }
 
-func (g *generator) examplePagingCall(m *descriptor.MethodDescriptorProto) {
+func (g *generator) examplePagingCall(call string) {
 	p := g.printf
 
-	p("it := c.%s(ctx, req)", *m.Name)
+	p("it := %s", call)
 	p("for {")
 	p("  resp, err := it.Next()")
 	p("  if err == iterator.Done {")
@@ -22,6 +22,7 @@ import (
 	"github.com/golang/protobuf/protoc-gen-go/descriptor"
 	"github.com/googleapis/gapic-generator-go/internal/pbinfo"
 	"github.com/googleapis/gapic-generator-go/internal/txtdiff"
+	"google.golang.org/genproto/googleapis/api/annotations"
 	"google.golang.org/genproto/googleapis/longrunning"
 )
 
@@ -29,13 +30,6 @@ func TestExample(t *testing.T) {
 	var g generator
 	g.imports = map[pbinfo.ImportSpec]bool{}
 
-	inputType := &descriptor.DescriptorProto{
-		Name: proto.String("InputType"),
-	}
-	outputType := &descriptor.DescriptorProto{
-		Name: proto.String("OutputType"),
-	}
-
 	typep := func(t descriptor.FieldDescriptorProto_Type) *descriptor.FieldDescriptorProto_Type {
 		return &t
 	}
@@ -43,6 +37,20 @@ func TestExample(t *testing.T) {
 		return &l
 	}
 
+	inputType := &descriptor.DescriptorProto{
+		Name: proto.String("InputType"),
+		Field: []*descriptor.FieldDescriptorProto{
+			{
+				Name:  proto.String("name"),
+				Type:  typep(descriptor.FieldDescriptorProto_TYPE_STRING),
+				Label: labelp(descriptor.FieldDescriptorProto_LABEL_OPTIONAL),
+			},
+		},
+	}
+	outputType := &descriptor.DescriptorProto{
+		Name: proto.String("OutputType"),
+	}
+
 	pageInputType := &descriptor.DescriptorProto{
 		Name: proto.String("PageInputType"),
 		Field: []*descriptor.FieldDescriptorProto{
@@ -91,6 +99,10 @@ func TestExample(t *testing.T) {
 	}
 	respLROOpts := &descriptor.MethodOptions{}
 	proto.SetExtension(respLROOpts, longrunning.E_OperationInfo, respLRO)
+	proto.SetExtension(respLROOpts, annotations.E_MethodSignature, []string{"name"})
+
+	sigOpts := &descriptor.MethodOptions{}
+	proto.SetExtension(sigOpts, annotations.E_MethodSignature, []string{"name"})
 
 	commonTypes(&g)
 	for _, typ := range []*descriptor.DescriptorProto{
@@ -112,6 +124,7 @@ func TestExample(t *testing.T) {
 				Name:       proto.String("GetOneThing"),
 				InputType:  proto.String(".my.pkg.InputType"),
 				OutputType: proto.String(".my.pkg.OutputType"),
+				Options:    sigOpts,
 			},
 			{
 				Name:       proto.String("GetBigThing"),
@@ -142,6 +155,13 @@ func TestExample(t *testing.T) {
 				OutputType: proto.String(".google.longrunning.Operation"),
 				Options:    respLROOpts,
 			},
+			// Deprecated methods have no examples.
+			{
+				Name:       proto.String("GetOldThing"),
+				InputType:  proto.String(".my.pkg.InputType"),
+				OutputType: proto.String(".my.pkg.OutputType"),
+				Options:    &descriptor.MethodOptions{Deprecated: proto.Bool(true)},
+			},
 		},
 	}
 	for _, tst := range []struct {
@@ -151,9 +171,21 @@ func TestExample(t *testing.T) {
 		{tstName: "foo_example", pkgName: "Bar"},
 	} {
 		g.reset()
-		g.genExampleFile(serv, tst.pkgName)
+		if err := g.genExampleFile(serv, tst.pkgName); err != nil {
+			t.Fatal(err)
+		}
 		txtdiff.Diff(t, tst.tstName, g.pt.String(), filepath.Join("testdata", tst.tstName+".want"))
 	}
+
+	// Deprecated services have no examples, not even of their constructor.
+	serv.Options = &descriptor.ServiceOptions{Deprecated: proto.Bool(true)}
+	g.reset()
+	if err := g.genExampleFile(serv, "Foo"); err != nil {
+		t.Fatal(err)
+	}
+	if got := g.pt.String(); got != "" {
+		t.Errorf("genExampleFile(deprecated service) = %q, want no examples", got)
+	}
 }
 
 func commonTypes(g *generator) {
@@ -0,0 +1,455 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gengapic
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/googleapis/gapic-generator-go/internal/pbinfo"
+)
+
+// fakePkgName reports the name of the package of the fakes of the clients of the package pkgName.
+func fakePkgName(pkgName string) string {
+	return pkgName + "test"
+}
+
+// genFakeFile generates the fake server of serv, and the helper creating a client connected to it,
+// into the fake package of the client package with the import path pkgPath and name pkgName.
+func (g *generator) genFakeFile(serv *descriptor.ServiceDescriptorProto, pkgPath, pkgName string) error {
+	servName := pbinfo.ReduceServName(serv.GetName(), pkgName)
+	servSpec, err := g.descInfo.ImportSpec(serv)
+	if err != nil {
+		return err
+	}
+	fakeType := servName + "Server"
+	p := g.printf
+
+	p("// %s is a fake implementation of the %s service, for testing code that uses %sClient.",
+		fakeType, serv.GetName(), servName)
+	p("// It records the requests it receives, and returns the responses and errors queued")
+	p("// for its methods with AddResponse, AddError, AddLROResponse and AddLROError, which")
+	p("// take the name of the method.")
+	p("// The zero value is ready to use.")
+	p("type %s struct {", fakeType)
+	p("  Fake")
+	p("  %s.Unimplemented%sServer", servSpec.Name, serv.GetName())
+	p("}")
+	p("")
+
+	for _, m := range serv.GetMethod() {
+		if err := g.fakeMethod(serv, fakeType, m); err != nil {
+			return err
+		}
+	}
+
+	lroService := g.descInfo.ParentFile[serv].GetPackage() == "google.longrunning" && serv.GetName() == "Operations"
+
+	p("// New%sClient starts srv on an in-memory gRPC server, and returns a client connected to it,", servName)
+	p("// created with opts. Calling stop closes the client and stops the server.")
+	if !lroService {
+		p("// The server also runs a fake Operations service, reporting the long-running operations")
+		p("// returned by srv.")
+	}
+	p("func New%[1]sClient(ctx context.Context, srv *%[2]s, opts ...option.ClientOption) (client *%[3]s.%[1]sClient, stop func(), err error) {",
+		servName, fakeType, pkgName)
+	p("  conn, stopServer, err := serve(ctx, func(s *grpc.Server) {")
+	p("    %s.Register%sServer(s, srv)", servSpec.Name, serv.GetName())
+	if !lroService {
+		p("    longrunningpb.RegisterOperationsServer(s, &operations{fake: &srv.Fake})")
+	}
+	p("  })")
+	p("  if err != nil {")
+	p("    return nil, nil, err")
+	p("  }")
+	p("  c, err := %s.New%sClient(ctx, append([]option.ClientOption{option.WithGRPCConn(conn)}, opts...)...)", pkgName, servName)
+	p("  if err != nil {")
+	p("    stopServer()")
+	p("    return nil, nil, err")
+	p("  }")
+	p("  return c, func() {")
+	p("    c.Close()")
+	p("    stopServer()")
+	p("  }, nil")
+	p("}")
+	p("")
+
+	g.imports[servSpec] = true
+	g.imports[pbinfo.ImportSpec{Name: pkgName, Path: pkgPath}] = true
+	g.imports[pbinfo.ImportSpec{Path: "context"}] = true
+	g.imports[pbinfo.ImportSpec{Path: "google.golang.org/api/option"}] = true
+	g.imports[pbinfo.ImportSpec{Path: "google.golang.org/grpc"}] = true
+	if !lroService {
+		g.imports[pbinfo.ImportSpec{Name: "longrunningpb", Path: "google.golang.org/genproto/googleapis/longrunning"}] = true
+	}
+	return nil
+}
+
+// fakeMethod generates the method of the fake server type fakeType implementing the method m of serv.
+func (g *generator) fakeMethod(serv *descriptor.ServiceDescriptorProto, fakeType string, m *descriptor.MethodDescriptorProto) error {
+	inType := g.descInfo.Type[m.GetInputType()]
+	inName, inSpec, err := g.descInfo.NameSpec(inType)
+	if err != nil {
+		return err
+	}
+	outType := g.descInfo.Type[m.GetOutputType()]
+	outName, outSpec, err := g.descInfo.NameSpec(outType)
+	if err != nil {
+		return err
+	}
+	servSpec, err := g.descInfo.ImportSpec(serv)
+	if err != nil {
+		return err
+	}
+	in := fmt.Sprintf("%s.%s", inSpec.Name, inName)
+	out := fmt.Sprintf("%s.%s", outSpec.Name, outName)
+	stream := fmt.Sprintf("%s.%s_%sServer", servSpec.Name, serv.GetName(), m.GetName())
+	name := m.GetName()
+
+	p := g.printf
+	switch {
+	case m.GetClientStreaming() && m.GetServerStreaming():
+		p("// %s records each request received, and sends the next result queued for %s in reply,", name, name)
+		p("// until the client closes the stream or the result is an error.")
+		p("func (s *%s) %s(stream %s) error {", fakeType, name, stream)
+		p("  for {")
+		p("    req, err := stream.Recv()")
+		p("    if err == io.EOF {")
+		p("      return nil")
+		p("    }")
+		p("    if err != nil {")
+		p("      return err")
+		p("    }")
+		p("    resp, err := s.call(%q, req)", name)
+		p("    if err != nil {")
+		p("      return err")
+		p("    }")
+		p("    r, ok := resp.(*%s)", out)
+		p("    if !ok {")
+		p("      return wrongType(%q, resp)", name)
+		p("    }")
+		p("    if err := stream.Send(r); err != nil {")
+		p("      return err")
+		p("    }")
+		p("  }")
+		p("}")
+		g.imports[pbinfo.ImportSpec{Path: "io"}] = true
+
+	case m.GetClientStreaming():
+		p("// %s records the requests received until the client closes the stream,", name)
+		p("// and returns the next result queued for %s.", name)
+		p("func (s *%s) %s(stream %s) error {", fakeType, name, stream)
+		p("  for {")
+		p("    req, err := stream.Recv()")
+		p("    if err == io.EOF {")
+		p("      break")
+		p("    }")
+		p("    if err != nil {")
+		p("      return err")
+		p("    }")
+		p("    s.record(%q, req)", name)
+		p("  }")
+		p("  resp, err := s.call(%q, nil)", name)
+		p("  if err != nil {")
+		p("    return err")
+		p("  }")
+		p("  r, ok := resp.(*%s)", out)
+		p("  if !ok {")
+		p("    return wrongType(%q, resp)", name)
+		p("  }")
+		p("  return stream.SendAndClose(r)")
+		p("}")
+		g.imports[pbinfo.ImportSpec{Path: "io"}] = true
+
+	case m.GetServerStreaming():
+		p("// %s records req, and sends the responses queued for %s, until an error,", name, name)
+		p("// which is returned.")
+		p("func (s *%s) %s(req *%s, stream %s) error {", fakeType, name, in, stream)
+		p("  s.record(%q, req)", name)
+		p("  for s.queued(%q) {", name)
+		p("    resp, err := s.call(%q, nil)", name)
+		p("    if err != nil {")
+		p("      return err")
+		p("    }")
+		p("    r, ok := resp.(*%s)", out)
+		p("    if !ok {")
+		p("      return wrongType(%q, resp)", name)
+		p("    }")
+		p("    if err := stream.Send(r); err != nil {")
+		p("      return err")
+		p("    }")
+		p("  }")
+		p("  return nil")
+		p("}")
+
+	default:
+		p("// %s records req, and returns the next result queued for %s.", name, name)
+		p("func (s *%s) %s(ctx context.Context, req *%s) (*%s, error) {", fakeType, name, in, out)
+		p("  resp, err := s.call(%q, req)", name)
+		p("  if err != nil {")
+		p("    return nil, err")
+		p("  }")
+		p("  r, ok := resp.(*%s)", out)
+		p("  if !ok {")
+		p("    return nil, wrongType(%q, resp)", name)
+		p("  }")
+		p("  return r, nil")
+		p("}")
+		g.imports[pbinfo.ImportSpec{Path: "context"}] = true
+	}
+	p("")
+
+	if !m.GetClientStreaming() {
+		g.imports[inSpec] = true
+	}
+	g.imports[outSpec] = true
+	return nil
+}
+
+// genFakeSupport generates the types and helpers shared by the fake servers of a package:
+// the Fake type they embed, the fake Operations service and the in-memory server.
+func (g *generator) genFakeSupport() {
+	p := g.printf
+
+	p("// Fake holds the requests received, and the results queued, for the methods of a fake server.")
+	p("// Its methods are safe for concurrent use.")
+	p("type Fake struct {")
+	p("  mu      sync.Mutex")
+	p("  reqs    map[string][]proto.Message")
+	p("  results map[string][]fakeResult")
+	p("")
+	p("  // The long-running operations returned by the methods, by name.")
+	p("  ops     map[string]*longrunningpb.Operation")
+	p("  opCount int")
+	p("}")
+	p("")
+	p("// fakeResult is the result of a call of a method: a response, or an error.")
+	p("type fakeResult struct {")
+	p("  resp proto.Message")
+	p("  err  error")
+	p("}")
+	p("")
+	p("// AddResponse queues resp as the next result of method. A call of a unary or client streaming")
+	p("// method returns the next result, a bidi streaming method replies to each request with the next")
+	p("// result, and a server streaming method sends the results queued, up to an error.")
+	p("func (f *Fake) AddResponse(method string, resp proto.Message) {")
+	p("  f.add(method, fakeResult{resp: resp})")
+	p("}")
+	p("")
+	p("// AddError queues err as the next result of method.")
+	p("func (f *Fake) AddError(method string, err error) {")
+	p("  f.add(method, fakeResult{err: err})")
+	p("}")
+	p("")
+	p("// AddLROResponse queues a done long-running operation with the response resp as the next result")
+	p("// of method.")
+	p("func (f *Fake) AddLROResponse(method string, resp proto.Message) error {")
+	p("  any, err := ptypes.MarshalAny(resp)")
+	p("  if err != nil {")
+	p("    return err")
+	p("  }")
+	p("  f.AddResponse(method, &longrunningpb.Operation{")
+	p("    Done:   true,")
+	p("    Result: &longrunningpb.Operation_Response{Response: any},")
+	p("  })")
+	p("  return nil")
+	p("}")
+	p("")
+	p("// AddLROError queues a done long-running operation that failed with err as the next result")
+	p("// of method.")
+	p("func (f *Fake) AddLROError(method string, err error) {")
+	p("  f.AddResponse(method, &longrunningpb.Operation{")
+	p("    Done:   true,")
+	p("    Result: &longrunningpb.Operation_Error{Error: status.Convert(err).Proto()},")
+	p("  })")
+	p("}")
+	p("")
+	p("// Requests reports the requests received by method, in order.")
+	p("func (f *Fake) Requests(method string) []proto.Message {")
+	p("  f.mu.Lock()")
+	p("  defer f.mu.Unlock()")
+	p("  return append([]proto.Message(nil), f.reqs[method]...)")
+	p("}")
+	p("")
+	p("// Reset forgets the requests received, the results queued and the operations returned.")
+	p("func (f *Fake) Reset() {")
+	p("  f.mu.Lock()")
+	p("  defer f.mu.Unlock()")
+	p("  f.reqs = nil")
+	p("  f.results = nil")
+	p("  f.ops = nil")
+	p("}")
+	p("")
+	p("func (f *Fake) add(method string, r fakeResult) {")
+	p("  f.mu.Lock()")
+	p("  defer f.mu.Unlock()")
+	p("  if f.results == nil {")
+	p("    f.results = map[string][]fakeResult{}")
+	p("  }")
+	p("  f.results[method] = append(f.results[method], r)")
+	p("}")
+	p("")
+	p("// record records req as a request received by method.")
+	p("func (f *Fake) record(method string, req proto.Message) {")
+	p("  f.mu.Lock()")
+	p("  defer f.mu.Unlock()")
+	p("  if f.reqs == nil {")
+	p("    f.reqs = map[string][]proto.Message{}")
+	p("  }")
+	p("  f.reqs[method] = append(f.reqs[method], req)")
+	p("}")
+	p("")
+	p("// queued reports whether a result is queued for method.")
+	p("func (f *Fake) queued(method string) bool {")
+	p("  f.mu.Lock()")
+	p("  defer f.mu.Unlock()")
+	p("  return len(f.results[method]) > 0")
+	p("}")
+	p("")
+	p("// call records req, unless nil, and pops the next result queued for method.")
+	p("// The long-running operations returned are copies of those queued, named if they are not,")
+	p("// and kept for the fake Operations service to report.")
+	p("func (f *Fake) call(method string, req proto.Message) (proto.Message, error) {")
+	p("  if req != nil {")
+	p("    f.record(method, req)")
+	p("  }")
+	p("")
+	p("  f.mu.Lock()")
+	p("  defer f.mu.Unlock()")
+	p("  rs := f.results[method]")
+	p("  if len(rs) == 0 {")
+	p("    return nil, status.Errorf(codes.Unimplemented, \"fake: no result queued for %%s\", method)")
+	p("  }")
+	p("  f.results[method] = rs[1:]")
+	p("  r := rs[0]")
+	p("  if op, ok := r.resp.(*longrunningpb.Operation); ok && op != nil {")
+	p("    return f.keep(method, op), r.err")
+	p("  }")
+	p("  return r.resp, r.err")
+	p("}")
+	p("")
+	p("// done returns a done long-running operation of method with the response resp, kept for the")
+	p("// fake Operations service to report.")
+	p("func (f *Fake) done(method string, resp proto.Message) (*longrunningpb.Operation, error) {")
+	p("  any, err := ptypes.MarshalAny(resp)")
+	p("  if err != nil {")
+	p("    return nil, status.Errorf(codes.Internal, \"fake: %%v\", err)")
+	p("  }")
+	p("  op := &longrunningpb.Operation{")
+	p("    Done:   true,")
+	p("    Result: &longrunningpb.Operation_Response{Response: any},")
+	p("  }")
+	p("")
+	p("  f.mu.Lock()")
+	p("  defer f.mu.Unlock()")
+	p("  return f.keep(method, op), nil")
+	p("}")
+	p("")
+	p("// keep keeps a copy of op, named if it is not, for the fake Operations service to report,")
+	p("// and returns another copy to respond with. The fake Operations service changes the copy it keeps,")
+	p("// while gRPC marshals the response, and callers may reuse op, so none of them are shared.")
+	p("// f.mu must be held.")
+	p("func (f *Fake) keep(method string, op *longrunningpb.Operation) *longrunningpb.Operation {")
+	p("  op = proto.Clone(op).(*longrunningpb.Operation)")
+	p("  if op.GetName() == \"\" {")
+	p("    f.opCount++")
+	p("    op.Name = fmt.Sprintf(\"operations/%%s-%%d\", method, f.opCount)")
+	p("  }")
+	p("  if f.ops == nil {")
+	p("    f.ops = map[string]*longrunningpb.Operation{}")
+	p("  }")
+	p("  f.ops[op.GetName()] = proto.Clone(op).(*longrunningpb.Operation)")
+	p("  return op")
+	p("}")
+	p("")
+	p("// wrongType reports that the result queued for method is not a response of it.")
+	p("func wrongType(method string, resp proto.Message) error {")
+	p("  return status.Errorf(codes.Internal, \"fake: result queued for %%s is a %%T\", method, resp)")
+	p("}")
+	p("")
+	p("// operations is a fake of the google.longrunning.Operations service, reporting the")
+	p("// long-running operations returned by the methods of fake.")
+	p("type operations struct {")
+	p("  longrunningpb.UnimplementedOperationsServer")
+	p("  fake *Fake")
+	p("}")
+	p("")
+	p("func (o *operations) GetOperation(ctx context.Context, req *longrunningpb.GetOperationRequest) (*longrunningpb.Operation, error) {")
+	p("  o.fake.mu.Lock()")
+	p("  defer o.fake.mu.Unlock()")
+	p("  op, ok := o.fake.ops[req.GetName()]")
+	p("  if !ok {")
+	p("    return nil, status.Errorf(codes.NotFound, \"fake: no operation %%q\", req.GetName())")
+	p("  }")
+	p("  return proto.Clone(op).(*longrunningpb.Operation), nil")
+	p("}")
+	p("")
+	p("func (o *operations) CancelOperation(ctx context.Context, req *longrunningpb.CancelOperationRequest) (*emptypb.Empty, error) {")
+	p("  o.fake.mu.Lock()")
+	p("  defer o.fake.mu.Unlock()")
+	p("  op, ok := o.fake.ops[req.GetName()]")
+	p("  if !ok {")
+	p("    return nil, status.Errorf(codes.NotFound, \"fake: no operation %%q\", req.GetName())")
+	p("  }")
+	p("  if !op.GetDone() {")
+	p("    op.Done = true")
+	p("    op.Result = &longrunningpb.Operation_Error{Error: status.New(codes.Canceled, \"operation canceled\").Proto()}")
+	p("  }")
+	p("  return &emptypb.Empty{}, nil")
+	p("}")
+	p("")
+	p("func (o *operations) DeleteOperation(ctx context.Context, req *longrunningpb.DeleteOperationRequest) (*emptypb.Empty, error) {")
+	p("  o.fake.mu.Lock()")
+	p("  defer o.fake.mu.Unlock()")
+	p("  if _, ok := o.fake.ops[req.GetName()]; !ok {")
+	p("    return nil, status.Errorf(codes.NotFound, \"fake: no operation %%q\", req.GetName())")
+	p("  }")
+	p("  delete(o.fake.ops, req.GetName())")
+	p("  return &emptypb.Empty{}, nil")
+	p("}")
+	p("")
+	p("// serve starts a gRPC server, with the services register registers, on an in-memory listener,")
+	p("// and dials it. Calling stop closes the connection and stops the server.")
+	p("func serve(ctx context.Context, register func(*grpc.Server)) (conn *grpc.ClientConn, stop func(), err error) {")
+	p("  lis := bufconn.Listen(1 << 20)")
+	p("  s := grpc.NewServer()")
+	p("  register(s)")
+	p("  go s.Serve(lis)")
+	p("")
+	p("  dial := func(context.Context, string) (net.Conn, error) {")
+	p("    return lis.Dial()")
+	p("  }")
+	p("  conn, err = grpc.DialContext(ctx, \"bufnet\", grpc.WithContextDialer(dial), grpc.WithInsecure())")
+	p("  if err != nil {")
+	p("    s.Stop()")
+	p("    return nil, nil, err")
+	p("  }")
+	p("  return conn, func() {")
+	p("    conn.Close()")
+	p("    s.Stop()")
+	p("  }, nil")
+	p("}")
+	p("")
+
+	for _, path := range []string{
+		"context", "fmt", "net", "sync",
+		"github.com/golang/protobuf/proto", "github.com/golang/protobuf/ptypes", "google.golang.org/grpc",
+		"google.golang.org/grpc/codes", "google.golang.org/grpc/status", "google.golang.org/grpc/test/bufconn",
+	} {
+		g.imports[pbinfo.ImportSpec{Path: path}] = true
+	}
+	g.imports[pbinfo.ImportSpec{Name: "emptypb", Path: "github.com/golang/protobuf/ptypes/empty"}] = true
+	g.imports[pbinfo.ImportSpec{Name: "longrunningpb", Path: "google.golang.org/genproto/googleapis/longrunning"}] = true
+}
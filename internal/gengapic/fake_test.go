@@ -0,0 +1,72 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gengapic
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/googleapis/gapic-generator-go/internal/txtdiff"
+)
+
+func TestFakeFile(t *testing.T) {
+	method := func(name, in, out string, clientStreaming, serverStreaming bool) *descriptor.MethodDescriptorProto {
+		return &descriptor.MethodDescriptorProto{
+			Name:            proto.String(name),
+			InputType:       proto.String(in),
+			OutputType:      proto.String(out),
+			ClientStreaming: proto.Bool(clientStreaming),
+			ServerStreaming: proto.Bool(serverStreaming),
+		}
+	}
+
+	serv := &descriptor.ServiceDescriptorProto{
+		Name: proto.String("Foo"),
+		Method: []*descriptor.MethodDescriptorProto{
+			method("GetThing", ".my.pkg.InputType", ".my.pkg.OutputType", false, false),
+			method("MakeThing", ".my.pkg.InputType", ".google.longrunning.Operation", false, false),
+			method("ServerThings", ".my.pkg.InputType", ".my.pkg.OutputType", false, true),
+			method("ClientThings", ".my.pkg.InputType", ".my.pkg.OutputType", true, false),
+			method("BidiThings", ".my.pkg.InputType", ".my.pkg.OutputType", true, true),
+		},
+	}
+	file := &descriptor.FileDescriptorProto{
+		Package: proto.String("my.pkg"),
+		Options: &descriptor.FileOptions{GoPackage: proto.String("mypackage")},
+		MessageType: []*descriptor.DescriptorProto{
+			{Name: proto.String("InputType")},
+			{Name: proto.String("OutputType")},
+		},
+		Service: []*descriptor.ServiceDescriptorProto{serv},
+	}
+	lroFile := &descriptor.FileDescriptorProto{
+		Package:     proto.String("google.longrunning"),
+		Options:     &descriptor.FileOptions{GoPackage: proto.String("google.golang.org/genproto/googleapis/longrunning;longrunning")},
+		MessageType: []*descriptor.DescriptorProto{{Name: proto.String("Operation")}},
+	}
+
+	var g generator
+	g.init([]*descriptor.FileDescriptorProto{file, lroFile})
+	if err := g.genFakeFile(serv, "github.com/googleapis/foo/apiv1", "foo"); err != nil {
+		t.Fatal(err)
+	}
+	txtdiff.Diff(t, "fake_file", g.pt.String(), filepath.Join("testdata", "fake_file.want"))
+
+	g.reset()
+	g.genFakeSupport()
+	txtdiff.Diff(t, "fake_support", g.pt.String(), filepath.Join("testdata", "fake_support.want"))
+}
@@ -0,0 +1,388 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gengapic
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/googleapis/gapic-generator-go/internal/errors"
+	"github.com/googleapis/gapic-generator-go/internal/pbinfo"
+	"google.golang.org/genproto/googleapis/api/annotations"
+)
+
+// flattening describes a method generated from a google.api.method_signature annotation.
+// The method takes the listed request fields as parameters, builds the request
+// and calls the request-object method.
+type flattening struct {
+	// Name of the generated method, e.g. "GetBookByName".
+	name string
+
+	// Fields of the request message, in the order they are listed in the signature.
+	fields []flatField
+}
+
+// flatField is a field of the request message, possibly nested, set by a flattened method.
+type flatField struct {
+	// The fields from the request message to the field, e.g. book and title for "book.title",
+	// and the messages declaring them.
+	path []*descriptor.FieldDescriptorProto
+	msgs []*descriptor.DescriptorProto
+}
+
+// field returns the field set by ff, the last of its path.
+func (ff flatField) field() *descriptor.FieldDescriptorProto {
+	return ff.path[len(ff.path)-1]
+}
+
+// name returns the dotted name of ff in the signature, e.g. "book.title".
+func (ff flatField) name() string {
+	var names []string
+	for _, f := range ff.path {
+		names = append(names, f.GetName())
+	}
+	return strings.Join(names, ".")
+}
+
+// flattenings reports the flattened methods to generate for m.
+// m must be a method declared in serv.
+//
+// Signatures whose method name would collide with another method of the client are skipped.
+func (g *generator) flattenings(serv *descriptor.ServiceDescriptorProto, m *descriptor.MethodDescriptorProto) ([]flattening, error) {
+	// Client and bidi streaming methods have no request to build.
+	if m.GetClientStreaming() || m.GetOptions() == nil {
+		return nil, nil
+	}
+
+	eSigs, err := proto.GetExtension(m.GetOptions(), annotations.E_MethodSignature)
+	if err == proto.ErrMissingExtension {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	inType := g.descInfo.Type[m.GetInputType()]
+	inMsg, ok := inType.(*descriptor.DescriptorProto)
+	if !ok {
+		return nil, errors.E(nil, "expected %q to be message type, found %T", m.GetInputType(), inType)
+	}
+
+	taken := map[string]bool{}
+	for _, sm := range g.clientMethods(serv) {
+		taken[sm.GetName()] = true
+		taken[lroTypeName(sm.GetName())] = true
+	}
+
+	var flats []flattening
+	for _, sig := range eSigs.([]string) {
+		var names []string
+		for _, n := range strings.Split(sig, ",") {
+			if n = strings.TrimSpace(n); n != "" {
+				names = append(names, n)
+			}
+		}
+		// An empty signature is the request-object method itself.
+		if len(names) == 0 {
+			continue
+		}
+
+		fl := flattening{}
+		var nameParts []string
+		oneofs := map[*descriptor.DescriptorProto]map[int32]*descriptor.FieldDescriptorProto{}
+		for _, n := range names {
+			ff, err := g.flatField(inMsg, n)
+			if err != nil {
+				return nil, errors.E(err, "method_signature %q", sig)
+			}
+			for _, prev := range fl.fields {
+				if pn := prev.name(); strings.HasPrefix(n+".", pn+".") || strings.HasPrefix(pn+".", n+".") {
+					return nil, errors.E(nil, "method_signature %q: fields %q and %q overlap", sig, pn, n)
+				}
+			}
+			for i, f := range ff.path {
+				if f.OneofIndex == nil {
+					continue
+				}
+				msg := ff.msgs[i]
+				if oneofs[msg] == nil {
+					oneofs[msg] = map[int32]*descriptor.FieldDescriptorProto{}
+				}
+				// Fields nested in the same message of a oneof can be set together.
+				if prev := oneofs[msg][f.GetOneofIndex()]; prev != nil && prev != f {
+					return nil, errors.E(nil, "method_signature %q: multiple fields of the same oneof", sig)
+				}
+				oneofs[msg][f.GetOneofIndex()] = f
+			}
+			fl.fields = append(fl.fields, ff)
+			nameParts = append(nameParts, snakeToCamel(strings.Replace(n, ".", "_", -1)))
+		}
+
+		fl.name = m.GetName() + "By" + strings.Join(nameParts, "And")
+		if taken[fl.name] {
+			continue
+		}
+		taken[fl.name] = true
+		flats = append(flats, fl)
+	}
+	return flats, nil
+}
+
+// flatField resolves the dotted field name n of a method signature, relative to the request message req.
+// The fields leading to the last one must be singular messages, which the flattened method builds.
+func (g *generator) flatField(req *descriptor.DescriptorProto, n string) (flatField, error) {
+	var ff flatField
+	msg := req
+	for i, part := range strings.Split(n, ".") {
+		if i > 0 {
+			prev := ff.path[i-1]
+			next, ok := g.descInfo.Type[prev.GetTypeName()].(*descriptor.DescriptorProto)
+			if prev.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE || !ok ||
+				prev.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED || next.GetOptions().GetMapEntry() {
+				return flatField{}, errors.E(nil, "field %q of %q is not a singular message", prev.GetName(), n)
+			}
+			msg = next
+		}
+		f := fieldByName(msg, part)
+		if f == nil {
+			return flatField{}, errors.E(nil, "no field %q in %s", part, msg.GetName())
+		}
+		ff.path = append(ff.path, f)
+		ff.msgs = append(ff.msgs, msg)
+	}
+	return ff, nil
+}
+
+func fieldByName(msg *descriptor.DescriptorProto, name string) *descriptor.FieldDescriptorProto {
+	for _, f := range msg.GetField() {
+		if f.GetName() == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// flattenedSignature reports the signature, without the receiver, of the flattened method fl of m.
+// m must be a method declared in serv.
+func (g *generator) flattenedSignature(serv *descriptor.ServiceDescriptorProto, m *descriptor.MethodDescriptorProto, fl flattening) (string, error) {
+	ret, err := g.methodReturn(serv, m)
+	if err != nil {
+		return "", err
+	}
+
+	var params strings.Builder
+	for _, ff := range fl.fields {
+		typ, err := g.fieldGoType(ff.field())
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&params, "%s %s, ", paramName(ff), typ)
+	}
+
+	g.imports[pbinfo.ImportSpec{Path: "context"}] = true
+	g.imports[pbinfo.ImportSpec{Name: "gax", Path: "github.com/googleapis/gax-go/v2"}] = true
+	return fmt.Sprintf("%s(ctx context.Context, %sopts ...gax.CallOption) %s", fl.name, params.String(), ret), nil
+}
+
+// genFlattenedMethods generates the flattened methods of m on the client type clientType,
+// e.g. "FooClient". m must be a method declared in serv.
+func (g *generator) genFlattenedMethods(clientType string, serv *descriptor.ServiceDescriptorProto, m *descriptor.MethodDescriptorProto) error {
+	flats, err := g.flattenings(serv, m)
+	if err != nil {
+		return err
+	}
+
+	inType := g.descInfo.Type[m.GetInputType()]
+	inName, inSpec, err := g.descInfo.NameSpec(inType)
+	if err != nil {
+		return err
+	}
+
+	p := g.printf
+	for _, fl := range flats {
+		sig, err := g.flattenedSignature(serv, m, fl)
+		if err != nil {
+			return err
+		}
+
+		var params []string
+		for _, ff := range fl.fields {
+			params = append(params, paramName(ff))
+		}
+
+		p("// %s calls %s with a request built from %s.", fl.name, m.GetName(), englishList(params))
+		g.deprecationNotice(m, fl.name)
+		p("func (c *%s) %s {", clientType, sig)
+		p("  req := &%s.%s{", inSpec.Name, inName)
+		if err := g.flatFieldsLiteral(fl.fields, 0); err != nil {
+			return err
+		}
+		p("  }")
+		p("  return c.%s(ctx, req, opts...)", m.GetName())
+		p("}")
+		p("")
+	}
+
+	g.imports[inSpec] = true
+	return nil
+}
+
+// flatFieldsLiteral prints the fields of a composite literal of the message at depth in the paths of fields,
+// setting each field to its parameter. Fields nested in the same message share its literal.
+func (g *generator) flatFieldsLiteral(fields []flatField, depth int) error {
+	p := g.printf
+
+	var order []*descriptor.FieldDescriptorProto
+	groups := map[*descriptor.FieldDescriptorProto][]flatField{}
+	for _, ff := range fields {
+		f := ff.path[depth]
+		if groups[f] == nil {
+			order = append(order, f)
+		}
+		groups[f] = append(groups[f], ff)
+	}
+
+	for _, f := range order {
+		group := groups[f]
+		msg := group[0].msgs[depth]
+
+		// Fields of a oneof are set through the wrapper type of the field.
+		if f.OneofIndex != nil {
+			msgName, msgSpec, err := g.descInfo.NameSpec(msg)
+			if err != nil {
+				return err
+			}
+			oneof := msg.GetOneofDecl()[f.GetOneofIndex()]
+			p("%s: &%s.%s_%s{", snakeToCamel(oneof.GetName()), msgSpec.Name, msgName, snakeToCamel(f.GetName()))
+		}
+
+		if leaf := group[0]; len(leaf.path) == depth+1 {
+			p("%s: %s,", snakeToCamel(f.GetName()), g.flatFieldValue(msg, f, paramName(leaf)))
+		} else {
+			nestedName, nestedSpec, err := g.descInfo.NameSpec(g.descInfo.Type[f.GetTypeName()])
+			if err != nil {
+				return err
+			}
+			g.imports[nestedSpec] = true
+			p("%s: &%s.%s{", snakeToCamel(f.GetName()), nestedSpec.Name, nestedName)
+			if err := g.flatFieldsLiteral(group, depth+1); err != nil {
+				return err
+			}
+			p("},")
+		}
+
+		if f.OneofIndex != nil {
+			p("},")
+		}
+	}
+	return nil
+}
+
+// protoPtrFuncs are the functions of the proto package returning a pointer to a scalar value,
+// by the type of the field the pointer is for.
+var protoPtrFuncs = map[descriptor.FieldDescriptorProto_Type]string{
+	descriptor.FieldDescriptorProto_TYPE_DOUBLE:   "Float64",
+	descriptor.FieldDescriptorProto_TYPE_FLOAT:    "Float32",
+	descriptor.FieldDescriptorProto_TYPE_INT64:    "Int64",
+	descriptor.FieldDescriptorProto_TYPE_UINT64:   "Uint64",
+	descriptor.FieldDescriptorProto_TYPE_INT32:    "Int32",
+	descriptor.FieldDescriptorProto_TYPE_FIXED64:  "Uint64",
+	descriptor.FieldDescriptorProto_TYPE_FIXED32:  "Uint32",
+	descriptor.FieldDescriptorProto_TYPE_BOOL:     "Bool",
+	descriptor.FieldDescriptorProto_TYPE_STRING:   "String",
+	descriptor.FieldDescriptorProto_TYPE_UINT32:   "Uint32",
+	descriptor.FieldDescriptorProto_TYPE_SFIXED32: "Int32",
+	descriptor.FieldDescriptorProto_TYPE_SFIXED64: "Int64",
+	descriptor.FieldDescriptorProto_TYPE_SINT32:   "Int32",
+	descriptor.FieldDescriptorProto_TYPE_SINT64:   "Int64",
+}
+
+// flatFieldValue returns the expression setting field f of msg to the parameter param.
+// Fields with presence, such as the optional scalars of proto2 messages, are pointers.
+func (g *generator) flatFieldValue(msg *descriptor.DescriptorProto, f *descriptor.FieldDescriptorProto, param string) string {
+	if !g.hasPresence(msg, f) {
+		return param
+	}
+	if f.GetType() == descriptor.FieldDescriptorProto_TYPE_ENUM {
+		return param + ".Enum()"
+	}
+	g.imports[pbinfo.ImportSpec{Path: "github.com/golang/protobuf/proto"}] = true
+	return fmt.Sprintf("proto.%s(%s)", protoPtrFuncs[f.GetType()], param)
+}
+
+// fieldGoType reports the Go type of field f in the message generated by protoc-gen-go.
+func (g *generator) fieldGoType(f *descriptor.FieldDescriptorProto) (string, error) {
+	var typ string
+	switch f.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_ENUM:
+		t := g.descInfo.Type[f.GetTypeName()]
+		if t == nil {
+			return "", errors.E(nil, "cannot find type %q, malformed descriptor?", f.GetTypeName())
+		}
+
+		if msg, ok := t.(*descriptor.DescriptorProto); ok && msg.GetOptions().GetMapEntry() {
+			key, err := g.fieldGoType(fieldByName(msg, "key"))
+			if err != nil {
+				return "", err
+			}
+			val, err := g.fieldGoType(fieldByName(msg, "value"))
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("map[%s]%s", key, val), nil
+		}
+
+		name, spec, err := g.descInfo.NameSpec(t)
+		if err != nil {
+			return "", err
+		}
+		g.imports[spec] = true
+
+		typ = spec.Name + "." + name
+		if f.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+			typ = "*" + typ
+		}
+	default:
+		typ = pbinfo.GoTypeForPrim[f.GetType()]
+		if typ == "" {
+			return "", errors.E(nil, "unrecognized type of field %s: %v", f.GetName(), f.GetType())
+		}
+	}
+
+	if f.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		typ = "[]" + typ
+	}
+	return typ, nil
+}
+
+// paramName reports the name of the parameter holding the field ff in a flattened method,
+// e.g. "bookTitle" for "book.title". It avoids Go keywords and the names of the other parameters.
+func paramName(ff flatField) string {
+	return goIdent(lowerFirst(snakeToCamel(strings.Replace(ff.name(), ".", "_", -1))), "c", "ctx", "opts", "req")
+}
+
+// englishList joins s into a list like "a", "a and b" or "a, b, and c".
+func englishList(s []string) string {
+	switch len(s) {
+	case 0:
+		return ""
+	case 1:
+		return s[0]
+	case 2:
+		return s[0] + " and " + s[1]
+	}
+	return strings.Join(s[:len(s)-1], ", ") + ", and " + s[len(s)-1]
+}
@@ -0,0 +1,177 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gengapic
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/googleapis/gapic-generator-go/internal/pbinfo"
+	"github.com/googleapis/gapic-generator-go/internal/txtdiff"
+	"google.golang.org/genproto/googleapis/api/annotations"
+)
+
+func TestFlattenedMethods(t *testing.T) {
+	typep := func(t descriptor.FieldDescriptorProto_Type) *descriptor.FieldDescriptorProto_Type {
+		return &t
+	}
+	labelp := func(l descriptor.FieldDescriptorProto_Label) *descriptor.FieldDescriptorProto_Label {
+		return &l
+	}
+	optional := labelp(descriptor.FieldDescriptorProto_LABEL_OPTIONAL)
+
+	kind := &descriptor.EnumDescriptorProto{
+		Name: proto.String("Kind"),
+	}
+	thing := &descriptor.DescriptorProto{
+		Name: proto.String("Thing"),
+		Field: []*descriptor.FieldDescriptorProto{
+			{Name: proto.String("name"), Type: typep(descriptor.FieldDescriptorProto_TYPE_STRING), Label: optional},
+			{Name: proto.String("kind"), Type: typep(descriptor.FieldDescriptorProto_TYPE_ENUM), TypeName: proto.String(".my.pkg.Kind"), Label: optional},
+		},
+	}
+	labelsEntry := &descriptor.DescriptorProto{
+		Name: proto.String("LabelsEntry"),
+		Field: []*descriptor.FieldDescriptorProto{
+			{Name: proto.String("key"), Type: typep(descriptor.FieldDescriptorProto_TYPE_STRING), Label: optional},
+			{Name: proto.String("value"), Type: typep(descriptor.FieldDescriptorProto_TYPE_INT64), Label: optional},
+		},
+		Options: &descriptor.MessageOptions{MapEntry: proto.Bool(true)},
+	}
+	inputType := &descriptor.DescriptorProto{
+		Name: proto.String("InputType"),
+		Field: []*descriptor.FieldDescriptorProto{
+			{Name: proto.String("name"), Type: typep(descriptor.FieldDescriptorProto_TYPE_STRING), Label: optional},
+			{Name: proto.String("thing"), Type: typep(descriptor.FieldDescriptorProto_TYPE_MESSAGE), TypeName: proto.String(".my.pkg.Thing"), Label: optional},
+			{Name: proto.String("kind"), Type: typep(descriptor.FieldDescriptorProto_TYPE_ENUM), TypeName: proto.String(".my.pkg.Kind"), Label: optional},
+			{
+				Name:  proto.String("tags"),
+				Type:  typep(descriptor.FieldDescriptorProto_TYPE_STRING),
+				Label: labelp(descriptor.FieldDescriptorProto_LABEL_REPEATED),
+			},
+			{
+				Name:     proto.String("labels"),
+				Type:     typep(descriptor.FieldDescriptorProto_TYPE_MESSAGE),
+				TypeName: proto.String(".my.pkg.InputType.LabelsEntry"),
+				Label:    labelp(descriptor.FieldDescriptorProto_LABEL_REPEATED),
+			},
+			{Name: proto.String("uri"), Type: typep(descriptor.FieldDescriptorProto_TYPE_STRING), Label: optional, OneofIndex: proto.Int32(0)},
+			{Name: proto.String("type"), Type: typep(descriptor.FieldDescriptorProto_TYPE_STRING), Label: optional},
+		},
+		NestedType: []*descriptor.DescriptorProto{labelsEntry},
+		OneofDecl: []*descriptor.OneofDescriptorProto{
+			{Name: proto.String("source")},
+		},
+	}
+	outputType := &descriptor.DescriptorProto{
+		Name: proto.String("OutputType"),
+	}
+
+	file := &descriptor.FileDescriptorProto{
+		Package: proto.String("my.pkg"),
+		Options: &descriptor.FileOptions{
+			GoPackage: proto.String("mypackage"),
+		},
+	}
+
+	sigOpts := func(sigs ...string) *descriptor.MethodOptions {
+		opts := &descriptor.MethodOptions{}
+		if err := proto.SetExtension(opts, annotations.E_MethodSignature, sigs); err != nil {
+			t.Fatal(err)
+		}
+		return opts
+	}
+
+	getThing := &descriptor.MethodDescriptorProto{
+		Name:       proto.String("GetThing"),
+		InputType:  proto.String(".my.pkg.InputType"),
+		OutputType: proto.String(".my.pkg.OutputType"),
+		Options:    sigOpts("name", "name,kind", "", "thing.name"),
+	}
+	createThing := &descriptor.MethodDescriptorProto{
+		Name:       proto.String("CreateThing"),
+		InputType:  proto.String(".my.pkg.InputType"),
+		OutputType: proto.String(".my.pkg.OutputType"),
+		Options:    sigOpts("thing,tags,labels", "uri,type", "name,thing.name,thing.kind"),
+	}
+	deleteThing := &descriptor.MethodDescriptorProto{
+		Name:       proto.String("DeleteThing"),
+		InputType:  proto.String(".my.pkg.InputType"),
+		OutputType: proto.String(emptyType),
+		Options:    sigOpts("name"),
+	}
+	serv := &descriptor.ServiceDescriptorProto{
+		Name:   proto.String("Foo"),
+		Method: []*descriptor.MethodDescriptorProto{getThing, createThing, deleteThing},
+	}
+
+	var g generator
+	g.imports = map[pbinfo.ImportSpec]bool{}
+	g.aux = &auxTypes{
+		iters: map[string]*iterType{},
+	}
+
+	commonTypes(&g)
+	g.descInfo.ParentElement = map[pbinfo.ProtoType]pbinfo.ProtoType{
+		labelsEntry: inputType,
+	}
+	for _, typ := range []pbinfo.ProtoType{thing, kind, inputType, outputType} {
+		g.descInfo.Type[".my.pkg."+typ.GetName()] = typ
+		g.descInfo.ParentFile[typ] = file
+	}
+	g.descInfo.Type[".my.pkg.InputType.LabelsEntry"] = labelsEntry
+	g.descInfo.ParentFile[serv] = file
+
+	for _, m := range serv.GetMethod() {
+		g.reset()
+		if err := g.genFlattenedMethods("FooClient", serv, m); err != nil {
+			t.Error(err)
+			continue
+		}
+		txtdiff.Diff(t, m.GetName(), g.pt.String(), filepath.Join("testdata", "flattened_"+m.GetName()+".want"))
+	}
+
+	bad := &descriptor.MethodDescriptorProto{
+		Name:       proto.String("BadThing"),
+		InputType:  proto.String(".my.pkg.InputType"),
+		OutputType: proto.String(".my.pkg.OutputType"),
+		Options:    sigOpts("missing"),
+	}
+	for _, sig := range []string{"missing", "thing.missing", "tags.name", "labels.key", "thing,thing.name", "thing.name,thing.name"} {
+		bad.Options = sigOpts(sig)
+		if _, err := g.flattenings(serv, bad); err == nil {
+			t.Errorf("flattenings(%s) with signature %q = nil error, want error", bad.GetName(), sig)
+		}
+	}
+}
+
+func TestEnglishList(t *testing.T) {
+	for _, tst := range []struct {
+		in   []string
+		want string
+	}{
+		{in: nil, want: ""},
+		{in: []string{"a"}, want: "a"},
+		{in: []string{"a", "b"}, want: "a and b"},
+		{in: []string{"a", "b", "c"}, want: "a, b, and c"},
+	} {
+		if got := englishList(tst.in); !reflect.DeepEqual(got, tst.want) {
+			t.Errorf("englishList(%q) = %q, want %q", tst.in, got, tst.want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gengapic
+
+import (
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/googleapis/gapic-generator-go/internal/gensample"
+)
+
+// defaultLROPollConfig is used for the settings of LRO methods missing from the GAPIC YAML config.
+// Without a total timeout, waiting is only bounded by the context given to Wait.
+var defaultLROPollConfig = gensample.LongRunningConfig{
+	InitialPollDelayMillis: 500,
+	PollDelayMultiplier:    1.5,
+	MaxPollDelayMillis:     5000,
+}
+
+// lroPollConfig reports the polling settings of the LRO method m, declared in serv.
+// Settings missing from the GAPIC YAML config take their default value.
+func (g *generator) lroPollConfig(serv *descriptor.ServiceDescriptorProto, m *descriptor.MethodDescriptorProto) gensample.LongRunningConfig {
+	conf := defaultLROPollConfig
+	if g.gapicConf == nil {
+		return conf
+	}
+
+	fqn := g.descInfo.ParentFile[serv].GetPackage() + "." + serv.GetName()
+	for _, inter := range g.gapicConf.Interfaces {
+		if inter.Name != fqn {
+			continue
+		}
+		for _, gm := range inter.Methods {
+			if gm.Name != m.GetName() {
+				continue
+			}
+			lr := gm.LongRunning
+			if lr.InitialPollDelayMillis > 0 {
+				conf.InitialPollDelayMillis = lr.InitialPollDelayMillis
+			}
+			if lr.PollDelayMultiplier > 0 {
+				conf.PollDelayMultiplier = lr.PollDelayMultiplier
+			}
+			if lr.MaxPollDelayMillis > 0 {
+				conf.MaxPollDelayMillis = lr.MaxPollDelayMillis
+			}
+			if lr.TotalPollTimeoutMillis > 0 {
+				conf.TotalPollTimeoutMillis = lr.TotalPollTimeoutMillis
+			}
+		}
+	}
+	return conf
+}
+
+// customOpConfig reports the custom operation config of method m, declared in serv,
+// with defaults filled in, or nil if m is not configured as a custom operation.
+func (g *generator) customOpConfig(serv *descriptor.ServiceDescriptorProto, m *descriptor.MethodDescriptorProto) *gensample.CustomOperationConfig {
+	if g.gapicConf == nil {
+		return nil
+	}
+
+	fqn := g.descInfo.ParentFile[serv].GetPackage() + "." + serv.GetName()
+	for _, inter := range g.gapicConf.Interfaces {
+		if inter.Name != fqn {
+			continue
+		}
+		for _, gm := range inter.Methods {
+			if gm.Name != m.GetName() || gm.CustomOperation == nil {
+				continue
+			}
+			conf := *gm.CustomOperation
+			for _, d := range []struct {
+				field *string
+				def   string
+			}{
+				{&conf.PollingNameField, "name"},
+				{&conf.NameField, "name"},
+				{&conf.StatusField, "status"},
+				{&conf.DoneValue, "DONE"},
+			} {
+				if *d.field == "" {
+					*d.field = d.def
+				}
+			}
+			return &conf
+		}
+	}
+	return nil
+}
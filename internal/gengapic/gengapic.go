@@ -17,7 +17,6 @@ package gengapic
 import (
 	"fmt"
 	"net/url"
-	"os"
 	"path/filepath"
 	"regexp"
 	"sort"
@@ -33,11 +32,14 @@ import (
 	"github.com/golang/protobuf/protoc-gen-go/descriptor"
 	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
 	"github.com/googleapis/gapic-generator-go/internal/errors"
+	"github.com/googleapis/gapic-generator-go/internal/gensample"
 	conf "github.com/googleapis/gapic-generator-go/internal/grpc_service_config"
 	"github.com/googleapis/gapic-generator-go/internal/license"
+	"github.com/googleapis/gapic-generator-go/internal/options"
 	"github.com/googleapis/gapic-generator-go/internal/pbinfo"
 	"github.com/googleapis/gapic-generator-go/internal/printer"
 	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/genproto/googleapis/api/serviceconfig"
 )
 
 const (
@@ -55,77 +57,166 @@ const (
 
 var headerParamRegexp = regexp.MustCompile(`{([_.a-z]+)=`)
 
+// Options are the options of the generator as a protoc plugin, along with those of the
+// sample generator run by the same plugin.
+var Options = options.NewSet(
+	&options.Option{
+		Name:     "go-gapic-package",
+		Value:    "path;name",
+		Usage:    "the import path and name of the generated package, e.g. cloud.google.com/go/foo/apiv1;foo",
+		Required: true,
+	},
+	&options.Option{
+		Name:  "gapic-service-config",
+		Kind:  options.File,
+		Value: "path",
+		Usage: "the service config of the API, in YAML",
+	},
+	&options.Option{
+		Name:  "gapic-config",
+		Kind:  options.File,
+		Value: "path",
+		Usage: "the GAPIC config of the API, in YAML",
+	},
+	&options.Option{
+		Name:  "grpc-service-config",
+		Kind:  options.File,
+		Value: "path",
+		Usage: "the gRPC ServiceConfig of the API, in JSON, used to configure retries",
+	},
+	&options.Option{
+		Name:  "release-level",
+		Value: "level",
+		Usage: "the release level of the package, noted in its documentation",
+		Enum:  []string{alpha, beta, "ga"},
+	},
+	&options.Option{
+		Name:    "transport",
+		Value:   "transports",
+		Usage:   "the transports to generate clients for, separated by +",
+		Default: grpcTransport,
+	},
+	&options.Option{
+		Name:  "validate-required",
+		Kind:  options.Bool,
+		Usage: "whether clients check the REQUIRED fields of requests before sending them",
+	},
+	&options.Option{
+		Name:  "gen-fakes",
+		Kind:  options.Bool,
+		Usage: "whether to generate fake servers for testing code that uses the clients",
+	},
+	&options.Option{
+		Name:  "gen-stateful-fakes",
+		Kind:  options.Bool,
+		Usage: "whether the fakes store resources to implement the standard methods; implies gen-fakes",
+	},
+	&options.Option{
+		Name:  "gen-tests",
+		Kind:  options.Bool,
+		Usage: "whether to generate tests of the clients against the fakes; implies gen-fakes",
+	},
+	&options.Option{
+		Name:      "sample-only",
+		Kind:      options.Bool,
+		Usage:     "whether to generate only the samples",
+		Conflicts: []string{"gen-fakes", "gen-stateful-fakes", "gen-tests"},
+	},
+).Merge(gensample.Options)
+
 func Gen(genReq *plugin.CodeGeneratorRequest) (*plugin.CodeGeneratorResponse, error) {
-	var pkgPath, pkgName, outDir string
 	var g generator
 
-	if genReq.Parameter == nil {
+	opts, err := Options.Parse(genReq.GetParameter())
+	if err != nil {
+		return &g.resp, err
+	}
+	if opts.Bool("sample-only") {
+		return &g.resp, nil
+	}
+
+	pkg := opts.String("go-gapic-package")
+	p := strings.IndexByte(pkg, ';')
+	if p <= 0 || p == len(pkg)-1 {
 		return &g.resp, errors.E(nil, paramError)
 	}
+	pkgPath, pkgName := pkg[:p], pkg[p+1:]
+	outDir := filepath.FromSlash(pkgPath)
 
-	// parse plugin params, ignoring unknown values
-	for _, s := range strings.Split(*genReq.Parameter, ",") {
-		e := strings.IndexByte(s, '=')
-		if e < 0 {
-			e = len(s)
+	if f, err := opts.Open("gapic-service-config"); err != nil {
+		return &g.resp, err
+	} else if f != nil {
+		defer f.Close()
+		g.serviceConfig, err = readServiceConfig(f)
+		if err != nil {
+			return &g.resp, errors.E(nil, "error decoding service config: %v", err)
 		}
-		switch s[:e] {
-		case "go-gapic-package":
-			p := strings.IndexByte(s, ';')
-
-			if p < 0 {
-				return &g.resp, errors.E(nil, paramError)
-			}
-
-			pkgPath = s[e+1 : p]
-			pkgName = s[p+1:]
-			outDir = filepath.FromSlash(pkgPath)
-		case "gapic-service-config":
-			f, err := os.Open(s[e+1:])
-			if err != nil {
-				return &g.resp, errors.E(nil, "error opening service config: %v", err)
-			}
-
-			err = yaml.NewDecoder(f).Decode(&g.serviceConfig)
-			if err != nil {
-				return &g.resp, errors.E(nil, "error decoding service config: %v", err)
-			}
-		case "grpc-service-config":
-			data, err := os.Open(s[e+1:])
-			if err != nil {
-				return &g.resp, errors.E(nil, "error opening gRPC service config: %v", err)
-			}
-
-			g.grpcConf = &conf.ServiceConfig{}
-			err = jsonpb.Unmarshal(data, g.grpcConf)
-			if err != nil {
-				return &g.resp, errors.E(nil, "error unmarshaling gPRC service config: %v", err)
-			}
-		case "release-level":
-			g.relLvl = strings.ToLower(s[e+1:])
-		case "sample-only":
-			return &g.resp, nil
+	}
+	if f, err := opts.Open("gapic-config"); err != nil {
+		return &g.resp, err
+	} else if f != nil {
+		defer f.Close()
+		g.gapicConf = &gensample.GAPICConfig{}
+		if err := yaml.NewDecoder(f).Decode(g.gapicConf); err != nil {
+			return &g.resp, errors.E(nil, "error decoding GAPIC config: %v", err)
+		}
+	}
+	if f, err := opts.Open("grpc-service-config"); err != nil {
+		return &g.resp, err
+	} else if f != nil {
+		defer f.Close()
+		g.grpcConf = &conf.ServiceConfig{}
+		if err := jsonpb.Unmarshal(f, g.grpcConf); err != nil {
+			return &g.resp, errors.E(nil, "error unmarshaling gPRC service config: %v", err)
 		}
 	}
 
-	if pkgPath == "" || pkgName == "" || outDir == "" {
-		return &g.resp, errors.E(nil, paramError)
+	g.relLvl = strings.ToLower(opts.String("release-level"))
+	g.transports, err = parseTransports(opts.String("transport"))
+	if err != nil {
+		return &g.resp, err
 	}
+	g.validateRequired = opts.Bool("validate-required")
+	g.genFakes = opts.Bool("gen-fakes")
+	g.genStatefulFakes = opts.Bool("gen-stateful-fakes")
+	g.genTests = opts.Bool("gen-tests")
 
-	g.init(genReq.ProtoFile)
+	// Stateful fakes extend the fakes, and the tests run against them, so they are generated too.
+	if g.genStatefulFakes || g.genTests {
+		g.genFakes = true
+	}
 
+	files := genReq.GetProtoFile()
+	mixinFiles, err := loadMixinFiles(g.mixinNames(), files)
+	if err != nil {
+		return &g.resp, err
+	}
+	g.init(append(files[:len(files):len(files)], mixinFiles...))
+	if err := g.applyServiceConfig(); err != nil {
+		return &g.resp, err
+	}
+	if err := g.collectMixins(); err != nil {
+		return &g.resp, err
+	}
+
+	var genFiles []*descriptor.FileDescriptorProto
 	var genServs []*descriptor.ServiceDescriptorProto
 	for _, f := range genReq.ProtoFile {
 		if !strContains(genReq.FileToGenerate, f.GetName()) {
 			continue
 		}
+		genFiles = append(genFiles, f)
 		genServs = append(genServs, f.Service...)
 	}
+	g.clientNames = map[*descriptor.ServiceDescriptorProto]string{}
+	for _, s := range genServs {
+		g.clientNames[s] = pbinfo.ReduceServName(s.GetName(), pkgName) + "Client"
+	}
 
 	if g.serviceConfig != nil {
 		// TODO(ndietz) remove this if some metadata/packaging
 		// annotations are ever accepted
-		g.apiName = g.serviceConfig.Title
+		g.apiName = g.serviceConfig.GetTitle()
 	}
 
 	for _, s := range genServs {
@@ -147,12 +238,62 @@ func Gen(genReq *plugin.CodeGeneratorRequest) (*plugin.CodeGeneratorResponse, er
 		if err := g.genExampleFile(s, pkgName); err != nil {
 			return &g.resp, errors.E(err, "example: %s", s.GetName())
 		}
-		g.imports[pbinfo.ImportSpec{Name: pkgName, Path: pkgPath}] = true
-		g.commit(outFile+"_client_example_test.go", pkgName+"_test")
+		if len(g.pt.Bytes()) > 0 {
+			g.imports[pbinfo.ImportSpec{Name: pkgName, Path: pkgPath}] = true
+			g.commit(outFile+"_client_example_test.go", pkgName+"_test")
+		}
+
+		if g.genTests {
+			g.reset()
+			if err := g.genTestFile(s, pkgPath, pkgName); err != nil {
+				return &g.resp, errors.E(err, "test: %s", s.GetName())
+			}
+			g.commit(outFile+"_client_test.go", pkgName+"_test")
+		}
+
+		if g.genFakes {
+			g.reset()
+			if err := g.genFakeFile(s, pkgPath, pkgName); err != nil {
+				return &g.resp, errors.E(err, "fake: %s", s.GetName())
+			}
+			if g.genStatefulFakes {
+				if err := g.genStatefulFake(s, pkgName); err != nil {
+					return &g.resp, errors.E(err, "stateful fake: %s", s.GetName())
+				}
+			}
+			fakeFile := filepath.Join(outDir, fakePkgName(pkgName), camelToSnake(pbinfo.ReduceServName(s.GetName(), ""))+"_fake.go")
+			g.commit(fakeFile, fakePkgName(pkgName))
+		}
+	}
+
+	if g.genFakes && len(genServs) > 0 {
+		g.reset()
+		g.genFakeSupport()
+		if g.genStatefulFakes {
+			g.genStoreSupport()
+		}
+		g.commit(filepath.Join(outDir, fakePkgName(pkgName), "fake.go"), fakePkgName(pkgName))
+	}
+
+	g.reset()
+	if ok, err := g.genAuxFile(); err != nil {
+		return &g.resp, err
+	} else if ok {
+		g.commit(filepath.Join(outDir, "auxiliary.go"), pkgName)
+	}
+
+	resources, err := collectResources(genFiles, genReq.ProtoFile)
+	if err != nil {
+		return &g.resp, err
+	}
+	if len(resources) > 0 {
+		g.reset()
+		g.genResourceNames(resources)
+		g.commit(filepath.Join(outDir, "resource_names.go"), pkgName)
 	}
 
 	g.reset()
-	scopes, err := collectScopes(genServs, g.serviceConfig)
+	scopes, err := g.collectScopes(genServs)
 	if err != nil {
 		return &g.resp, err
 	}
@@ -162,6 +303,17 @@ func Gen(genReq *plugin.CodeGeneratorRequest) (*plugin.CodeGeneratorResponse, er
 		Content: proto.String(g.pt.String()),
 	})
 
+	if len(genServs) > 0 {
+		md, err := g.genGapicMetadata(genServs, genFiles[0].GetPackage(), pkgPath, pkgName)
+		if err != nil {
+			return &g.resp, err
+		}
+		g.resp.File = append(g.resp.File, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(filepath.Join(outDir, "gapic_metadata.json")),
+			Content: proto.String(string(md)),
+		})
+	}
+
 	return &g.resp, nil
 }
 
@@ -182,6 +334,9 @@ type generator struct {
 	// Maps proto elements to their comments
 	comments map[proto.Message]string
 
+	// Maps deprecated proto elements to their deprecation descriptions from the service config
+	deprecations map[proto.Message]string
+
 	resp plugin.CodeGeneratorResponse
 
 	imports map[pbinfo.ImportSpec]bool
@@ -190,7 +345,10 @@ type generator struct {
 	apiName string
 
 	// Parsed service config from plugin option
-	serviceConfig *serviceConfig
+	serviceConfig *serviceconfig.Service
+
+	// Parsed GAPIC YAML config from plugin option
+	gapicConf *gensample.GAPICConfig
 
 	// gRPC ServiceConfig
 	grpcConf *conf.ServiceConfig
@@ -200,6 +358,45 @@ type generator struct {
 
 	// Release level that defaults to GA/nothing
 	relLvl string
+
+	// Transports to generate clients for, gRPC by default
+	transports []string
+
+	// Whether client methods check that the REQUIRED fields of requests are set before sending them
+	validateRequired bool
+
+	// Whether to generate a package of fake servers for testing the clients
+	genFakes bool
+
+	// Whether the package of fake servers also has stateful fakes, implementing standard methods
+	genStatefulFakes bool
+
+	// Whether to generate tests of the client methods, run against the fake servers
+	genTests bool
+
+	// Default timeouts, in milliseconds, of the methods of the current service,
+	// from the gRPC ServiceConfig.
+	timeouts map[*descriptor.MethodDescriptorProto]int64
+
+	// Methods of the current service with a hedging policy in the gRPC ServiceConfig.
+	hedged map[*descriptor.MethodDescriptorProto]bool
+
+	// Methods of the current service configured as custom operations in the GAPIC config.
+	customOps map[*descriptor.MethodDescriptorProto]*customOp
+
+	// Mixin APIs listed in the service config, whose methods are added to the clients.
+	mixins []mixin
+
+	// The gRPC client fields of the mixin methods, which are called with the clients of their mixin.
+	mixinFields map[*descriptor.MethodDescriptorProto]string
+
+	// Maps the services generated in the package to the names of their client types,
+	// which the doc links of comments refer to.
+	clientNames map[*descriptor.ServiceDescriptorProto]string
+
+	// Packages imported by the client file of the current service,
+	// which the doc links of comments can refer to by name.
+	docLinkPkgs map[pbinfo.ImportSpec]bool
 }
 
 func (g *generator) init(files []*descriptor.FileDescriptorProto) {
@@ -207,9 +404,7 @@ func (g *generator) init(files []*descriptor.FileDescriptorProto) {
 
 	g.comments = map[proto.Message]string{}
 	g.imports = map[pbinfo.ImportSpec]bool{}
-	g.aux = &auxTypes{
-		iters: map[string]*iterType{},
-	}
+	g.aux = newAuxTypes()
 
 	for _, f := range files {
 		for _, loc := range f.GetSourceCodeInfo().GetLocation() {
@@ -314,72 +509,86 @@ func (g *generator) reset() {
 // gen generates client for the given service.
 func (g *generator) gen(serv *descriptor.ServiceDescriptorProto, pkgName string) error {
 	servName := pbinfo.ReduceServName(*serv.Name, pkgName)
+
+	// The client methods take the requests, so their packages are imported.
+	g.docLinkPkgs = map[pbinfo.ImportSpec]bool{}
+	for _, m := range g.clientMethods(serv) {
+		if _, spec, err := g.descInfo.NameSpec(g.descInfo.Type[m.GetInputType()]); err == nil {
+			g.docLinkPkgs[spec] = true
+		}
+	}
+
 	if err := g.clientOptions(serv, servName); err != nil {
 		return err
 	}
 	if err := g.clientInit(serv, servName); err != nil {
 		return err
 	}
+	if err := g.collectCustomOps(serv); err != nil {
+		return err
+	}
+	if err := g.clientInterface(serv, servName); err != nil {
+		return err
+	}
 
-	// clear LRO types between services
-	g.aux.lros = []*descriptor.MethodDescriptorProto{}
-
-	for _, m := range serv.Method {
+	for _, m := range g.clientMethods(serv) {
 		g.methodDoc(m)
 		if err := g.genMethod(servName, serv, m); err != nil {
 			return errors.E(err, "method: %s", m.GetName())
 		}
+		if err := g.genFlattenedMethods(servName+"Client", serv, m); err != nil {
+			return errors.E(err, "method: %s", m.GetName())
+		}
+	}
+	if err := g.genCustomOpConstructors(servName + "Client"); err != nil {
+		return err
 	}
 
-	sort.Slice(g.aux.lros, func(i, j int) bool {
-		return g.aux.lros[i].GetName() < g.aux.lros[j].GetName()
-	})
-	for _, m := range g.aux.lros {
-		if err := g.lroType(servName, serv, m); err != nil {
+	if g.hasTransport(restTransport) {
+		g.aux.rest = true
+		if err := g.restClientOptions(serv, servName); err != nil {
 			return err
 		}
-	}
+		g.restClientInit(serv, servName)
 
-	var iters []*iterType
-	for _, iter := range g.aux.iters {
-		// skip iterators that have already been generated in this package
-		//
-		// TODO(ndietz): investigate generating auxiliary types in a
-		// separate file in the same package to avoid keeping this state
-		if iter.generated {
-			continue
+		for _, m := range g.clientMethods(serv) {
+			g.methodDoc(m)
+			if err := g.genRESTMethod(servName, serv, m); err != nil {
+				return errors.E(err, "REST method: %s", m.GetName())
+			}
+			if err := g.genFlattenedMethods(servName+"RESTClient", serv, m); err != nil {
+				return errors.E(err, "REST method: %s", m.GetName())
+			}
 		}
+		if err := g.genCustomOpConstructors(servName + "RESTClient"); err != nil {
+			return err
+		}
+	}
 
-		iter.generated = true
-		iters = append(iters, iter)
+	var lros []*descriptor.MethodDescriptorProto
+	for _, m := range serv.GetMethod() {
+		if g.isLRO(m) {
+			lros = append(lros, m)
+		}
 	}
-	sort.Slice(iters, func(i, j int) bool {
-		return iters[i].iterTypeName < iters[j].iterTypeName
+	sort.Slice(lros, func(i, j int) bool {
+		return lros[i].GetName() < lros[j].GetName()
 	})
-	for _, iter := range iters {
-		g.pagingIter(iter)
+	for _, m := range lros {
+		g.lroFromName(servName, serv, m)
 	}
 
 	return nil
 }
 
-// auxTypes gathers details of types we need to generate along with the client
-type auxTypes struct {
-	// List of LRO methods. For each method "Foo", we use this to create the "FooOperation" type.
-	lros []*descriptor.MethodDescriptorProto
-
-	// "List" of iterator types. We use these to generate FooIterator returned by paging methods.
-	// Since multiple methods can page over the same type, we dedupe by the name of the iterator,
-	// which is in turn determined by the element type name.
-	iters map[string]*iterType
-}
-
 // genMethod generates a single method from a client. m must be a method declared in serv.
 // If the generated method requires an auxillary type, it is added to aux.
 func (g *generator) genMethod(servName string, serv *descriptor.ServiceDescriptorProto, m *descriptor.MethodDescriptorProto) error {
-	if m.GetOutputType() == lroType {
-		g.aux.lros = append(g.aux.lros, m)
-		return g.lroCall(servName, m)
+	if g.isLRO(m) {
+		if err := g.addLRO(serv, m); err != nil {
+			return err
+		}
+		return g.lroCall(servName, serv, m)
 	}
 
 	if m.GetOutputType() == emptyType {
@@ -397,6 +606,12 @@ func (g *generator) genMethod(servName string, serv *descriptor.ServiceDescripto
 		return g.pagingCall(servName, m, pf, iter)
 	}
 
+	if m.GetClientStreaming() || m.GetServerStreaming() {
+		if err := g.addStream(serv, m); err != nil {
+			return err
+		}
+	}
+
 	switch {
 	case m.GetClientStreaming():
 		return g.noRequestStreamCall(servName, serv, m)
@@ -407,6 +622,16 @@ func (g *generator) genMethod(servName string, serv *descriptor.ServiceDescripto
 	}
 }
 
+// unaryResult prints the statement returning resp, the response of the unary method m,
+// from the client method. The responses of custom operation methods are wrapped in their operation type.
+func (g *generator) unaryResult(m *descriptor.MethodDescriptorProto, resp string) {
+	if g.customOps[m] != nil {
+		g.customOpResult(m, resp)
+		return
+	}
+	g.printf("return %s, nil", resp)
+}
+
 func (g *generator) unaryCall(servName string, m *descriptor.MethodDescriptorProto) error {
 	inType := g.descInfo.Type[*m.InputType]
 	outType := g.descInfo.Type[*m.OutputType]
@@ -422,25 +647,43 @@ func (g *generator) unaryCall(servName string, m *descriptor.MethodDescriptorPro
 
 	p := g.printf
 
-	p("func (c *%sClient) %s(ctx context.Context, req *%s.%s, opts ...gax.CallOption) (*%s.%s, error) {",
-		servName, *m.Name, inSpec.Name, inType.GetName(), outSpec.Name, outType.GetName())
+	ret := fmt.Sprintf("*%s.%s", outSpec.Name, outType.GetName())
+	if g.customOps[m] != nil {
+		ret = "*" + lroTypeName(m.GetName())
+	}
+	p("func (c *%sClient) %s(ctx context.Context, req *%s.%s, opts ...gax.CallOption) (%s, error) {",
+		servName, *m.Name, inSpec.Name, inType.GetName(), ret)
 
+	if err := g.validateRequest(m, "nil, err"); err != nil {
+		return err
+	}
+	g.applyTimeout(m)
 	err = g.insertMetadata(m)
 	if err != nil {
 		return err
 	}
 
 	g.appendCallOpts(m)
-	p("var resp *%s.%s", outSpec.Name, outType.GetName())
-	p("err := gax.Invoke(ctx, func(ctx context.Context, settings gax.CallSettings) error {")
-	p("  var err error")
-	p("  resp, err = %s", grpcClientCall(servName, *m.Name))
-	p("  return err")
-	p("}, opts...)")
-	p("if err != nil {")
-	p("  return nil, err")
-	p("}")
-	p("return resp, nil")
+	if g.hedged[m] {
+		p("res, err := invokeHedged(ctx, opts, func(ctx context.Context, settings gax.CallSettings) (interface{}, error) {")
+		p("  return %s", g.grpcClientCall(servName, m))
+		p("})")
+		p("if err != nil {")
+		p("  return nil, err")
+		p("}")
+		g.unaryResult(m, fmt.Sprintf("res.(*%s.%s)", outSpec.Name, outType.GetName()))
+	} else {
+		p("var resp *%s.%s", outSpec.Name, outType.GetName())
+		p("err := gax.Invoke(ctx, func(ctx context.Context, settings gax.CallSettings) error {")
+		p("  var err error")
+		p("  resp, err = %s", g.grpcClientCall(servName, m))
+		p("  return err")
+		p("}, opts...)")
+		p("if err != nil {")
+		p("  return nil, err")
+		p("}")
+		g.unaryResult(m, "resp")
+	}
 
 	p("}")
 	p("")
@@ -464,18 +707,29 @@ func (g *generator) emptyUnaryCall(servName string, m *descriptor.MethodDescript
 	p("func (c *%sClient) %s(ctx context.Context, req *%s.%s, opts ...gax.CallOption) error {",
 		servName, m.GetName(), inSpec.Name, inType.GetName())
 
+	if err := g.validateRequest(m, "err"); err != nil {
+		return err
+	}
+	g.applyTimeout(m)
 	err = g.insertMetadata(m)
 	if err != nil {
 		return err
 	}
 
 	g.appendCallOpts(m)
-	p("err := gax.Invoke(ctx, func(ctx context.Context, settings gax.CallSettings) error {")
-	p("  var err error")
-	p("  _, err = %s", grpcClientCall(servName, m.GetName()))
-	p("  return err")
-	p("}, opts...)")
-	p("return err")
+	if g.hedged[m] {
+		p("_, err := invokeHedged(ctx, opts, func(ctx context.Context, settings gax.CallSettings) (interface{}, error) {")
+		p("  return %s", g.grpcClientCall(servName, m))
+		p("})")
+		p("return err")
+	} else {
+		p("err := gax.Invoke(ctx, func(ctx context.Context, settings gax.CallSettings) error {")
+		p("  var err error")
+		p("  _, err = %s", g.grpcClientCall(servName, m))
+		p("  return err")
+		p("}, opts...)")
+		p("return err")
+	}
 
 	p("}")
 	p("")
@@ -485,44 +739,65 @@ func (g *generator) emptyUnaryCall(servName string, m *descriptor.MethodDescript
 }
 
 func (g *generator) insertMetadata(m *descriptor.MethodDescriptorProto) error {
-	headers, err := parseRequestHeaders(m)
+	hasParams, err := g.requestParamsMD(m)
 	if err != nil {
 		return err
 	}
+	if hasParams {
+		g.printf("ctx = insertMetadata(ctx, c.xGoogMetadata, md)")
+	} else {
+		g.printf("ctx = insertMetadata(ctx, c.xGoogMetadata)")
+	}
+	return nil
+}
 
-	if len(headers) > 0 {
-		seen := map[string]bool{}
-		var formats, values strings.Builder
-		for _, h := range headers {
-			field := h[1]
-			// skip fields that have multiple patterns, they use the same accessor
-			if _, dupe := seen[field]; dupe {
-				continue
-			}
-			seen[field] = true
-
-			// URL encode key & values separately per aip.dev/4222.
-			// Encode the key ahead of time to reduce clutter
-			// and because it will likely never be necessary
-			fmt.Fprintf(&values, " %q, url.QueryEscape(req%s),",
-				url.QueryEscape(field), buildAccessor(field))
-			formats.WriteString("%s=%v&")
-		}
-		f := formats.String()[:formats.Len()-1]
-		v := values.String()[:values.Len()-1]
+// requestParamsMD generates code that builds md, the metadata.MD holding
+// the x-goog-request-params header of req, and reports whether m has such a header.
+func (g *generator) requestParamsMD(m *descriptor.MethodDescriptorProto) (bool, error) {
+	// The google.api.routing annotation, if any, replaces the headers derived from google.api.http.
+	params, err := routingParams(m)
+	if err != nil {
+		return false, err
+	}
+	if len(params) > 0 {
+		g.routingHeadersMD(params)
+		return true, nil
+	}
 
-		g.printf("md := metadata.Pairs(\"x-goog-request-params\", fmt.Sprintf(%q,%s))", f, v)
-		g.printf("ctx = insertMetadata(ctx, c.xGoogMetadata, md)")
+	headers, err := parseRequestHeaders(m)
+	if err != nil {
+		return false, err
+	}
+	if len(headers) == 0 {
+		return false, nil
+	}
 
-		g.imports[pbinfo.ImportSpec{Path: "fmt"}] = true
-		g.imports[pbinfo.ImportSpec{Path: "net/url"}] = true
+	seen := map[string]bool{}
+	var formats, values strings.Builder
+	for _, h := range headers {
+		field := h[1]
+		// skip fields that have multiple patterns, they use the same accessor
+		if _, dupe := seen[field]; dupe {
+			continue
+		}
+		seen[field] = true
 
-		return nil
+		// URL encode key & values separately per aip.dev/4222.
+		// Encode the key ahead of time to reduce clutter
+		// and because it will likely never be necessary
+		fmt.Fprintf(&values, " %q, url.QueryEscape(req%s),",
+			url.QueryEscape(field), buildAccessor(field))
+		formats.WriteString("%s=%v&")
 	}
+	f := formats.String()[:formats.Len()-1]
+	v := values.String()[:values.Len()-1]
 
-	g.printf("ctx = insertMetadata(ctx, c.xGoogMetadata)")
+	g.printf("md := metadata.Pairs(\"x-goog-request-params\", fmt.Sprintf(%q,%s))", f, v)
 
-	return nil
+	g.imports[pbinfo.ImportSpec{Path: "fmt"}] = true
+	g.imports[pbinfo.ImportSpec{Path: "net/url"}] = true
+	g.imports[pbinfo.ImportSpec{Path: "google.golang.org/grpc/metadata"}] = true
+	return true, nil
 }
 
 func buildAccessor(field string) string {
@@ -534,6 +809,25 @@ func buildAccessor(field string) string {
 	return ax.String()
 }
 
+// applyTimeout sets the default timeout of m, if any, on ctx unless it already has a deadline.
+// The generated code must be in the function that makes the call,
+// since the timeout is canceled when the function returns.
+func (g *generator) applyTimeout(m *descriptor.MethodDescriptorProto) {
+	timeout, ok := g.timeouts[m]
+	if !ok {
+		return
+	}
+
+	p := g.printf
+	p("if _, ok := ctx.Deadline(); !ok {")
+	p("  cctx, cancel := context.WithTimeout(ctx, %d*time.Millisecond)", timeout)
+	p("  defer cancel()")
+	p("  ctx = cctx")
+	p("}")
+
+	g.imports[pbinfo.ImportSpec{Path: "time"}] = true
+}
+
 func (g *generator) appendCallOpts(m *descriptor.MethodDescriptorProto) {
 	g.printf("opts = append(%[1]s[0:len(%[1]s):len(%[1]s)], opts...)", "c.CallOptions."+*m.Name)
 }
@@ -544,10 +838,14 @@ func (g *generator) methodDoc(m *descriptor.MethodDescriptorProto) {
 
 	// If there's no comment, adding method name is just confusing.
 	if com == "" {
+		if deprecated(m) {
+			g.deprecationParagraph(g.deprecations[m], m.GetName())
+		}
 		return
 	}
 
 	g.comment(*m.Name + " " + lowerFirst(com))
+	g.deprecationNotice(m, m.GetName())
 }
 
 func (g *generator) comment(s string) {
@@ -556,7 +854,7 @@ func (g *generator) comment(s string) {
 		return
 	}
 
-	s = MDPlain(s)
+	s = mdPlain(s, g.docLink)
 
 	lines := strings.Split(s, "\n")
 	for _, l := range lines {
@@ -576,8 +874,8 @@ func grpcClientField(reducedServName string) string {
 	return lowerFirst(reducedServName + "Client")
 }
 
-func grpcClientCall(reducedServName, methName string) string {
-	return fmt.Sprintf("c.%s.%s(ctx, req, settings.GRPC...)", grpcClientField(reducedServName), methName)
+func (g *generator) grpcClientCall(reducedServName string, m *descriptor.MethodDescriptorProto) string {
+	return fmt.Sprintf("c.%s.%s(ctx, req, settings.GRPC...)", g.methodClientField(reducedServName, m), m.GetName())
 }
 
 func lowerFirst(s string) string {
@@ -596,6 +894,23 @@ func upperFirst(s string) string {
 	return string(unicode.ToUpper(r)) + s[w:]
 }
 
+// goIdent returns name, suffixed with "Arg" if it is a Go keyword or one of reserved,
+// so that it can be used as an identifier in generated code.
+func goIdent(name string, reserved ...string) string {
+	switch name {
+	case "break", "case", "chan", "const", "continue", "default", "defer", "else",
+		"fallthrough", "for", "func", "go", "goto", "if", "import", "interface",
+		"map", "package", "range", "return", "select", "struct", "switch", "type", "var":
+		return name + "Arg"
+	}
+	for _, r := range reserved {
+		if name == r {
+			return name + "Arg"
+		}
+	}
+	return name
+}
+
 func camelToSnake(s string) string {
 	var sb strings.Builder
 	for i, r := range s {
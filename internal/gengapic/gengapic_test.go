@@ -16,11 +16,14 @@ package gengapic
 
 import (
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/googleapis/gapic-generator-go/internal/gensample"
 	"github.com/googleapis/gapic-generator-go/internal/pbinfo"
+	"github.com/googleapis/gapic-generator-go/internal/routing"
 	"github.com/googleapis/gapic-generator-go/internal/txtdiff"
 	"google.golang.org/genproto/googleapis/api/annotations"
 	"google.golang.org/genproto/googleapis/longrunning"
@@ -53,12 +56,18 @@ func TestMethodDoc(t *testing.T) {
 	m := &descriptor.MethodDescriptorProto{
 		Name: proto.String("MyMethod"),
 	}
+	deprecatedM := &descriptor.MethodDescriptorProto{
+		Name:    proto.String("MyMethod"),
+		Options: &descriptor.MethodOptions{Deprecated: proto.Bool(true)},
+	}
 
 	var g generator
 	g.comments = make(map[proto.Message]string)
+	g.deprecations = make(map[proto.Message]string)
 
 	for _, tst := range []struct {
-		in, want string
+		in, deprecation, want string
+		m                     *descriptor.MethodDescriptorProto
 	}{
 		{
 			in:   "",
@@ -68,8 +77,29 @@ func TestMethodDoc(t *testing.T) {
 			in:   "Does stuff.\n It also does other stuffs.",
 			want: "// MyMethod does stuff.\n// It also does other stuffs.\n",
 		},
+		{
+			in:   "Does stuff.",
+			m:    deprecatedM,
+			want: "// MyMethod does stuff.\n//\n// Deprecated: MyMethod may be removed in a future version.\n",
+		},
+		{
+			in:          "Does stuff.",
+			deprecation: "Use MyOtherMethod instead.",
+			m:           deprecatedM,
+			want:        "// MyMethod does stuff.\n//\n// Deprecated: Use MyOtherMethod instead.\n",
+		},
+		{
+			in:   "",
+			m:    deprecatedM,
+			want: "// Deprecated: MyMethod may be removed in a future version.\n",
+		},
 	} {
+		m := m
+		if tst.m != nil {
+			m = tst.m
+		}
 		g.comments[m] = tst.in
+		g.deprecations[m] = tst.deprecation
 		g.pt.Reset()
 		g.methodDoc(m)
 		if got := g.pt.String(); got != tst.want {
@@ -165,6 +195,41 @@ func TestGenMethod(t *testing.T) {
 		},
 	}
 
+	thingsEntry := &descriptor.DescriptorProto{
+		Name: proto.String("ThingsEntry"),
+		Field: []*descriptor.FieldDescriptorProto{
+			{
+				Name:  proto.String("key"),
+				Type:  typep(descriptor.FieldDescriptorProto_TYPE_STRING),
+				Label: labelp(descriptor.FieldDescriptorProto_LABEL_OPTIONAL),
+			},
+			{
+				Name:     proto.String("value"),
+				Type:     typep(descriptor.FieldDescriptorProto_TYPE_MESSAGE),
+				TypeName: proto.String(".my.pkg.OutputType"),
+				Label:    labelp(descriptor.FieldDescriptorProto_LABEL_OPTIONAL),
+			},
+		},
+		Options: &descriptor.MessageOptions{MapEntry: proto.Bool(true)},
+	}
+	pageMapOutputType := &descriptor.DescriptorProto{
+		Name: proto.String("PageMapOutputType"),
+		Field: []*descriptor.FieldDescriptorProto{
+			{
+				Name:  proto.String("next_page_token"),
+				Type:  typep(descriptor.FieldDescriptorProto_TYPE_STRING),
+				Label: labelp(descriptor.FieldDescriptorProto_LABEL_OPTIONAL),
+			},
+			{
+				Name:     proto.String("things"),
+				Type:     typep(descriptor.FieldDescriptorProto_TYPE_MESSAGE),
+				TypeName: proto.String(".my.pkg.PageMapOutputType.ThingsEntry"),
+				Label:    labelp(descriptor.FieldDescriptorProto_LABEL_REPEATED),
+			},
+		},
+		NestedType: []*descriptor.DescriptorProto{thingsEntry},
+	}
+
 	opts := &descriptor.MethodOptions{}
 	ext := &annotations.HttpRule{
 		Pattern: &annotations.HttpRule_Get{
@@ -180,6 +245,17 @@ func TestGenMethod(t *testing.T) {
 	}
 	proto.SetExtension(opts, annotations.E_Http, ext)
 
+	// The google.api.routing annotation replaces the headers derived from google.api.http.
+	routingOpts := &descriptor.MethodOptions{}
+	proto.SetExtension(routingOpts, annotations.E_Http, ext)
+	proto.SetExtension(routingOpts, routing.E_Routing, &routing.RoutingRule{
+		RoutingParameters: []*routing.RoutingParameter{
+			{Field: "other"},
+			{Field: "field_name.nested", PathTemplate: "{routing_id=projects/*}/**"},
+			{Field: "another", PathTemplate: "bar/*/{routing_id=baz/*}"},
+		},
+	})
+
 	file := &descriptor.FileDescriptorProto{
 		Package: proto.String("my.pkg"),
 		Options: &descriptor.FileOptions{
@@ -193,14 +269,16 @@ func TestGenMethod(t *testing.T) {
 
 	commonTypes(&g)
 	for _, typ := range []*descriptor.DescriptorProto{
-		inputType, outputType, pageInputType, pageOutputType,
+		inputType, outputType, pageInputType, pageOutputType, pageMapOutputType,
 	} {
 		g.descInfo.Type[".my.pkg."+*typ.Name] = typ
 		g.descInfo.ParentFile[typ] = file
 	}
+	g.descInfo.Type[".my.pkg.PageMapOutputType.ThingsEntry"] = thingsEntry
 	g.descInfo.ParentFile[serv] = file
 	g.descInfo.ParentElement = map[pbinfo.ProtoType]pbinfo.ProtoType{
 		paginatedField: pageOutputType,
+		thingsEntry:    pageMapOutputType,
 	}
 
 	meths := []*descriptor.MethodDescriptorProto{
@@ -216,12 +294,24 @@ func TestGenMethod(t *testing.T) {
 			OutputType: proto.String(".my.pkg.OutputType"),
 			Options:    opts,
 		},
+		{
+			Name:       proto.String("GetRoutedThing"),
+			InputType:  proto.String(".my.pkg.InputType"),
+			OutputType: proto.String(".my.pkg.OutputType"),
+			Options:    routingOpts,
+		},
 		{
 			Name:       proto.String("GetManyThings"),
 			InputType:  proto.String(".my.pkg.PageInputType"),
 			OutputType: proto.String(".my.pkg.PageOutputType"),
 			Options:    opts,
 		},
+		{
+			Name:       proto.String("GetManyThingsMap"),
+			InputType:  proto.String(".my.pkg.PageInputType"),
+			OutputType: proto.String(".my.pkg.PageMapOutputType"),
+			Options:    opts,
+		},
 		{
 			Name:            proto.String("ServerThings"),
 			InputType:       proto.String(".my.pkg.InputType"),
@@ -246,20 +336,35 @@ func TestGenMethod(t *testing.T) {
 		},
 	}
 
+	// GetOneThing and GetManyThings have default timeouts.
+	g.timeouts = map[*descriptor.MethodDescriptorProto]int64{
+		meths[1]: 30000,
+		meths[3]: 60000,
+	}
+	// GetEmptyThing sends hedged requests.
+	g.hedged = map[*descriptor.MethodDescriptorProto]bool{
+		meths[0]: true,
+	}
+
 methods:
 	for _, m := range meths {
 		g.pt.Reset()
 
-		g.aux = &auxTypes{
-			iters: map[string]*iterType{},
-		}
+		g.aux = newAuxTypes()
 		if err := g.genMethod("Foo", serv, m); err != nil {
 			t.Error(err)
 			continue
 		}
 
-		for _, m := range g.aux.lros {
-			if err := g.lroType("MyService", serv, m); err != nil {
+		for _, lro := range g.aux.lros {
+			if err := g.lroType(lro.serv, lro.m); err != nil {
+				t.Error(err)
+				continue methods
+			}
+		}
+
+		for _, sm := range g.aux.streams {
+			if err := g.streamType(sm.m); err != nil {
 				t.Error(err)
 				continue methods
 			}
@@ -277,8 +382,12 @@ func TestGenLRO(t *testing.T) {
 	inputType := &descriptor.DescriptorProto{
 		Name: proto.String("InputType"),
 	}
+	resultType := &descriptor.DescriptorProto{
+		Name: proto.String("Result"),
+	}
 	outputType := &descriptor.DescriptorProto{
-		Name: proto.String("OutputType"),
+		Name:       proto.String("OutputType"),
+		NestedType: []*descriptor.DescriptorProto{resultType},
 	}
 
 	file := &descriptor.FileDescriptorProto{
@@ -287,7 +396,7 @@ func TestGenLRO(t *testing.T) {
 			GoPackage: proto.String("mypackage"),
 		},
 	}
-	serv := &descriptor.ServiceDescriptorProto{}
+	serv := &descriptor.ServiceDescriptorProto{Name: proto.String("Foo")}
 
 	var g generator
 	g.imports = map[pbinfo.ImportSpec]bool{}
@@ -299,8 +408,28 @@ func TestGenLRO(t *testing.T) {
 		g.descInfo.Type[".my.pkg."+*typ.Name] = typ
 		g.descInfo.ParentFile[typ] = file
 	}
+	g.descInfo.Type[".my.pkg.OutputType.Result"] = resultType
+	g.descInfo.ParentElement = map[pbinfo.ProtoType]pbinfo.ProtoType{
+		resultType: outputType,
+	}
 	g.descInfo.ParentFile[serv] = file
 
+	// RespLRO polls faster than the default, for up to a minute.
+	g.gapicConf = &gensample.GAPICConfig{
+		Interfaces: []gensample.GAPICInterface{{
+			Name: "my.pkg.Foo",
+			Methods: []gensample.GAPICMethod{{
+				Name: "RespLRO",
+				LongRunning: gensample.LongRunningConfig{
+					InitialPollDelayMillis: 100,
+					PollDelayMultiplier:    2,
+					MaxPollDelayMillis:     1000,
+					TotalPollTimeoutMillis: 60000,
+				},
+			}},
+		}},
+	}
+
 	emptyLRO := &longrunning.OperationInfo{
 		ResponseType: emptyValue,
 	}
@@ -313,6 +442,14 @@ func TestGenLRO(t *testing.T) {
 	respLROOpts := &descriptor.MethodOptions{}
 	proto.SetExtension(respLROOpts, longrunning.E_OperationInfo, respLRO)
 
+	// The response type is nested, and named relative to the package of the service.
+	nestedLRO := &longrunning.OperationInfo{
+		ResponseType: "OutputType.Result",
+		MetadataType: "my.pkg.OutputType",
+	}
+	nestedLROOpts := &descriptor.MethodOptions{}
+	proto.SetExtension(nestedLROOpts, longrunning.E_OperationInfo, nestedLRO)
+
 	lros := []*descriptor.MethodDescriptorProto{
 		{
 			Name:       proto.String("EmptyLRO"),
@@ -326,21 +463,28 @@ func TestGenLRO(t *testing.T) {
 			OutputType: proto.String(".google.longrunning.Operation"),
 			Options:    respLROOpts,
 		},
+		{
+			Name:       proto.String("NestedLRO"),
+			InputType:  proto.String(".my.pkg.InputType"),
+			OutputType: proto.String(".google.longrunning.Operation"),
+			Options:    nestedLROOpts,
+		},
 	}
 
 lros:
 	for _, m := range lros {
 		g.pt.Reset()
 
-		g.aux = &auxTypes{}
+		g.aux = newAuxTypes()
 
 		if err := g.genMethod("Foo", serv, m); err != nil {
 			t.Error(err)
 			continue
 		}
+		g.lroFromName("Foo", serv, m)
 
-		for _, m := range g.aux.lros {
-			if err := g.lroType("MyService", serv, m); err != nil {
+		for _, lro := range g.aux.lros {
+			if err := g.lroType(lro.serv, lro.m); err != nil {
 				t.Error(err)
 				continue lros
 			}
@@ -350,6 +494,44 @@ lros:
 	}
 }
 
+func TestPollSettingsField(t *testing.T) {
+	file := &descriptor.FileDescriptorProto{Package: proto.String("my.pkg")}
+	serv := &descriptor.ServiceDescriptorProto{Name: proto.String("Foo")}
+	configured := &descriptor.MethodDescriptorProto{Name: proto.String("Configured")}
+	unconfigured := &descriptor.MethodDescriptorProto{Name: proto.String("Unconfigured")}
+
+	var g generator
+	g.imports = map[pbinfo.ImportSpec]bool{}
+	g.descInfo.ParentFile = map[proto.Message]*descriptor.FileDescriptorProto{serv: file}
+	g.gapicConf = &gensample.GAPICConfig{
+		Interfaces: []gensample.GAPICInterface{{
+			Name: "my.pkg.Foo",
+			Methods: []gensample.GAPICMethod{{
+				Name:        "Configured",
+				LongRunning: gensample.LongRunningConfig{TotalPollTimeoutMillis: 60000},
+			}},
+		}},
+	}
+
+	for _, tst := range []struct {
+		m           *descriptor.MethodDescriptorProto
+		wantTimeout bool
+	}{
+		{m: configured, wantTimeout: true},
+		// Operations of methods without a total timeout are only bounded by the context given to Wait.
+		{m: unconfigured},
+	} {
+		g.pt.Reset()
+		g.pollSettingsField(serv, tst.m)
+		if got := strings.Contains(g.pt.String(), "Timeout:"); got != tst.wantTimeout {
+			t.Errorf("pollSettingsField(%s) sets Timeout = %t, want %t:\n%s", tst.m.GetName(), got, tst.wantTimeout, g.pt.String())
+		}
+	}
+	if got := g.lroPollConfig(serv, unconfigured).TotalPollTimeoutMillis; got != 0 {
+		t.Errorf("lroPollConfig(Unconfigured).TotalPollTimeoutMillis = %d, want 0", got)
+	}
+}
+
 func Test_buildAccessor(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -0,0 +1,129 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gengapic
+
+import "github.com/googleapis/gapic-generator-go/internal/pbinfo"
+
+// hedgingHelpers prints the package-level hedgingPolicy call option and the
+// invokeHedged function used by the methods with a hedging policy.
+//
+// gax.CallSettings has no room for a hedging policy, so hedgingPolicy resolves to nothing,
+// and invokeHedged finds it among the call options instead.
+// See https://github.com/grpc/proposal/blob/master/A6-client-retries.md#hedging-policy.
+func (g *generator) hedgingHelpers() {
+	p := g.printf
+
+	p("// hedgingPolicy is a gax.CallOption that makes invokeHedged send up to maxAttempts")
+	p("// attempts of a call in parallel, delay apart, until one of them succeeds or fails")
+	p("// with a code other than nonFatalCodes.")
+	p("type hedgingPolicy struct {")
+	p("  maxAttempts int")
+	p("  delay time.Duration")
+	p("  nonFatalCodes []codes.Code")
+	p("}")
+	p("")
+	p("// Resolve implements gax.CallOption. The policy is applied by invokeHedged,")
+	p("// so there are no settings to resolve.")
+	p("func (*hedgingPolicy) Resolve(*gax.CallSettings) {}")
+	p("")
+	p("func (hp *hedgingPolicy) isNonFatal(err error) bool {")
+	p("  c := status.Code(err)")
+	p("  for _, nf := range hp.nonFatalCodes {")
+	p("    if c == nf {")
+	p("      return true")
+	p("    }")
+	p("  }")
+	p("  return false")
+	p("}")
+	p("")
+	p("// invokeHedged calls call according to the last hedgingPolicy in opts, and returns")
+	p("// the result of the first successful attempt. The other attempts are canceled.")
+	p("// Without a hedgingPolicy, it calls call like gax.Invoke.")
+	p("func invokeHedged(ctx context.Context, opts []gax.CallOption, call func(context.Context, gax.CallSettings) (interface{}, error)) (interface{}, error) {")
+	p("  var settings gax.CallSettings")
+	p("  var hp *hedgingPolicy")
+	p("  for _, opt := range opts {")
+	p("    opt.Resolve(&settings)")
+	p("    if o, ok := opt.(*hedgingPolicy); ok {")
+	p("      hp = o")
+	p("    }")
+	p("  }")
+	p("  if hp == nil {")
+	p("    var resp interface{}")
+	p("    err := gax.Invoke(ctx, func(ctx context.Context, settings gax.CallSettings) error {")
+	p("      var err error")
+	p("      resp, err = call(ctx, settings)")
+	p("      return err")
+	p("    }, opts...)")
+	p("    return resp, err")
+	p("  }")
+	p("")
+	p("  ctx, cancel := context.WithCancel(ctx)")
+	p("  defer cancel()")
+	p("")
+	p("  type result struct {")
+	p("    resp interface{}")
+	p("    err error")
+	p("  }")
+	p("  // Buffered so that attempts finishing after we return do not block.")
+	p("  results := make(chan result, hp.maxAttempts)")
+	p("  var next <-chan time.Time")
+	p("  sent, pending := 0, 0")
+	p("  send := func() {")
+	p("    sent++")
+	p("    pending++")
+	p("    go func() {")
+	p("      resp, err := call(ctx, settings)")
+	p("      results <- result{resp, err}")
+	p("    }()")
+	p("    next = nil")
+	p("    if sent < hp.maxAttempts {")
+	p("      next = time.After(hp.delay)")
+	p("    }")
+	p("  }")
+	p("")
+	p("  send()")
+	p("  var lastErr error")
+	p("  for pending > 0 {")
+	p("    select {")
+	p("    case <-ctx.Done():")
+	p("      return nil, ctx.Err()")
+	p("    case <-next:")
+	p("      send()")
+	p("    case r := <-results:")
+	p("      pending--")
+	p("      if r.err == nil {")
+	p("        return r.resp, nil")
+	p("      }")
+	p("      if !hp.isNonFatal(r.err) {")
+	p("        return nil, r.err")
+	p("      }")
+	p("      lastErr = r.err")
+	p("      // A non-fatal failure triggers the next attempt right away.")
+	p("      if sent < hp.maxAttempts {")
+	p("        send()")
+	p("      }")
+	p("    }")
+	p("  }")
+	p("  return nil, lastErr")
+	p("}")
+	p("")
+
+	g.imports[pbinfo.ImportSpec{Path: "context"}] = true
+	g.imports[pbinfo.ImportSpec{Path: "time"}] = true
+	g.imports[pbinfo.ImportSpec{Name: "gax", Path: "github.com/googleapis/gax-go/v2"}] = true
+	g.imports[pbinfo.ImportSpec{Path: "google.golang.org/grpc/codes"}] = true
+	g.imports[pbinfo.ImportSpec{Path: "google.golang.org/grpc/status"}] = true
+}
@@ -16,7 +16,6 @@ package gengapic
 
 import (
 	"fmt"
-	"strings"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/protoc-gen-go/descriptor"
@@ -24,7 +23,7 @@ import (
 	"google.golang.org/genproto/googleapis/longrunning"
 )
 
-func (g *generator) lroCall(servName string, m *descriptor.MethodDescriptorProto) error {
+func (g *generator) lroCall(servName string, serv *descriptor.ServiceDescriptorProto, m *descriptor.MethodDescriptorProto) error {
 	inType := g.descInfo.Type[m.GetInputType()]
 	outType := g.descInfo.Type[m.GetOutputType()]
 
@@ -44,6 +43,10 @@ func (g *generator) lroCall(servName string, m *descriptor.MethodDescriptorProto
 	p("func (c *%sClient) %s(ctx context.Context, req *%s.%s, opts ...gax.CallOption) (*%s, error) {",
 		servName, *m.Name, inSpec.Name, inType.GetName(), lroType)
 
+	if err := g.validateRequest(m, "nil, err"); err != nil {
+		return err
+	}
+	g.applyTimeout(m)
 	err = g.insertMetadata(m)
 	if err != nil {
 		return err
@@ -53,7 +56,7 @@ func (g *generator) lroCall(servName string, m *descriptor.MethodDescriptorProto
 	p("  var resp *%s.%s", outSpec.Name, outType.GetName())
 	p("  err := gax.Invoke(ctx, func(ctx context.Context, settings gax.CallSettings) error {")
 	p("    var err error")
-	p("    resp, err = %s", grpcClientCall(servName, *m.Name))
+	p("    resp, err = %s", g.grpcClientCall(servName, m))
 	p("    return err")
 	p("  }, opts...)")
 	p("  if err != nil {")
@@ -61,6 +64,7 @@ func (g *generator) lroCall(servName string, m *descriptor.MethodDescriptorProto
 	p("  }")
 	p("  return &%s{", lroType)
 	p("    lro: longrunning.InternalNewOperation(c.LROClient, resp),")
+	g.pollSettingsField(serv, m)
 	p("  }, nil")
 
 	p("}")
@@ -72,7 +76,26 @@ func (g *generator) lroCall(servName string, m *descriptor.MethodDescriptorProto
 	return nil
 }
 
-func (g *generator) lroType(servName string, serv *descriptor.ServiceDescriptorProto, m *descriptor.MethodDescriptorProto) error {
+// lroInfoType reports the Go type of the message named by the response_type or metadata_type
+// of a google.longrunning.operation_info, used in a method of serv. The name is resolved relative
+// to the package of serv, so it may be fully-qualified, or refer to a top-level or nested message
+// of the same package.
+func (g *generator) lroInfoType(serv *descriptor.ServiceDescriptorProto, name string) (string, error) {
+	pkg := g.descInfo.ParentFile[serv].GetPackage()
+	typ := g.descInfo.Resolve(name, pkg)
+	if typ == nil {
+		return "", fmt.Errorf("no type %q in scope of package %q", name, pkg)
+	}
+	typName, spec, err := g.descInfo.NameSpec(typ)
+	if err != nil {
+		return "", err
+	}
+	g.imports[spec] = true
+	return fmt.Sprintf("%s.%s", spec.Name, typName), nil
+}
+
+// lroType generates the "FooOperation" type of the LRO method m, declared in serv.
+func (g *generator) lroType(serv *descriptor.ServiceDescriptorProto, m *descriptor.MethodDescriptorProto) error {
 	mFQN := fmt.Sprintf("%s.%s.%s", g.descInfo.ParentFile[serv].GetPackage(), serv.GetName(), m.GetName())
 	lroType := lroTypeName(*m.Name)
 	p := g.printf
@@ -87,94 +110,65 @@ func (g *generator) lroType(servName string, serv *descriptor.ServiceDescriptorP
 		return fmt.Errorf("rpc %q has google.longrunning.operation_info but is missing option google.longrunning.operation_info.response_type", mFQN)
 	}
 
-	var respType string
-	{
-		// eLRO.ResponseType is either fully-qualified or top-level in the same package as the method.
-		//
-		// TODO(ndietz) this won't work with nested message types in the same package;
-		// migrating to protoreflect will help remove from semantic meaning in the names.
-		if strings.IndexByte(fullName, '.') < 0 {
-			fullName = g.descInfo.ParentFile[serv].GetPackage() + "." + fullName
-		}
-
-		// When we build a map[name]Type in pbinfo, we prefix names with '.' to signify that they are fully qualified.
-		// The string in ResponseType does not have the prefix, so we add it.
-		fullName = "." + fullName
-
-		typ := g.descInfo.Type[fullName]
-		name, respSpec, err := g.descInfo.NameSpec(typ)
-		if err != nil {
-			return fmt.Errorf("unable to resolve google.longrunning.operation_info.response_type value %q in rpc %q", opInfo.GetResponseType(), mFQN)
-		}
-		g.imports[respSpec] = true
-
-		respType = fmt.Sprintf("%s.%s", respSpec.Name, name)
+	respType, err := g.lroInfoType(serv, fullName)
+	if err != nil {
+		return fmt.Errorf("unable to resolve google.longrunning.operation_info.response_type value %q in rpc %q: %v", fullName, mFQN, err)
 	}
 
 	hasMeta := opInfo.GetMetadataType() != ""
 	var metaType string
 	if hasMeta {
-		fullName := opInfo.GetMetadataType()
-		// TODO(ndietz) this won't work with nested message types in the same package;
-		// migrating to protoreflect will help remove from semantic meaning in the names.
-		if strings.IndexByte(fullName, '.') < 0 {
-			fullName = g.descInfo.ParentFile[serv].GetPackage() + "." + fullName
-		}
-		fullName = "." + fullName
-
-		typ := g.descInfo.Type[fullName]
-		name, meta, err := g.descInfo.NameSpec(typ)
+		metaType, err = g.lroInfoType(serv, opInfo.GetMetadataType())
 		if err != nil {
-			return fmt.Errorf("unable to resolve google.longrunning.operation_info.metadata_type value %q in rpc %q", opInfo.GetMetadataType(), mFQN)
+			return fmt.Errorf("unable to resolve google.longrunning.operation_info.metadata_type value %q in rpc %q: %v", opInfo.GetMetadataType(), mFQN, err)
 		}
-		g.imports[meta] = true
-
-		metaType = fmt.Sprintf("%s.%s", meta.Name, name)
 	}
 
 	// Type definition
 	{
 		p("// %s manages a long-running operation from %s.", lroType, *m.Name)
+		g.deprecationNotice(m, lroType)
 		p("type %s struct {", lroType)
 		p("  lro *longrunning.Operation")
+		p("  pollSettings PollSettings")
 		p("}")
 		p("")
 	}
 
-	// LRO from name
-	{
-		p("// %[1]s returns a new %[1]s from a given name.", lroType)
-		p("// The name must be that of a previously created %s, possibly from a different process.", lroType)
-		p("func (c *%sClient) %[2]s(name string) *%[2]s {", servName, lroType)
-		p("  return &%s{", lroType)
-		p("    lro: longrunning.InternalNewOperation(c.LROClient, &longrunningpb.Operation{Name: name}),")
-		p("  }")
-		p("}")
-		p("")
-
-		g.imports[pbinfo.ImportSpec{Name: "longrunningpb", Path: "google.golang.org/genproto/googleapis/longrunning"}] = true
-	}
-
 	// Wait
 	{
 		p("// Wait blocks until the long-running operation is completed, returning the response and any errors encountered.")
+		p("// It polls the operation with the PollSettings configured for the method that started it.")
 		p("//")
 		p("// See documentation of Poll for error-handling information.")
 		if opInfo.GetResponseType() == emptyValue {
 			p("func (op *%s) Wait(ctx context.Context, opts ...gax.CallOption) error {", lroType)
-			p("  return op.lro.WaitWithInterval(ctx, nil, time.Minute, opts...)")
 		} else {
 			p("func (op *%s) Wait(ctx context.Context, opts ...gax.CallOption) (*%s, error) {", lroType, respType)
+		}
+		p("  return op.WaitWithSettings(ctx, op.pollSettings, opts...)")
+		p("}")
+		p("")
+
+		p("// WaitWithSettings is like Wait, but polls the operation according to settings.")
+		if opInfo.GetResponseType() == emptyValue {
+			p("func (op *%s) WaitWithSettings(ctx context.Context, settings PollSettings, opts ...gax.CallOption) error {", lroType)
+			p("  return waitOperation(ctx, settings, func(ctx context.Context) error {")
+			p("    return op.lro.Poll(ctx, nil, opts...)")
+			p("  }, op.Done)")
+		} else {
+			p("func (op *%s) WaitWithSettings(ctx context.Context, settings PollSettings, opts ...gax.CallOption) (*%s, error) {", lroType, respType)
 			p("  var resp %s", respType)
-			p("  if err := op.lro.WaitWithInterval(ctx, &resp, time.Minute, opts...); err != nil {")
+			p("  err := waitOperation(ctx, settings, func(ctx context.Context) error {")
+			p("    return op.lro.Poll(ctx, &resp, opts...)")
+			p("  }, op.Done)")
+			p("  if err != nil {")
 			p("    return nil, err")
 			p("  }")
 			p("  return &resp, nil")
 		}
 		p("}")
 		p("")
-
-		g.imports[pbinfo.ImportSpec{Path: "time"}] = true
 	}
 
 	// Poll
@@ -244,9 +238,106 @@ func (g *generator) lroType(servName string, serv *descriptor.ServiceDescriptorP
 		p("}")
 		p("")
 	}
+
+	g.imports[pbinfo.ImportSpec{Path: "context"}] = true
+	g.imports[pbinfo.ImportSpec{Name: "gax", Path: "github.com/googleapis/gax-go/v2"}] = true
+	g.imports[pbinfo.ImportSpec{Path: "cloud.google.com/go/longrunning"}] = true
 	return nil
 }
 
+// lroFromName generates the method of the client of servName that returns
+// the "FooOperation" of the LRO method m, declared in serv, from the name of an operation.
+func (g *generator) lroFromName(servName string, serv *descriptor.ServiceDescriptorProto, m *descriptor.MethodDescriptorProto) {
+	lroType := lroTypeName(m.GetName())
+	p := g.printf
+
+	p("// %[1]s returns a new %[1]s from a given name.", lroType)
+	p("// The name must be that of a previously created %s, possibly from a different process.", lroType)
+	g.deprecationNotice(m, lroType)
+	p("func (c *%sClient) %[2]s(name string) *%[2]s {", servName, lroType)
+	p("  return &%s{", lroType)
+	p("    lro: longrunning.InternalNewOperation(c.LROClient, &longrunningpb.Operation{Name: name}),")
+	g.pollSettingsField(serv, m)
+	p("  }")
+	p("}")
+	p("")
+
+	g.imports[pbinfo.ImportSpec{Path: "cloud.google.com/go/longrunning"}] = true
+	g.imports[pbinfo.ImportSpec{Name: "longrunningpb", Path: "google.golang.org/genproto/googleapis/longrunning"}] = true
+}
+
 func lroTypeName(methodName string) string {
 	return methodName + "Operation"
 }
+
+// pollSettingsField prints the pollSettings field of the "FooOperation" of the LRO method m,
+// declared in serv, in a composite literal.
+func (g *generator) pollSettingsField(serv *descriptor.ServiceDescriptorProto, m *descriptor.MethodDescriptorProto) {
+	conf := g.lroPollConfig(serv, m)
+	p := g.printf
+
+	p("pollSettings: PollSettings{")
+	p("  Initial: %d * time.Millisecond,", conf.InitialPollDelayMillis)
+	p("  Multiplier: %v,", conf.PollDelayMultiplier)
+	p("  Max: %d * time.Millisecond,", conf.MaxPollDelayMillis)
+	if conf.TotalPollTimeoutMillis > 0 {
+		p("  Timeout: %d * time.Millisecond,", conf.TotalPollTimeoutMillis)
+	}
+	p("},")
+
+	g.imports[pbinfo.ImportSpec{Path: "time"}] = true
+}
+
+// lroHelpers prints the package-level types and functions used by the "FooOperation" types,
+// of both google.longrunning.Operation and custom operation methods.
+func (g *generator) lroHelpers() {
+	p := g.printf
+
+	p("// PollSettings configures how the Wait methods of long-running operations poll them.")
+	p("type PollSettings struct {")
+	p("  // Initial is the delay after the first poll.")
+	p("  Initial time.Duration")
+	p("")
+	p("  // Multiplier is the factor by which the delay increases after each poll.")
+	p("  Multiplier float64")
+	p("")
+	p("  // Max is the maximum delay between polls.")
+	p("  Max time.Duration")
+	p("")
+	p("  // Timeout is the total time to wait for the operation to complete.")
+	p("  // If zero, the wait is only bounded by the deadline of the context.")
+	p("  Timeout time.Duration")
+	p("}")
+	p("")
+
+	p("// waitOperation calls poll with backoff according to settings until done reports")
+	p("// that the operation has completed, or poll fails.")
+	p("func waitOperation(ctx context.Context, settings PollSettings, poll func(context.Context) error, done func() bool) error {")
+	p("  if settings.Timeout > 0 {")
+	p("    var cancel context.CancelFunc")
+	p("    ctx, cancel = context.WithTimeout(ctx, settings.Timeout)")
+	p("    defer cancel()")
+	p("  }")
+	p("  bo := gax.Backoff{")
+	p("    Initial: settings.Initial,")
+	p("    Max: settings.Max,")
+	p("    Multiplier: settings.Multiplier,")
+	p("  }")
+	p("  for {")
+	p("    if err := poll(ctx); err != nil {")
+	p("      return err")
+	p("    }")
+	p("    if done() {")
+	p("      return nil")
+	p("    }")
+	p("    if err := gax.Sleep(ctx, bo.Pause()); err != nil {")
+	p("      return err")
+	p("    }")
+	p("  }")
+	p("}")
+	p("")
+
+	g.imports[pbinfo.ImportSpec{Path: "context"}] = true
+	g.imports[pbinfo.ImportSpec{Path: "time"}] = true
+	g.imports[pbinfo.ImportSpec{Name: "gax", Path: "github.com/googleapis/gax-go/v2"}] = true
+}
@@ -21,15 +21,40 @@ import (
 	"fmt"
 	"log"
 	"regexp"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/golang-commonmark/markdown"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
 )
 
 var linkParser = regexp.MustCompile(`<a href=["'](.+)["']`)
 
+// xrefParser matches the cross-references of proto comments, like [Book][google.example.v1.Book],
+// capturing the text and the fully-qualified name of the referenced element.
+var xrefParser = regexp.MustCompile(`\[([^\[\]]+)\]\[([A-Za-z_][A-Za-z0-9_]*(?:\.[A-Za-z_][A-Za-z0-9_]*)*)\]`)
+
+// xrefPlaceholder matches the placeholders mdPlain swaps cross-references for while parsing,
+// capturing their index.
+var xrefPlaceholder = regexp.MustCompile("\uE000([0-9]+)\uE001")
+
 func MDPlain(s string) string {
-	var mdr mdRenderer
+	return mdPlain(s, nil)
+}
+
+// mdPlain renders the markdown s as plain text, like MDPlain. Cross-references to proto elements
+// are replaced with the Go doc links resolve reports for them, or with their text if there is none.
+func mdPlain(s string, resolve func(ref string) (string, bool)) string {
+	mdr := mdRenderer{resolve: resolve}
+
+	// Cross-references are swapped for placeholders before parsing, since the parser
+	// would take the fully-qualified names in them for URLs.
+	s = xrefParser.ReplaceAllStringFunc(s, func(xref string) string {
+		mdr.xrefs = append(mdr.xrefs, xrefParser.FindStringSubmatch(xref))
+		return fmt.Sprintf("\uE000%d\uE001", len(mdr.xrefs)-1)
+	})
+
 	for _, tok := range markdown.New(markdown.HTML(true)).Parse([]byte(s)) {
 		mdr.plain(tok)
 	}
@@ -46,19 +71,32 @@ type mdRenderer struct {
 	// to nest, though I'm not sure if that'd be a valid Markdown.
 	linkTargets []string
 	listLevel   int
+
+	// resolve reports the Go doc link to the proto element with the fully-qualified name ref.
+	resolve func(ref string) (string, bool)
+	// The submatches of xrefParser of the cross-references replaced by placeholders.
+	xrefs [][]string
+
+	// The rows of cells of the table being rendered, if any, and whether a cell is open.
+	table  [][]string
+	inCell bool
 }
 
 func (m *mdRenderer) plain(t markdown.Token) {
 	switch t := t.(type) {
 	case *markdown.Inline:
+		if m.inCell {
+			m.cell(t)
+			return
+		}
 		for _, c := range t.Children {
 			m.plain(c)
 		}
 	case *markdown.Text:
-		m.sb.WriteString(t.Content)
+		m.sb.WriteString(m.restoreXrefs(t.Content, true))
 	case *markdown.CodeInline:
-		m.sb.WriteString(t.Content)
-	case *markdown.Softbreak:
+		m.sb.WriteString(m.restoreXrefs(t.Content, false))
+	case *markdown.Softbreak, *markdown.Hardbreak:
 		m.sb.WriteByte('\n')
 		// indent multiple line list items according to list level
 		m.indent()
@@ -87,15 +125,107 @@ func (m *mdRenderer) plain(t markdown.Token) {
 		m.indent()
 	case *markdown.ListItemClose:
 
-	// ignore # headings
+	// headings of all levels become Go doc headings
 	case *markdown.HeadingOpen:
+		m.sb.WriteString("# ")
 	case *markdown.HeadingClose:
+		m.sb.WriteString("\n\n")
+
+	case *markdown.CodeBlock:
+		m.preformatted(strings.Split(strings.TrimRight(m.restoreXrefs(t.Content, false), "\n"), "\n"))
+	case *markdown.Fence:
+		m.preformatted(strings.Split(strings.TrimRight(m.restoreXrefs(t.Content, false), "\n"), "\n"))
+
+	// the paragraphs of block quotes are rendered as any other
+	case *markdown.BlockquoteOpen:
+	case *markdown.BlockquoteClose:
+	case *markdown.Hr:
+
+	case *markdown.TableOpen:
+		m.table = [][]string{}
+	case *markdown.TableClose:
+		m.preformatted(tableLines(m.table))
+		m.table = nil
+	case *markdown.TrOpen:
+		m.table = append(m.table, nil)
+	case *markdown.ThOpen, *markdown.TdOpen:
+		m.inCell = true
+		row := &m.table[len(m.table)-1]
+		*row = append(*row, "")
+	case *markdown.ThClose, *markdown.TdClose:
+		m.inCell = false
+	case *markdown.TheadOpen, *markdown.TheadClose, *markdown.TbodyOpen, *markdown.TbodyClose, *markdown.TrClose:
 
 	default:
 		log.Printf("unhandled type: %T", t)
 	}
 }
 
+// cell renders the content of the current table cell.
+func (m *mdRenderer) cell(t *markdown.Inline) {
+	cr := mdRenderer{resolve: m.resolve, xrefs: m.xrefs}
+	cr.plain(t)
+	row := m.table[len(m.table)-1]
+	row[len(row)-1] = strings.TrimSpace(cr.sb.String())
+}
+
+// preformatted writes lines as a Go doc preformatted block, indented past the current list level.
+func (m *mdRenderer) preformatted(lines []string) {
+	for _, l := range lines {
+		if strings.TrimSpace(l) != "" {
+			m.indent()
+			m.sb.WriteString("  ")
+			m.sb.WriteString(l)
+		}
+		m.sb.WriteByte('\n')
+	}
+	m.sb.WriteByte('\n')
+}
+
+// tableLines lays out the rows of a table, the first being the header, in aligned columns.
+func tableLines(rows [][]string) []string {
+	var widths []int
+	for _, row := range rows {
+		for i, c := range row {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			if w := utf8.RuneCountInString(c); w > widths[i] {
+				widths[i] = w
+			}
+		}
+	}
+
+	line := func(cells []string) string {
+		var sb strings.Builder
+		for i, w := range widths {
+			var c string
+			if i < len(cells) {
+				c = cells[i]
+			}
+			if i > 0 {
+				sb.WriteString(" | ")
+			}
+			sb.WriteString(c)
+			sb.WriteString(strings.Repeat(" ", w-utf8.RuneCountInString(c)))
+		}
+		return strings.TrimRight(sb.String(), " ")
+	}
+
+	var lines []string
+	for i, row := range rows {
+		lines = append(lines, line(row))
+		if i == 0 {
+			sep := make([]string, len(widths))
+			for j, w := range widths {
+				sep[j] = strings.Repeat("-", w)
+			}
+			lines = append(lines, strings.Join(sep, "-|-"))
+		}
+	}
+	return lines
+}
+
 func (m *mdRenderer) html(t *markdown.HTMLInline) {
 	// font-based tags like <b> and most closing tags are just ignored entirely
 
@@ -113,6 +243,28 @@ func (m *mdRenderer) html(t *markdown.HTMLInline) {
 	}
 }
 
+// restoreXrefs replaces the placeholders of cross-references in s. If link is true, they are
+// replaced with Go doc links, or with their text if they can't be resolved. Otherwise, as in code,
+// they are restored verbatim.
+func (m *mdRenderer) restoreXrefs(s string, link bool) string {
+	return xrefPlaceholder.ReplaceAllStringFunc(s, func(ph string) string {
+		i, err := strconv.Atoi(xrefPlaceholder.FindStringSubmatch(ph)[1])
+		if err != nil || i >= len(m.xrefs) {
+			return ph
+		}
+		xref := m.xrefs[i]
+		if !link {
+			return xref[0]
+		}
+		if m.resolve != nil {
+			if l, ok := m.resolve(xref[2]); ok {
+				return l
+			}
+		}
+		return xref[1]
+	})
+}
+
 func (m *mdRenderer) indent() {
 	if m.listLevel > 0 {
 		for l := 0; l < m.listLevel; l++ {
@@ -120,3 +272,51 @@ func (m *mdRenderer) indent() {
 		}
 	}
 }
+
+// docLink reports the Go doc link to the generated element of the proto element with the
+// fully-qualified name ref, without leading dot. Services and methods link to the clients
+// generated in the package and their methods, and messages and enums to their Go types.
+// Other elements, like fields, and elements without generated code can't be linked to.
+func (g *generator) docLink(ref string) (string, bool) {
+	fqn := "." + ref
+	if serv, ok := g.descInfo.Serv[fqn]; ok {
+		client, ok := g.clientNames[serv]
+		if !ok {
+			return "", false
+		}
+		return "[" + client + "]", true
+	}
+
+	if t, ok := g.descInfo.Type[fqn]; ok {
+		switch t.(type) {
+		case *descriptor.DescriptorProto, *descriptor.EnumDescriptorProto:
+		default:
+			return "", false
+		}
+		goName, spec, err := g.descInfo.NameSpec(t)
+		if err != nil {
+			return "", false
+		}
+		// A package imported by the file can be referred to by name, others by import path.
+		if g.docLinkPkgs[spec] {
+			return fmt.Sprintf("[%s.%s]", spec.Name, goName), true
+		}
+		return fmt.Sprintf("[%s.%s]", spec.Path, goName), true
+	}
+
+	dot := strings.LastIndexByte(fqn, '.')
+	serv, ok := g.descInfo.Serv[fqn[:dot]]
+	if !ok {
+		return "", false
+	}
+	client, ok := g.clientNames[serv]
+	if !ok {
+		return "", false
+	}
+	for _, m := range serv.GetMethod() {
+		if m.GetName() == fqn[dot+1:] {
+			return fmt.Sprintf("[%s.%s]", client, m.GetName()), true
+		}
+	}
+	return "", false
+}
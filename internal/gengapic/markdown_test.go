@@ -18,7 +18,13 @@
 package gengapic
 
 import (
+	"path/filepath"
 	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/googleapis/gapic-generator-go/internal/pbinfo"
+	"github.com/googleapis/gapic-generator-go/internal/txtdiff"
 )
 
 func TestMDPlain(t *testing.T) {
@@ -65,9 +71,23 @@ func TestMDPlain(t *testing.T) {
 			want: "List:\n\n  item1\n  abc\n\n    item2\n    def, ghi\n\ndone",
 		},
 		{
-			// ignore headings
+			// headings of all levels become Go doc headings
 			in:   "## Heading",
-			want: "Heading",
+			want: "# Heading",
+		},
+		{
+			in:   "Heading\n=======\n\ntext",
+			want: "# Heading\n\ntext",
+		},
+		{
+			// block quotes render as paragraphs
+			in:   "> quoted\n\ntext",
+			want: "quoted\n\ntext",
+		},
+		{
+			// cross-references can't be resolved without a generator
+			in:   "Gets a [Book][google.example.v1.Book].",
+			want: "Gets a Book.",
 		},
 	} {
 		got := MDPlain(tst.in)
@@ -76,3 +96,109 @@ func TestMDPlain(t *testing.T) {
 		}
 	}
 }
+
+func TestMDPlainBlocks(t *testing.T) {
+	const in = `Lists the books of a shelf.
+
+# Filtering
+
+The filter is an expression over the fields of a book:
+
+` + "```" + `
+author = "Ursula K. Le Guin" AND
+  genre = FANTASY
+` + "```" + `
+
+Indented code is kept as is:
+
+    ListBooks(shelf, filter)
+
+## Ordering
+
+| Field | Order | Notes |
+|-------|:-----:|-------|
+| ` + "`title`" + ` | asc | The default. |
+| publish_time | desc | Newest first, use [Book][google.example.v1.Book]. |
+| rating | | |
+
+* A list item with code:
+
+  ` + "```" + `
+  rating > 3
+  ` + "```" + `
+
+> Books on hold are never listed.
+`
+
+	var g generator
+	g.comment(in)
+	txtdiff.Diff(t, "markdown", g.pt.String(), filepath.Join("testdata", "markdown.want"))
+}
+
+func TestMDPlainDocLinks(t *testing.T) {
+	getBook := &descriptor.MethodDescriptorProto{Name: proto.String("GetBook")}
+	library := &descriptor.ServiceDescriptorProto{
+		Name:   proto.String("LibraryService"),
+		Method: []*descriptor.MethodDescriptorProto{getBook},
+	}
+	other := &descriptor.ServiceDescriptorProto{Name: proto.String("OtherService")}
+	book := &descriptor.DescriptorProto{
+		Name:       proto.String("Book"),
+		Field:      []*descriptor.FieldDescriptorProto{{Name: proto.String("name")}},
+		NestedType: []*descriptor.DescriptorProto{{Name: proto.String("Author")}},
+	}
+	file := &descriptor.FileDescriptorProto{
+		Package:     proto.String("google.example.v1"),
+		Options:     &descriptor.FileOptions{GoPackage: proto.String("google.golang.org/genproto/example/v1;example")},
+		MessageType: []*descriptor.DescriptorProto{book},
+		EnumType:    []*descriptor.EnumDescriptorProto{{Name: proto.String("Genre")}},
+		Service:     []*descriptor.ServiceDescriptorProto{library, other},
+	}
+	shelf := &descriptor.FileDescriptorProto{
+		Package:     proto.String("google.example.shelf"),
+		Options:     &descriptor.FileOptions{GoPackage: proto.String("google.golang.org/genproto/example/shelf;shelf")},
+		MessageType: []*descriptor.DescriptorProto{{Name: proto.String("Shelf")}},
+	}
+
+	var g generator
+	g.init([]*descriptor.FileDescriptorProto{file, shelf})
+	g.clientNames = map[*descriptor.ServiceDescriptorProto]string{library: "LibraryClient"}
+	g.docLinkPkgs = map[pbinfo.ImportSpec]bool{
+		{Name: "examplepb", Path: "google.golang.org/genproto/example/v1"}: true,
+	}
+
+	for _, tst := range []struct {
+		in, want string
+	}{
+		{
+			in:   "Gets a [Book][google.example.v1.Book].",
+			want: "Gets a [examplepb.Book].",
+		},
+		{
+			in:   "The [author][google.example.v1.Book.Author] and [genre][google.example.v1.Genre] of a book.",
+			want: "The [examplepb.Book_Author] and [examplepb.Genre] of a book.",
+		},
+		{
+			in:   "Puts it on a [Shelf][google.example.shelf.Shelf].",
+			want: "Puts it on a [google.golang.org/genproto/example/shelf.Shelf].",
+		},
+		{
+			in:   "See [LibraryService][google.example.v1.LibraryService] and [GetBook][google.example.v1.LibraryService.GetBook].",
+			want: "See [LibraryClient] and [LibraryClient.GetBook].",
+		},
+		{
+			// fields, services without clients, and unknown elements fall back to their text
+			in:   "The [name][google.example.v1.Book.name] of a [Book][google.example.v1.Book], see [OtherService][google.example.v1.OtherService] and [Nothing][google.example.v1.Nothing].",
+			want: "The name of a [examplepb.Book], see OtherService and Nothing.",
+		},
+		{
+			// cross-references in code are left alone
+			in:   "Code `[Book][google.example.v1.Book]`",
+			want: "Code [Book][google.example.v1.Book]",
+		},
+	} {
+		if got := mdPlain(tst.in, g.docLink); got != tst.want {
+			t.Errorf("mdPlain(%q)=%q, want %q", tst.in, got, tst.want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gengapic
+
+import (
+	"encoding/json"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/googleapis/gapic-generator-go/internal/errors"
+	"github.com/googleapis/gapic-generator-go/internal/pbinfo"
+)
+
+// The following types mirror the JSON form of google.gapic.metadata.GapicMetadata,
+// which is not yet in genproto.
+
+// gapicMetadata maps the services and RPCs of a proto package to the clients and methods
+// generated for them in a Go package.
+type gapicMetadata struct {
+	Schema         string                      `json:"schema"`
+	Comment        string                      `json:"comment"`
+	Language       string                      `json:"language"`
+	ProtoPackage   string                      `json:"protoPackage"`
+	LibraryPackage string                      `json:"libraryPackage"`
+	Services       map[string]*serviceMetadata `json:"services"`
+}
+
+// serviceMetadata maps the transports of a service to its client for them.
+type serviceMetadata struct {
+	Clients map[string]*clientMetadata `json:"clients"`
+}
+
+// clientMetadata maps the RPCs of a service to the methods of one of its clients.
+type clientMetadata struct {
+	LibraryClient string                  `json:"libraryClient"`
+	RPCs          map[string]*rpcMetadata `json:"rpcs"`
+}
+
+// rpcMetadata lists the methods of a client that call an RPC.
+type rpcMetadata struct {
+	Methods []string `json:"methods"`
+}
+
+// client returns the client of the service serv for the transport t, adding libClient
+// if it has none.
+func (md *gapicMetadata) client(serv, t, libClient string) *clientMetadata {
+	sm, ok := md.Services[serv]
+	if !ok {
+		sm = &serviceMetadata{Clients: map[string]*clientMetadata{}}
+		md.Services[serv] = sm
+	}
+	cm, ok := sm.Clients[t]
+	if !ok {
+		cm = &clientMetadata{
+			LibraryClient: libClient,
+			RPCs:          map[string]*rpcMetadata{},
+		}
+		sm.Clients[t] = cm
+	}
+	return cm
+}
+
+// genGapicMetadata generates gapic_metadata.json, mapping each RPC of servs to the methods
+// of the clients generated for each transport, whose types are named after the services
+// reduced with ReduceServName, as their files are.
+//
+// The RPCs of the mixins added to the clients are listed under the fully-qualified name
+// of the mixin service, with the first client that has them.
+func (g *generator) genGapicMetadata(servs []*descriptor.ServiceDescriptorProto, protoPkg, pkgPath, pkgName string) ([]byte, error) {
+	md := gapicMetadata{
+		Schema:         "1.0",
+		Comment:        "This file maps proto services/RPCs to the corresponding library clients/methods.",
+		Language:       "go",
+		ProtoPackage:   protoPkg,
+		LibraryPackage: pkgPath,
+		Services:       map[string]*serviceMetadata{},
+	}
+
+	mixinServs := map[*descriptor.MethodDescriptorProto]string{}
+	for _, mix := range g.mixins {
+		fqn := g.descInfo.ParentFile[mix.serv].GetPackage() + "." + mix.serv.GetName()
+		for _, m := range mix.methods {
+			mixinServs[m] = fqn
+		}
+	}
+
+	for _, serv := range servs {
+		servName := pbinfo.ReduceServName(serv.GetName(), pkgName)
+		for _, t := range g.transports {
+			libClient := servName + "Client"
+			if t == restTransport {
+				libClient = servName + "RESTClient"
+			}
+			md.client(serv.GetName(), t, libClient)
+
+			for _, m := range g.clientMethods(serv) {
+				mServ := serv.GetName()
+				if fqn, ok := mixinServs[m]; ok {
+					mServ = fqn
+				}
+				cm := md.client(mServ, t, libClient)
+				if cm.LibraryClient != libClient {
+					continue
+				}
+
+				methods := []string{m.GetName()}
+				flats, err := g.flattenings(serv, m)
+				if err != nil {
+					return nil, errors.E(err, "method: %s", m.GetName())
+				}
+				for _, fl := range flats {
+					methods = append(methods, fl.name)
+				}
+				cm.RPCs[m.GetName()] = &rpcMetadata{Methods: methods}
+			}
+		}
+	}
+
+	b, err := json.MarshalIndent(md, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
@@ -0,0 +1,119 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gengapic
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/googleapis/gapic-generator-go/internal/txtdiff"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/genproto/googleapis/api/serviceconfig"
+	apipb "google.golang.org/genproto/protobuf/api"
+)
+
+func TestGapicMetadata(t *testing.T) {
+	sigOpts := &descriptor.MethodOptions{}
+	if err := proto.SetExtension(sigOpts, annotations.E_MethodSignature, []string{"name"}); err != nil {
+		t.Fatal(err)
+	}
+
+	file := &descriptor.FileDescriptorProto{
+		Package: proto.String("my.pkg"),
+		Options: &descriptor.FileOptions{GoPackage: proto.String("mypackage")},
+		MessageType: []*descriptor.DescriptorProto{
+			{
+				Name: proto.String("InputType"),
+				Field: []*descriptor.FieldDescriptorProto{
+					{
+						Name:  proto.String("name"),
+						Type:  descriptor.FieldDescriptorProto_TYPE_STRING.Enum(),
+						Label: descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+					},
+				},
+			},
+			{Name: proto.String("OutputType")},
+		},
+		Service: []*descriptor.ServiceDescriptorProto{
+			{
+				Name: proto.String("FooService"),
+				Method: []*descriptor.MethodDescriptorProto{
+					{
+						Name:       proto.String("GetThing"),
+						InputType:  proto.String(".my.pkg.InputType"),
+						OutputType: proto.String(".my.pkg.OutputType"),
+						Options:    sigOpts,
+					},
+					{
+						Name:       proto.String("ListThings"),
+						InputType:  proto.String(".my.pkg.InputType"),
+						OutputType: proto.String(".my.pkg.OutputType"),
+					},
+				},
+			},
+			{
+				Name: proto.String("BarService"),
+				Method: []*descriptor.MethodDescriptorProto{
+					{
+						Name:       proto.String("Zip"),
+						InputType:  proto.String(".my.pkg.InputType"),
+						OutputType: proto.String(".my.pkg.OutputType"),
+					},
+				},
+			},
+		},
+	}
+
+	var g generator
+	g.init([]*descriptor.FileDescriptorProto{file})
+
+	for _, tst := range []struct {
+		name       string
+		transports []string
+	}{
+		{name: "gapic_metadata", transports: []string{grpcTransport}},
+		{name: "gapic_metadata_rest", transports: []string{grpcTransport, restTransport}},
+	} {
+		g.transports = tst.transports
+		got, err := g.genGapicMetadata(file.GetService(), "my.pkg", "github.com/googleapis/mypackage", "foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		txtdiff.Diff(t, tst.name, string(got), filepath.Join("testdata", tst.name+".want"))
+	}
+
+	// The methods of the Locations mixin are added to both clients, and listed under
+	// the mixin service with the first.
+	g.serviceConfig = &serviceconfig.Service{
+		Apis: []*apipb.Api{{Name: "google.cloud.location.Locations"}},
+	}
+	files := []*descriptor.FileDescriptorProto{file}
+	mixinFiles, err := loadMixinFiles(g.mixinNames(), files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.init(append(files, mixinFiles...))
+	if err := g.collectMixins(); err != nil {
+		t.Fatal(err)
+	}
+	g.transports = []string{grpcTransport}
+	got, err := g.genGapicMetadata(file.GetService(), "my.pkg", "github.com/googleapis/mypackage", "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	txtdiff.Diff(t, "gapic_metadata_mixin", string(got), filepath.Join("testdata", "gapic_metadata_mixin.want"))
+}
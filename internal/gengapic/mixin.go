@@ -0,0 +1,202 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gengapic
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/googleapis/gapic-generator-go/internal/errors"
+
+	// Register the descriptors of the mixin APIs, in case the request does not include them.
+	_ "google.golang.org/genproto/googleapis/cloud/location"
+	_ "google.golang.org/genproto/googleapis/iam/v1"
+	_ "google.golang.org/genproto/googleapis/longrunning"
+)
+
+// mixinAPIs are the APIs whose methods are added to the clients of an API that lists them
+// in the apis of its service config, by fully-qualified service name.
+var mixinAPIs = []struct {
+	name, file, field string
+}{
+	{"google.cloud.location.Locations", "google/cloud/location/locations.proto", "locationsClient"},
+	{"google.iam.v1.IAMPolicy", "google/iam/v1/iam_policy.proto", "iamPolicyClient"},
+	{"google.longrunning.Operations", "google/longrunning/operations.proto", "operationsClient"},
+}
+
+// mixin is a mixin API used by the clients, and its methods.
+// The methods are copies of those of serv, so that they are distinct from its methods
+// when serv is also generated.
+type mixin struct {
+	serv    *descriptor.ServiceDescriptorProto
+	field   string
+	methods []*descriptor.MethodDescriptorProto
+}
+
+// loadMixinFiles returns the files declaring the mixin APIs listed in the service config,
+// and their dependencies, that are missing from files. They are loaded from the descriptors
+// registered by their Go packages.
+func loadMixinFiles(apis []string, files []*descriptor.FileDescriptorProto) ([]*descriptor.FileDescriptorProto, error) {
+	seen := map[string]bool{}
+	for _, f := range files {
+		seen[f.GetName()] = true
+	}
+
+	var loaded []*descriptor.FileDescriptorProto
+	var load func(name string) error
+	load = func(name string) error {
+		if seen[name] {
+			return nil
+		}
+		seen[name] = true
+
+		gz := proto.FileDescriptor(name)
+		if gz == nil {
+			return errors.E(nil, "descriptor of %s is not registered", name)
+		}
+		r, err := gzip.NewReader(bytes.NewReader(gz))
+		if err != nil {
+			return err
+		}
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		f := &descriptor.FileDescriptorProto{}
+		if err := proto.Unmarshal(b, f); err != nil {
+			return err
+		}
+		for _, dep := range f.GetDependency() {
+			if err := load(dep); err != nil {
+				return err
+			}
+		}
+		loaded = append(loaded, f)
+		return nil
+	}
+
+	for _, api := range apis {
+		for _, mx := range mixinAPIs {
+			if mx.name != api {
+				continue
+			}
+			if err := load(mx.file); err != nil {
+				return nil, errors.E(err, "can't load mixin API %s", api)
+			}
+		}
+	}
+	return loaded, nil
+}
+
+// collectMixins sets g.mixins to the mixin APIs listed in the service config.
+// It must be called after g.init, with the files returned by loadMixinFiles,
+// and after applyServiceConfig, so that the methods are copied with their http rules.
+func (g *generator) collectMixins() error {
+	g.mixins = nil
+	g.mixinFields = map[*descriptor.MethodDescriptorProto]string{}
+
+	for _, api := range g.mixinNames() {
+		for _, mx := range mixinAPIs {
+			if mx.name != api {
+				continue
+			}
+			serv := g.descInfo.Serv["."+api]
+			if serv == nil {
+				return errors.E(nil, "can't find mixin API %s", api)
+			}
+
+			mix := mixin{serv: serv, field: mx.field}
+			for _, m := range serv.GetMethod() {
+				mm := proto.Clone(m).(*descriptor.MethodDescriptorProto)
+				g.comments[mm] = g.comments[m]
+				if d, ok := g.deprecations[m]; ok {
+					g.deprecations[mm] = d
+				}
+				g.mixinFields[mm] = mx.field
+				mix.methods = append(mix.methods, mm)
+			}
+			g.mixins = append(g.mixins, mix)
+		}
+	}
+	return nil
+}
+
+// clientMixins reports the mixins whose methods are added to the client of serv.
+func (g *generator) clientMixins(serv *descriptor.ServiceDescriptorProto) []mixin {
+	var mixins []mixin
+	for _, mix := range g.mixins {
+		if ms := g.mixinMethods(serv, mix); len(ms) > 0 {
+			mixins = append(mixins, mix)
+		}
+	}
+	return mixins
+}
+
+// mixinMethods reports the methods of mix added to the client of serv. Methods whose name
+// is taken by a method of serv or its "FooOperation" type are left out, as are all methods
+// if serv is the mixin itself.
+func (g *generator) mixinMethods(serv *descriptor.ServiceDescriptorProto, mix mixin) []*descriptor.MethodDescriptorProto {
+	if g.descInfo.ParentFile[serv].GetPackage() == g.descInfo.ParentFile[mix.serv].GetPackage() &&
+		serv.GetName() == mix.serv.GetName() {
+		return nil
+	}
+
+	taken := map[string]bool{}
+	for _, m := range serv.GetMethod() {
+		taken[m.GetName()] = true
+		taken[lroTypeName(m.GetName())] = true
+	}
+	var methods []*descriptor.MethodDescriptorProto
+	for _, m := range mix.methods {
+		if !taken[m.GetName()] {
+			methods = append(methods, m)
+		}
+	}
+	return methods
+}
+
+// clientMethods reports the methods of the client of serv: those of serv, followed by
+// those of the mixins listed in the service config.
+func (g *generator) clientMethods(serv *descriptor.ServiceDescriptorProto) []*descriptor.MethodDescriptorProto {
+	methods := serv.GetMethod()
+	if len(g.mixins) == 0 {
+		return methods
+	}
+	methods = append([]*descriptor.MethodDescriptorProto(nil), methods...)
+	for _, mix := range g.mixins {
+		methods = append(methods, g.mixinMethods(serv, mix)...)
+	}
+	return methods
+}
+
+// methodClientField reports the name of the field of the client of the reduced service servName
+// holding the gRPC client that m is called with: the client of the service, or of the mixin of m.
+func (g *generator) methodClientField(servName string, m *descriptor.MethodDescriptorProto) string {
+	if field, ok := g.mixinFields[m]; ok {
+		return field
+	}
+	return grpcClientField(servName)
+}
+
+// isLRO reports whether m is a long-running method, whose google.longrunning.Operation
+// is wrapped in a "FooOperation". The methods of the google.longrunning.Operations mixin
+// return the operations themselves.
+func (g *generator) isLRO(m *descriptor.MethodDescriptorProto) bool {
+	_, isMixin := g.mixinFields[m]
+	return m.GetOutputType() == lroType && !isMixin
+}
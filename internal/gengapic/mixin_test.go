@@ -0,0 +1,149 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gengapic
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/google/go-cmp/cmp"
+	"github.com/googleapis/gapic-generator-go/internal/txtdiff"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/genproto/googleapis/api/serviceconfig"
+	apipb "google.golang.org/genproto/protobuf/api"
+)
+
+func TestMixins(t *testing.T) {
+	serv := &descriptor.ServiceDescriptorProto{
+		Name: proto.String("Foo"),
+		Method: []*descriptor.MethodDescriptorProto{
+			{Name: proto.String("Zip"), InputType: proto.String(".my.pkg.Req"), OutputType: proto.String(".google.longrunning.Operation")},
+			// Shadows the method of the Locations mixin.
+			{Name: proto.String("GetLocation"), InputType: proto.String(".my.pkg.Req"), OutputType: proto.String(".my.pkg.Req")},
+			// Its "WaitOperation" name shadows the method of the Operations mixin.
+			{Name: proto.String("Wait"), InputType: proto.String(".my.pkg.Req"), OutputType: proto.String(".google.longrunning.Operation")},
+		},
+	}
+	file := &descriptor.FileDescriptorProto{
+		Name:        proto.String("my/pkg/foo.proto"),
+		Package:     proto.String("my.pkg"),
+		Options:     &descriptor.FileOptions{GoPackage: proto.String("github.com/googleapis/mypackage")},
+		MessageType: []*descriptor.DescriptorProto{{Name: proto.String("Req")}},
+		Service:     []*descriptor.ServiceDescriptorProto{serv},
+	}
+
+	var g generator
+	g.apiName = "Awesome Foo API"
+	g.serviceConfig = &serviceconfig.Service{
+		Apis: []*apipb.Api{
+			{Name: "my.pkg.Foo"},
+			{Name: "google.cloud.location.Locations"},
+			{Name: "google.iam.v1.IAMPolicy"},
+			{Name: "google.longrunning.Operations"},
+		},
+		Http: &annotations.Http{
+			Rules: []*annotations.HttpRule{
+				{
+					Selector: "google.iam.v1.IAMPolicy.GetIamPolicy",
+					Pattern:  &annotations.HttpRule_Post{Post: "/v1/{resource=projects/*/foos/*}:getIamPolicy"},
+					Body:     "*",
+					AdditionalBindings: []*annotations.HttpRule{
+						{Pattern: &annotations.HttpRule_Post{Post: "/v1/{resource=projects/*/bars/*}:getIamPolicy"}, Body: "*"},
+					},
+				},
+			},
+		},
+	}
+
+	files := []*descriptor.FileDescriptorProto{file}
+	mixinFiles, err := loadMixinFiles(g.mixinNames(), files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.init(append(files, mixinFiles...))
+	if err := g.applyServiceConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.collectMixins(); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, m := range g.clientMethods(serv) {
+		got = append(got, m.GetName())
+	}
+	want := []string{
+		"Zip",
+		"GetLocation",
+		"Wait",
+		"ListLocations",
+		"SetIamPolicy",
+		"GetIamPolicy",
+		"TestIamPermissions",
+		"ListOperations",
+		"GetOperation",
+		"DeleteOperation",
+		"CancelOperation",
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("clientMethods got(-),want(+):\n%s", diff)
+	}
+
+	for _, m := range g.clientMethods(serv) {
+		var wantField string
+		wantLRO := false
+		switch m.GetName() {
+		case "Zip", "Wait":
+			wantField, wantLRO = "fooClient", true
+		case "GetLocation":
+			wantField = "fooClient"
+		case "ListLocations":
+			wantField = "locationsClient"
+		case "SetIamPolicy", "GetIamPolicy", "TestIamPermissions":
+			wantField = "iamPolicyClient"
+		default:
+			wantField = "operationsClient"
+		}
+		if got := g.methodClientField("Foo", m); got != wantField {
+			t.Errorf("methodClientField(%s) = %q, want %q", m.GetName(), got, wantField)
+		}
+		if got := g.isLRO(m); got != wantLRO {
+			t.Errorf("isLRO(%s) = %t, want %t", m.GetName(), got, wantLRO)
+		}
+
+		if m.GetName() != "GetIamPolicy" {
+			continue
+		}
+		eHTTP, err := proto.GetExtension(m.GetOptions(), annotations.E_Http)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rule := eHTTP.(*annotations.HttpRule)
+		if got, want := rule.GetPost(), "/v1/{resource=projects/*/foos/*}:getIamPolicy"; got != want {
+			t.Errorf("GetIamPolicy http rule: got %q, want %q", got, want)
+		}
+		if got := len(rule.GetAdditionalBindings()); got != 1 {
+			t.Errorf("GetIamPolicy http rule: got %d additional bindings, want 1", got)
+		}
+	}
+
+	g.reset()
+	if err := g.clientInit(serv, "Foo"); err != nil {
+		t.Fatal(err)
+	}
+	txtdiff.Diff(t, "mixin_client_init", g.pt.String(), filepath.Join("testdata", "mixin_client_init.want"))
+}
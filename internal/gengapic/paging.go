@@ -16,7 +16,6 @@ package gengapic
 
 import (
 	"fmt"
-	"log"
 
 	"github.com/golang/protobuf/protoc-gen-go/descriptor"
 	"github.com/googleapis/gapic-generator-go/internal/errors"
@@ -27,11 +26,16 @@ import (
 type iterType struct {
 	iterTypeName, elemTypeName string
 
-	// If the elem type is a message, elemImports contains pbinfo.ImportSpec for the type.
+	// If the elem type is a message or an enum, elemImports contains pbinfo.ImportSpec for the type.
 	// Otherwise, len(elemImports)==0.
 	elemImports []pbinfo.ImportSpec
 
-	generated bool
+	// If the iterated field is a map, the elements are of type elemTypeName,
+	// a struct holding a key of type keyTypeName and a value of type valueTypeName.
+	keyTypeName, valueTypeName string
+
+	// Whether the message or enum of the elements, or of the values of a map, is deprecated.
+	deprecated bool
 }
 
 // iterTypeOf deduces iterType from a field to be iterated over.
@@ -39,16 +43,90 @@ type iterType struct {
 func (g *generator) iterTypeOf(elemField *descriptor.FieldDescriptorProto) (*iterType, error) {
 	var pt iterType
 
-	switch t := *elemField.Type; {
-	case t == descriptor.FieldDescriptorProto_TYPE_MESSAGE:
-		eType := g.descInfo.Type[elemField.GetTypeName()]
+	if entry := g.mapEntry(elemField); entry != nil {
+		key, keyName, _, err := g.iterElem(fieldByName(entry, "key"))
+		if err != nil {
+			return &iterType{}, err
+		}
+		val, valName, valImports, err := g.iterElem(fieldByName(entry, "value"))
+		if err != nil {
+			return &iterType{}, err
+		}
 
-		imp, err := g.descInfo.ImportSpec(eType)
+		pairName := valName + "Pair"
+		if key != "string" {
+			pairName = keyName + pairName
+		}
+		pt.elemTypeName = pairName
+		pt.iterTypeName = pairName + "Iterator"
+		pt.elemImports = valImports
+		pt.keyTypeName, pt.valueTypeName = key, val
+		pt.deprecated = deprecated(g.descInfo.Type[fieldByName(entry, "value").GetTypeName()])
+	} else {
+		elem, name, imports, err := g.iterElem(elemField)
 		if err != nil {
 			return &iterType{}, err
 		}
+		pt.elemTypeName = elem
+		pt.iterTypeName = name + "Iterator"
+		pt.elemImports = imports
+		pt.deprecated = deprecated(g.descInfo.Type[elemField.GetTypeName()])
+	}
+
+	if iter, ok := g.aux.iters[pt.iterTypeName]; ok {
+		// The names of iterators and map pairs are made of the names of their types,
+		// which may be the same for different types, e.g. those of map<string, Foo>
+		// and of a FooPair message, or messages of the same name in different packages.
+		if iter.elemTypeName != pt.elemTypeName || iter.keyTypeName != pt.keyTypeName || iter.valueTypeName != pt.valueTypeName {
+			return &iterType{}, errors.E(nil, "iterators over %s and %s would both be named %s", iter.elemDesc(), pt.elemDesc(), pt.iterTypeName)
+		}
+		return iter, nil
+	}
+	g.aux.iters[pt.iterTypeName] = &pt
+
+	return &pt, nil
+}
+
+// elemDesc describes the elements iterated over by iterators of type t.
+func (t *iterType) elemDesc() string {
+	if t.valueTypeName != "" {
+		return fmt.Sprintf("map[%s]%s", t.keyTypeName, t.valueTypeName)
+	}
+	return t.elemTypeName
+}
+
+// mapEntry reports the map entry message of f, or nil if f is not a map field.
+func (g *generator) mapEntry(f *descriptor.FieldDescriptorProto) *descriptor.DescriptorProto {
+	if f.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+		return nil
+	}
+	msg, ok := g.descInfo.Type[f.GetTypeName()].(*descriptor.DescriptorProto)
+	if !ok || !msg.GetOptions().GetMapEntry() {
+		return nil
+	}
+	return msg
+}
+
+// iterElem reports the Go type of a single value of field f, the name of that type
+// to use in the names of iterators, e.g. "Foo" for "*foopb.Foo" or "Int64" for "int64",
+// and the imports the type needs.
+func (g *generator) iterElem(f *descriptor.FieldDescriptorProto) (string, string, []pbinfo.ImportSpec, error) {
+	switch t := f.GetType(); t {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_ENUM:
+		eType := g.descInfo.Type[f.GetTypeName()]
+		if eType == nil {
+			return "", "", nil, errors.E(nil, "cannot find type %q, malformed descriptor?", f.GetTypeName())
+		}
+		if g.mapEntry(f) != nil {
+			return "", "", nil, errors.E(nil, "cannot iterate over map values of field %s that are maps", f.GetName())
+		}
+
+		imp, err := g.descInfo.ImportSpec(eType)
+		if err != nil {
+			return "", "", nil, err
+		}
 
-		// Prepend parent Message name for nested Messages
+		// Prepend parent Message name for nested Messages and Enums
 		// to match the generated Go type name.
 		typ := eType
 		typeName := typ.GetName()
@@ -57,33 +135,22 @@ func (g *generator) iterTypeOf(elemField *descriptor.FieldDescriptorProto) (*ite
 			typ = parent
 		}
 
-		pt.elemTypeName = fmt.Sprintf("*%s.%s", imp.Name, typeName)
-		pt.iterTypeName = typeName + "Iterator"
-
-		pt.elemImports = []pbinfo.ImportSpec{imp}
-
-	case t == descriptor.FieldDescriptorProto_TYPE_ENUM:
-		log.Panic("iterating enum not supported yet")
+		elem := fmt.Sprintf("%s.%s", imp.Name, typeName)
+		if t == descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+			elem = "*" + elem
+		}
+		return elem, typeName, []pbinfo.ImportSpec{imp}, nil
 
-	case t == descriptor.FieldDescriptorProto_TYPE_BYTES:
-		pt.elemTypeName = "[]byte"
-		pt.iterTypeName = "BytesIterator"
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return "[]byte", "Bytes", nil, nil
 
 	default:
 		pType := pbinfo.GoTypeForPrim[t]
 		if pType == "" {
-			log.Panicf("unrecognized type: %v", t)
+			return "", "", nil, errors.E(nil, "unrecognized type of field %s: %v", f.GetName(), t)
 		}
-		pt.elemTypeName = pType
-		pt.iterTypeName = upperFirst(pt.elemTypeName) + "Iterator"
+		return pType, upperFirst(pType), nil, nil
 	}
-
-	if iter, ok := g.aux.iters[pt.iterTypeName]; ok {
-		return iter, nil
-	}
-	g.aux.iters[pt.iterTypeName] = &pt
-
-	return &pt, nil
 }
 
 // TODO(pongad): this will probably need to read from annotations later.
@@ -177,7 +244,18 @@ func (g *generator) pagingCall(servName string, m *descriptor.MethodDescriptorPr
 
 	p("it := &%s{}", pt.iterTypeName)
 	p("req = proto.Clone(req).(*%s.%s)", inSpec.Name, inType.GetName())
+	if err := g.clearOutputOnly(m); err != nil {
+		return err
+	}
 	p("it.InternalFetch = func(pageSize int, pageToken string) ([]%s, string, error) {", pt.elemTypeName)
+	if err := g.validateRequest(m, `nil, "", err`); err != nil {
+		return err
+	}
+	if _, ok := g.timeouts[m]; ok {
+		// Each page gets the timeout, and the ctx of the other pages is left alone.
+		p("ctx := ctx")
+		g.applyTimeout(m)
+	}
 	p("  var resp *%s.%s", outSpec.Name, outType.GetName())
 	p("  req.PageToken = pageToken")
 	p("  if pageSize > math.MaxInt32 {")
@@ -185,17 +263,27 @@ func (g *generator) pagingCall(servName string, m *descriptor.MethodDescriptorPr
 	p("  } else {")
 	p("    req.PageSize = int32(pageSize)")
 	p("  }")
-	p("  err := gax.Invoke(ctx, func(ctx context.Context, settings gax.CallSettings) error {")
-	p("    var err error")
-	p("    resp, err = %s", grpcClientCall(servName, *m.Name))
-	p("    return err")
-	p("  }, opts...)")
-	p("  if err != nil {")
-	p("    return nil, \"\", err")
-	p("  }")
+	if g.hedged[m] {
+		p("res, err := invokeHedged(ctx, opts, func(ctx context.Context, settings gax.CallSettings) (interface{}, error) {")
+		p("  return %s", g.grpcClientCall(servName, m))
+		p("})")
+		p("if err != nil {")
+		p("  return nil, \"\", err")
+		p("}")
+		p("resp = res.(*%s.%s)", outSpec.Name, outType.GetName())
+	} else {
+		p("  err := gax.Invoke(ctx, func(ctx context.Context, settings gax.CallSettings) error {")
+		p("    var err error")
+		p("    resp, err = %s", g.grpcClientCall(servName, m))
+		p("    return err")
+		p("  }, opts...)")
+		p("  if err != nil {")
+		p("    return nil, \"\", err")
+		p("  }")
+	}
 	p("")
 	p("  it.Response = resp")
-	p("  return resp.%s, resp.NextPageToken, nil", snakeToCamel(*elemField.Name))
+	g.pagingResults(elemField, pt)
 	p("}")
 
 	p("fetch := func(pageSize int, pageToken string) (string, error) {")
@@ -225,10 +313,49 @@ func (g *generator) pagingCall(servName string, m *descriptor.MethodDescriptorPr
 	return nil
 }
 
+// pagingResults prints the statements returning the results of a page from resp,
+// the response of a paging call, in InternalFetch.
+// Map entries are returned sorted by key, so that iterating over them is deterministic.
+func (g *generator) pagingResults(elemField *descriptor.FieldDescriptorProto, pt *iterType) {
+	p := g.printf
+	field := snakeToCamel(elemField.GetName())
+
+	if pt.keyTypeName == "" {
+		p("  return resp.%s, resp.NextPageToken, nil", field)
+		return
+	}
+
+	less := "elems[i].Key < elems[j].Key"
+	if pt.keyTypeName == "bool" {
+		less = "!elems[i].Key && elems[j].Key"
+	}
+	p("  elems := make([]%s, 0, len(resp.%s))", pt.elemTypeName, field)
+	p("  for k, v := range resp.%s {", field)
+	p("    elems = append(elems, %s{k, v})", pt.elemTypeName)
+	p("  }")
+	p("  sort.Slice(elems, func(i, j int) bool { return %s })", less)
+	p("  return elems, resp.NextPageToken, nil")
+
+	g.imports[pbinfo.ImportSpec{Path: "sort"}] = true
+}
+
 func (g *generator) pagingIter(pt *iterType) {
 	p := g.printf
 
+	if pt.keyTypeName != "" {
+		p("// %s is a holder type for %s/%s map entries.", pt.elemTypeName, pt.keyTypeName, pt.valueTypeName)
+		p("type %s struct {", pt.elemTypeName)
+		p("  Key %s", pt.keyTypeName)
+		p("  Value %s", pt.valueTypeName)
+		p("}")
+		p("")
+	}
+
 	p("// %s manages a stream of %s.", pt.iterTypeName, pt.elemTypeName)
+	if pt.deprecated {
+		p("//")
+		g.deprecationParagraph("", pt.iterTypeName)
+	}
 	p("type %s struct {", pt.iterTypeName)
 	p("  items    []%s", pt.elemTypeName)
 	p("  pageInfo *iterator.PageInfo")
@@ -279,4 +406,9 @@ func (g *generator) pagingIter(pt *iterType) {
 	p("  return b")
 	p("}")
 	p("")
+
+	g.imports[pbinfo.ImportSpec{Path: "google.golang.org/api/iterator"}] = true
+	for _, spec := range pt.elemImports {
+		g.imports[spec] = true
+	}
 }
@@ -158,21 +158,56 @@ func TestIterTypeOf(t *testing.T) {
 	msgType := &descriptor.DescriptorProto{
 		Name: proto.String("Foo"),
 	}
+	enumType := &descriptor.EnumDescriptorProto{
+		Name: proto.String("Kind"),
+	}
+	deprecatedType := &descriptor.DescriptorProto{
+		Name:    proto.String("OldFoo"),
+		Options: &descriptor.MessageOptions{Deprecated: proto.Bool(true)},
+	}
+	mapEntry := func(name string, key, val *descriptor.FieldDescriptorProto) *descriptor.DescriptorProto {
+		key.Name = proto.String("key")
+		val.Name = proto.String("value")
+		return &descriptor.DescriptorProto{
+			Name:    proto.String(name),
+			Field:   []*descriptor.FieldDescriptorProto{key, val},
+			Options: &descriptor.MessageOptions{MapEntry: proto.Bool(true)},
+		}
+	}
+	labelsEntry := mapEntry("LabelsEntry",
+		&descriptor.FieldDescriptorProto{Type: typep(descriptor.FieldDescriptorProto_TYPE_STRING)},
+		&descriptor.FieldDescriptorProto{Type: typep(descriptor.FieldDescriptorProto_TYPE_INT64)})
+	foosEntry := mapEntry("FoosEntry",
+		&descriptor.FieldDescriptorProto{Type: typep(descriptor.FieldDescriptorProto_TYPE_INT32)},
+		&descriptor.FieldDescriptorProto{Type: typep(descriptor.FieldDescriptorProto_TYPE_MESSAGE), TypeName: proto.String(msgType.GetName())})
 	g := &generator{
 		aux: &auxTypes{
 			iters: map[string]*iterType{},
 		},
 		descInfo: pbinfo.Info{
 			Type: map[string]pbinfo.ProtoType{
-				msgType.GetName(): msgType,
+				msgType.GetName():        msgType,
+				deprecatedType.GetName(): deprecatedType,
+				"Foo.Kind":               enumType,
+				"Foo.LabelsEntry":        labelsEntry,
+				"Foo.FoosEntry":          foosEntry,
+			},
+			ParentElement: map[pbinfo.ProtoType]pbinfo.ProtoType{
+				enumType:    msgType,
+				labelsEntry: msgType,
+				foosEntry:   msgType,
 			},
-			ParentElement: map[pbinfo.ProtoType]pbinfo.ProtoType{},
 			ParentFile: map[proto.Message]*descriptor.FileDescriptorProto{
 				msgType: &descriptor.FileDescriptorProto{
 					Options: &descriptor.FileOptions{
 						GoPackage: proto.String("path/to/foo;foo"),
 					},
 				},
+				deprecatedType: &descriptor.FileDescriptorProto{
+					Options: &descriptor.FileOptions{
+						GoPackage: proto.String("path/to/foo;foo"),
+					},
+				},
 			},
 		},
 	}
@@ -210,6 +245,54 @@ func TestIterTypeOf(t *testing.T) {
 				elemImports:  []pbinfo.ImportSpec{{Name: "foopb", Path: "path/to/foo"}},
 			},
 		},
+		{
+			field: &descriptor.FieldDescriptorProto{
+				Type:     typep(descriptor.FieldDescriptorProto_TYPE_ENUM),
+				TypeName: proto.String("Foo.Kind"),
+			},
+			want: iterType{
+				iterTypeName: "Foo_KindIterator",
+				elemTypeName: "foopb.Foo_Kind",
+				elemImports:  []pbinfo.ImportSpec{{Name: "foopb", Path: "path/to/foo"}},
+			},
+		},
+		{
+			field: &descriptor.FieldDescriptorProto{
+				Type:     typep(descriptor.FieldDescriptorProto_TYPE_MESSAGE),
+				TypeName: proto.String("Foo.LabelsEntry"),
+			},
+			want: iterType{
+				iterTypeName:  "Int64PairIterator",
+				elemTypeName:  "Int64Pair",
+				keyTypeName:   "string",
+				valueTypeName: "int64",
+			},
+		},
+		{
+			field: &descriptor.FieldDescriptorProto{
+				Type:     typep(descriptor.FieldDescriptorProto_TYPE_MESSAGE),
+				TypeName: proto.String("Foo.FoosEntry"),
+			},
+			want: iterType{
+				iterTypeName:  "Int32FooPairIterator",
+				elemTypeName:  "Int32FooPair",
+				elemImports:   []pbinfo.ImportSpec{{Name: "foopb", Path: "path/to/foo"}},
+				keyTypeName:   "int32",
+				valueTypeName: "*foopb.Foo",
+			},
+		},
+		{
+			field: &descriptor.FieldDescriptorProto{
+				Type:     typep(descriptor.FieldDescriptorProto_TYPE_MESSAGE),
+				TypeName: proto.String(deprecatedType.GetName()),
+			},
+			want: iterType{
+				iterTypeName: "OldFooIterator",
+				elemTypeName: "*foopb.OldFoo",
+				elemImports:  []pbinfo.ImportSpec{{Name: "foopb", Path: "path/to/foo"}},
+				deprecated:   true,
+			},
+		},
 	} {
 		g.descInfo.ParentElement[tst.field] = msgType
 		got, err := g.iterTypeOf(tst.field)
@@ -220,3 +303,82 @@ func TestIterTypeOf(t *testing.T) {
 		}
 	}
 }
+
+func TestIterTypeOf_collision(t *testing.T) {
+	book := &descriptor.DescriptorProto{Name: proto.String("Book")}
+	bookPair := &descriptor.DescriptorProto{Name: proto.String("BookPair")}
+	otherBook := &descriptor.DescriptorProto{Name: proto.String("Book")}
+	mapEntry := func(val string) *descriptor.DescriptorProto {
+		return &descriptor.DescriptorProto{
+			Name: proto.String("BooksEntry"),
+			Field: []*descriptor.FieldDescriptorProto{
+				{Name: proto.String("key"), Type: descriptor.FieldDescriptorProto_TYPE_STRING.Enum()},
+				{Name: proto.String("value"), Type: descriptor.FieldDescriptorProto_TYPE_MESSAGE.Enum(), TypeName: proto.String(val)},
+			},
+			Options: &descriptor.MessageOptions{MapEntry: proto.Bool(true)},
+		}
+	}
+	booksEntry := mapEntry(".foo.Book")
+	otherBooksEntry := mapEntry(".bar.Book")
+	fooFile := &descriptor.FileDescriptorProto{Options: &descriptor.FileOptions{GoPackage: proto.String("path/to/foo;foo")}}
+	barFile := &descriptor.FileDescriptorProto{Options: &descriptor.FileOptions{GoPackage: proto.String("path/to/bar;bar")}}
+
+	field := func(typeName string) *descriptor.FieldDescriptorProto {
+		return &descriptor.FieldDescriptorProto{
+			Type:     descriptor.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+			TypeName: proto.String(typeName),
+		}
+	}
+	g := &generator{
+		descInfo: pbinfo.Info{
+			Type: map[string]pbinfo.ProtoType{
+				".foo.Book":               book,
+				".foo.BookPair":           bookPair,
+				".bar.Book":               otherBook,
+				".foo.Shelf.BooksEntry":   booksEntry,
+				".bar.Library.BooksEntry": otherBooksEntry,
+			},
+			ParentFile: map[proto.Message]*descriptor.FileDescriptorProto{
+				book:      fooFile,
+				bookPair:  fooFile,
+				otherBook: barFile,
+			},
+		},
+	}
+
+	for _, tst := range []struct {
+		name   string
+		fields []*descriptor.FieldDescriptorProto
+		want   string
+	}{
+		{
+			name:   "same type",
+			fields: []*descriptor.FieldDescriptorProto{field(".foo.Shelf.BooksEntry"), field(".foo.Shelf.BooksEntry")},
+		},
+		{
+			name:   "map and pair message",
+			fields: []*descriptor.FieldDescriptorProto{field(".foo.Shelf.BooksEntry"), field(".foo.BookPair")},
+			want:   "iterators over map[string]*foopb.Book and *foopb.BookPair would both be named BookPairIterator",
+		},
+		{
+			name:   "maps of values in different packages",
+			fields: []*descriptor.FieldDescriptorProto{field(".foo.Shelf.BooksEntry"), field(".bar.Library.BooksEntry")},
+			want:   "iterators over map[string]*foopb.Book and map[string]*barpb.Book would both be named BookPairIterator",
+		},
+	} {
+		g.aux = newAuxTypes()
+		var err error
+		for _, f := range tst.fields {
+			if _, err = g.iterTypeOf(f); err != nil {
+				break
+			}
+		}
+		var got string
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tst.want {
+			t.Errorf("%s: iterTypeOf() error = %q, want %q", tst.name, got, tst.want)
+		}
+	}
+}
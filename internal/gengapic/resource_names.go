@@ -0,0 +1,297 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gengapic
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/googleapis/gapic-generator-go/internal/errors"
+	"github.com/googleapis/gapic-generator-go/internal/pbinfo"
+	"google.golang.org/genproto/googleapis/api/annotations"
+)
+
+// resourcePattern is a resource name pattern like "shelves/{shelf}/books/{book}",
+// split into its segments.
+type resourcePattern struct {
+	pattern string
+
+	// Name of the helper functions, e.g. "Book" for BookPath and ParseBookPath.
+	funcName string
+
+	// For each segment, either the literal or the variable is set.
+	literals, vars []string
+}
+
+// collectResources reports the resources the generated package needs helpers for:
+// those annotated with google.api.resource or google.api.resource_definition in files,
+// and those referenced by google.api.resource_reference from messages in files.
+// References are resolved against all files in the request.
+func collectResources(files, allFiles []*descriptor.FileDescriptorProto) ([]*annotations.ResourceDescriptor, error) {
+	defined := map[string]*annotations.ResourceDescriptor{}
+	var refs []string
+
+	for _, f := range allFiles {
+		gen := false
+		for _, gf := range files {
+			gen = gen || gf == f
+		}
+
+		var defs []*annotations.ResourceDescriptor
+		if f.GetOptions() != nil {
+			eDefs, err := proto.GetExtension(f.GetOptions(), annotations.E_ResourceDefinition)
+			if err != nil && err != proto.ErrMissingExtension {
+				return nil, err
+			}
+			if err == nil {
+				defs = eDefs.([]*annotations.ResourceDescriptor)
+			}
+		}
+
+		var walk func(msgs []*descriptor.DescriptorProto) error
+		walk = func(msgs []*descriptor.DescriptorProto) error {
+			for _, m := range msgs {
+				if m.GetOptions() != nil {
+					eRes, err := proto.GetExtension(m.GetOptions(), annotations.E_Resource)
+					if err != nil && err != proto.ErrMissingExtension {
+						return err
+					}
+					if err == nil {
+						defs = append(defs, eRes.(*annotations.ResourceDescriptor))
+					}
+				}
+
+				if gen {
+					for _, fld := range m.GetField() {
+						if fld.GetOptions() == nil {
+							continue
+						}
+						eRef, err := proto.GetExtension(fld.GetOptions(), annotations.E_ResourceReference)
+						if err == proto.ErrMissingExtension {
+							continue
+						} else if err != nil {
+							return err
+						}
+						// TODO: support child_type, which refers to the parent of a resource.
+						if t := eRef.(*annotations.ResourceReference).GetType(); t != "" && t != "*" {
+							refs = append(refs, t)
+						}
+					}
+				}
+
+				if err := walk(m.GetNestedType()); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if err := walk(f.GetMessageType()); err != nil {
+			return nil, err
+		}
+
+		for _, d := range defs {
+			if d.GetType() == "" {
+				return nil, errors.E(nil, "resource without type in %s", f.GetName())
+			}
+			if gen {
+				refs = append(refs, d.GetType())
+			}
+			if _, ok := defined[d.GetType()]; !ok {
+				defined[d.GetType()] = d
+			}
+		}
+	}
+
+	seen := map[string]bool{}
+	var res []*annotations.ResourceDescriptor
+	for _, t := range refs {
+		d, ok := defined[t]
+		if !ok || seen[t] {
+			continue
+		}
+		seen[t] = true
+		res = append(res, d)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].GetType() < res[j].GetType()
+	})
+	return res, nil
+}
+
+// resourcePatterns reports the patterns of res to generate helpers for.
+// Patterns that are not made of literals and single-segment variables, like
+// "projects/{project}/books/{book=**}", are skipped, as are patterns without variables.
+//
+// If res has a single pattern, its helpers are named after the resource, e.g. BookPath.
+// Otherwise they are named after the variables of each pattern,
+// e.g. ProjectBookPath for "projects/{project}/books/{book}" or
+// "projects/{project_id}/books/{book_id}".
+// A resource that ORIGINALLY_SINGLE_PATTERN keeps the resource name for its first pattern.
+func resourcePatterns(res *annotations.ResourceDescriptor) []resourcePattern {
+	typ := res.GetType()
+	resName := typ[strings.LastIndexByte(typ, '/')+1:]
+
+	var pats []resourcePattern
+	seen := map[string]bool{}
+	for i, p := range res.GetPattern() {
+		pat, ok := parseResourcePattern(p)
+		if !ok || len(resourceParams(pat)) == 0 {
+			continue
+		}
+
+		switch {
+		case len(res.GetPattern()) == 1,
+			i == 0 && res.GetHistory() == annotations.ResourceDescriptor_ORIGINALLY_SINGLE_PATTERN:
+			pat.funcName = resName
+		default:
+			for _, v := range pat.vars {
+				if v != "" {
+					pat.funcName += snakeToCamel(strings.TrimSuffix(v, "_id"))
+				}
+			}
+		}
+		if pat.funcName == "" || seen[pat.funcName] {
+			continue
+		}
+		seen[pat.funcName] = true
+		pats = append(pats, pat)
+	}
+	return pats
+}
+
+func parseResourcePattern(p string) (resourcePattern, bool) {
+	pat := resourcePattern{pattern: p}
+	if p == "" || p == "*" {
+		return pat, false
+	}
+
+	for _, seg := range strings.Split(p, "/") {
+		switch {
+		case seg == "":
+			return pat, false
+		case seg[0] == '{' && seg[len(seg)-1] == '}':
+			v := seg[1 : len(seg)-1]
+			if v == "" || strings.ContainsAny(v, "{}=*") {
+				return pat, false
+			}
+			pat.literals = append(pat.literals, "")
+			pat.vars = append(pat.vars, v)
+		case strings.ContainsAny(seg, "{}*"):
+			return pat, false
+		default:
+			pat.literals = append(pat.literals, seg)
+			pat.vars = append(pat.vars, "")
+		}
+	}
+	return pat, true
+}
+
+// genResourceNames generates the helpers to format and parse the resource names of resources.
+// Helpers whose names collide with those of a previous resource are skipped.
+func (g *generator) genResourceNames(resources []*annotations.ResourceDescriptor) {
+	seen := map[string]bool{}
+	for _, res := range resources {
+		for _, pat := range resourcePatterns(res) {
+			if seen[pat.funcName] {
+				continue
+			}
+			seen[pat.funcName] = true
+			g.resourcePath(res, pat)
+			g.parseResourcePath(res, pat)
+		}
+	}
+}
+
+// resourceParams reports the names of the variables of pat, as Go identifiers.
+func resourceParams(pat resourcePattern) []string {
+	var params []string
+	for _, v := range pat.vars {
+		if v != "" {
+			params = append(params, goIdent(lowerFirst(snakeToCamel(v)), "name", "err", "segs"))
+		}
+	}
+	return params
+}
+
+func (g *generator) resourcePath(res *annotations.ResourceDescriptor, pat resourcePattern) {
+	p := g.printf
+	params := resourceParams(pat)
+
+	var concat []string
+	var lit strings.Builder
+	pi := 0
+	for i, l := range pat.literals {
+		if i > 0 {
+			lit.WriteByte('/')
+		}
+		if pat.vars[i] == "" {
+			lit.WriteString(l)
+			continue
+		}
+		if lit.Len() > 0 {
+			concat = append(concat, fmt.Sprintf("%q", lit.String()))
+			lit.Reset()
+		}
+		concat = append(concat, params[pi])
+		pi++
+	}
+	if lit.Len() > 0 {
+		concat = append(concat, fmt.Sprintf("%q", lit.String()))
+	}
+
+	p("// %sPath returns the name of a %s resource,", pat.funcName, res.GetType())
+	p("// in the form %q.", pat.pattern)
+	p("func %sPath(%s string) string {", pat.funcName, strings.Join(params, ", "))
+	p("  return %s", strings.Join(concat, " + "))
+	p("}")
+	p("")
+}
+
+func (g *generator) parseResourcePath(res *annotations.ResourceDescriptor, pat resourcePattern) {
+	p := g.printf
+	params := resourceParams(pat)
+
+	var conds, rets []string
+	conds = append(conds, fmt.Sprintf("len(segs) != %d", len(pat.literals)))
+	for i, l := range pat.literals {
+		if pat.vars[i] == "" {
+			conds = append(conds, fmt.Sprintf("segs[%d] != %q", i, l))
+		} else {
+			conds = append(conds, fmt.Sprintf("segs[%d] == \"\"", i))
+			rets = append(rets, fmt.Sprintf("segs[%d]", i))
+		}
+	}
+
+	results := strings.Join(params, ", ") + " string, err error"
+	errRet := fmt.Sprintf("%sfmt.Errorf(\"resource name %%q does not match pattern %%q\", name, %q)",
+		strings.Repeat(`"", `, len(params)), pat.pattern)
+
+	p("// Parse%sPath returns the IDs in name, the name of a %s", pat.funcName, res.GetType())
+	p("// resource in the form %q.", pat.pattern)
+	p("func Parse%sPath(name string) (%s) {", pat.funcName, results)
+	p("  segs := strings.Split(name, \"/\")")
+	p("  if %s {", strings.Join(conds, " || "))
+	p("    return %s", errRet)
+	p("  }")
+	p("  return %s", strings.Join(append(rets, "nil"), ", "))
+	p("}")
+	p("")
+
+	g.imports[pbinfo.ImportSpec{Path: "fmt"}] = true
+	g.imports[pbinfo.ImportSpec{Path: "strings"}] = true
+}
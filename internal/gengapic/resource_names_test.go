@@ -0,0 +1,179 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gengapic
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/google/go-cmp/cmp"
+	"github.com/googleapis/gapic-generator-go/internal/pbinfo"
+	"github.com/googleapis/gapic-generator-go/internal/txtdiff"
+	"google.golang.org/genproto/googleapis/api/annotations"
+)
+
+func TestCollectResources(t *testing.T) {
+	book := &annotations.ResourceDescriptor{
+		Type:    "library.googleapis.com/Book",
+		Pattern: []string{"shelves/{shelf}/books/{book}"},
+	}
+	shelf := &annotations.ResourceDescriptor{
+		Type:    "library.googleapis.com/Shelf",
+		Pattern: []string{"shelves/{shelf}"},
+	}
+	project := &annotations.ResourceDescriptor{
+		Type:    "cloudresourcemanager.googleapis.com/Project",
+		Pattern: []string{"projects/{project}"},
+	}
+	folder := &annotations.ResourceDescriptor{
+		Type:    "cloudresourcemanager.googleapis.com/Folder",
+		Pattern: []string{"folders/{folder}"},
+	}
+
+	msgOpts := func(res *annotations.ResourceDescriptor) *descriptor.MessageOptions {
+		opts := &descriptor.MessageOptions{}
+		if err := proto.SetExtension(opts, annotations.E_Resource, res); err != nil {
+			t.Fatal(err)
+		}
+		return opts
+	}
+	refOpts := func(typ string) *descriptor.FieldOptions {
+		opts := &descriptor.FieldOptions{}
+		if err := proto.SetExtension(opts, annotations.E_ResourceReference, &annotations.ResourceReference{Type: typ}); err != nil {
+			t.Fatal(err)
+		}
+		return opts
+	}
+	fileOpts := &descriptor.FileOptions{}
+	if err := proto.SetExtension(fileOpts, annotations.E_ResourceDefinition, []*annotations.ResourceDescriptor{shelf}); err != nil {
+		t.Fatal(err)
+	}
+
+	gen := &descriptor.FileDescriptorProto{
+		Name:    proto.String("library.proto"),
+		Options: fileOpts,
+		MessageType: []*descriptor.DescriptorProto{
+			{
+				Name:    proto.String("Book"),
+				Options: msgOpts(book),
+			},
+			{
+				Name: proto.String("ListShelvesRequest"),
+				Field: []*descriptor.FieldDescriptorProto{
+					{Name: proto.String("parent"), Options: refOpts(project.GetType())},
+					{Name: proto.String("anything"), Options: refOpts("*")},
+				},
+			},
+		},
+	}
+	common := &descriptor.FileDescriptorProto{
+		Name: proto.String("resources.proto"),
+		MessageType: []*descriptor.DescriptorProto{
+			{
+				Name:    proto.String("Folder"),
+				Options: msgOpts(folder),
+			},
+			{
+				Name:    proto.String("Project"),
+				Options: msgOpts(project),
+			},
+		},
+	}
+
+	got, err := collectResources([]*descriptor.FileDescriptorProto{gen}, []*descriptor.FileDescriptorProto{common, gen})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*annotations.ResourceDescriptor{project, book, shelf}
+	if diff := cmp.Diff(got, want, cmp.Comparer(proto.Equal)); diff != "" {
+		t.Errorf("collectResources() got(-),want(+):\n%s", diff)
+	}
+}
+
+func TestParseResourcePattern(t *testing.T) {
+	for _, tst := range []struct {
+		in             string
+		literals, vars []string
+		wantOK         bool
+	}{
+		{
+			in:       "shelves/{shelf}/books/{book}",
+			literals: []string{"shelves", "", "books", ""},
+			vars:     []string{"", "shelf", "", "book"},
+			wantOK:   true,
+		},
+		{
+			in:       "projects/{project}/settings",
+			literals: []string{"projects", "", "settings"},
+			vars:     []string{"", "project", ""},
+			wantOK:   true,
+		},
+		{in: "*"},
+		{in: "shelves/{shelf}/books/{book=**}"},
+		{in: "shelves/{shelf}~{alias}"},
+		{in: "shelves//books"},
+	} {
+		got, ok := parseResourcePattern(tst.in)
+		if ok != tst.wantOK {
+			t.Errorf("parseResourcePattern(%q) ok = %t, want %t", tst.in, ok, tst.wantOK)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if diff := cmp.Diff(got.literals, tst.literals); diff != "" {
+			t.Errorf("parseResourcePattern(%q) literals got(-),want(+):\n%s", tst.in, diff)
+		}
+		if diff := cmp.Diff(got.vars, tst.vars); diff != "" {
+			t.Errorf("parseResourcePattern(%q) vars got(-),want(+):\n%s", tst.in, diff)
+		}
+	}
+}
+
+func TestGenResourceNames(t *testing.T) {
+	var g generator
+	g.imports = map[pbinfo.ImportSpec]bool{}
+
+	g.genResourceNames([]*annotations.ResourceDescriptor{
+		{
+			Type:    "library.googleapis.com/Book",
+			Pattern: []string{"shelves/{shelf}/books/{book}"},
+		},
+		{
+			Type:    "library.googleapis.com/Settings",
+			Pattern: []string{"settings"},
+		},
+		{
+			Type:    "library.googleapis.com/Publisher",
+			Pattern: []string{"projects/{project}/publishers/{publisher}", "organizations/{organization}/publishers/{publisher}"},
+			History: annotations.ResourceDescriptor_ORIGINALLY_SINGLE_PATTERN,
+		},
+		{
+			Type: "library.googleapis.com/Author",
+			Pattern: []string{
+				"projects/{project}/authors/{author}",
+				"organizations/{organization_id}/authors/{author_id}",
+				"authors/{author=**}",
+			},
+		},
+		{
+			Type:    "library.googleapis.com/Type",
+			Pattern: []string{"types/{type}/names/{name}"},
+		},
+	})
+	txtdiff.Diff(t, "resource_names", g.pt.String(), filepath.Join("testdata", "resource_names.want"))
+}
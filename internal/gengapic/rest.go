@@ -0,0 +1,893 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gengapic
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/googleapis/gapic-generator-go/internal/errors"
+	"github.com/googleapis/gapic-generator-go/internal/pbinfo"
+	"google.golang.org/genproto/googleapis/api/annotations"
+)
+
+const (
+	grpcTransport = "grpc"
+	restTransport = "rest"
+
+	fieldMaskType = ".google.protobuf.FieldMask"
+)
+
+// pathParamRegexp matches the variables of an HTTP path template,
+// e.g. "{name=projects/*}" or "{name}", capturing the field path.
+var pathParamRegexp = regexp.MustCompile(`{([_.a-z0-9]+)(?:=[^}]*)?}`)
+
+// parseTransports parses the value of the transport plugin option,
+// a '+'-separated list of transports such as "grpc+rest".
+func parseTransports(s string) ([]string, error) {
+	if s == "" {
+		return []string{grpcTransport}, nil
+	}
+
+	var ts []string
+	seen := map[string]bool{}
+	for _, t := range strings.Split(s, "+") {
+		if t != grpcTransport && t != restTransport {
+			return nil, errors.E(nil, "unknown transport %q, want %q or %q", t, grpcTransport, restTransport)
+		}
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		ts = append(ts, t)
+	}
+
+	// LRO wrappers, streams and the example file are built on top of the gRPC client,
+	// so it cannot be omitted yet.
+	if !seen[grpcTransport] {
+		return nil, errors.E(nil, "transport %q: the %q transport is required", s, grpcTransport)
+	}
+	return ts, nil
+}
+
+// hasTransport reports whether t is one of the transports to generate.
+func (g *generator) hasTransport(t string) bool {
+	for _, gt := range g.transports {
+		if gt == t {
+			return true
+		}
+	}
+	return false
+}
+
+// httpRule returns the primary google.api.http rule of m,
+// or nil if m is not annotated.
+func httpRule(m *descriptor.MethodDescriptorProto) (*annotations.HttpRule, error) {
+	eHTTP, err := proto.GetExtension(m.GetOptions(), annotations.E_Http)
+	if m.GetOptions() == nil || err == proto.ErrMissingExtension {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return eHTTP.(*annotations.HttpRule), nil
+}
+
+// httpVerbPath reports the HTTP verb and path template of rule.
+func httpVerbPath(rule *annotations.HttpRule) (string, string) {
+	switch rule.GetPattern().(type) {
+	case *annotations.HttpRule_Get:
+		return "GET", rule.GetGet()
+	case *annotations.HttpRule_Post:
+		return "POST", rule.GetPost()
+	case *annotations.HttpRule_Patch:
+		return "PATCH", rule.GetPatch()
+	case *annotations.HttpRule_Put:
+		return "PUT", rule.GetPut()
+	case *annotations.HttpRule_Delete:
+		return "DELETE", rule.GetDelete()
+	case *annotations.HttpRule_Custom:
+		return rule.GetCustom().GetKind(), rule.GetCustom().GetPath()
+	}
+	return "", ""
+}
+
+func (g *generator) restClientOptions(serv *descriptor.ServiceDescriptorProto, servName string) error {
+	p := g.printf
+
+	var host string
+	if eHost, err := proto.GetExtension(serv.Options, annotations.E_DefaultHost); err == nil {
+		host = *eHost.(*string)
+	} else {
+		fqn := g.descInfo.ParentFile[serv].GetPackage() + "." + serv.GetName()
+		return fmt.Errorf("service %q is missing option google.api.default_host", fqn)
+	}
+
+	// The HTTP endpoint is a base URL; the default port for https is implied.
+	host = strings.TrimSuffix(host, ":443")
+
+	p("func default%sRESTClientOptions() []option.ClientOption {", servName)
+	p("  return []option.ClientOption{")
+	p("    option.WithEndpoint(%q),", "https://"+host)
+	p("    option.WithScopes(DefaultAuthScopes()...),")
+	p("  }")
+	p("}")
+	p("")
+
+	g.imports[pbinfo.ImportSpec{Path: "google.golang.org/api/option"}] = true
+	return nil
+}
+
+func (g *generator) restClientInit(serv *descriptor.ServiceDescriptorProto, servName string) {
+	p := g.printf
+
+	// client struct
+	{
+		p("// %sRESTClient is a client for interacting with %s over HTTP/JSON.", servName, g.apiName)
+		p("//")
+		p("// Methods, except Close, may be called concurrently. However, fields must not be modified concurrently with method calls.")
+		g.deprecationNotice(serv, servName+"RESTClient")
+		p("type %sRESTClient struct {", servName)
+
+		p("// The HTTP endpoint to connect to.")
+		p("endpoint string")
+		p("")
+
+		p("// The HTTP client.")
+		p("httpClient *http.Client")
+		p("")
+
+		p("// The call options for this service.")
+		p("CallOptions *%sCallOptions", servName)
+		p("")
+
+		p("// The x-goog-* metadata to be sent with each request.")
+		p("xGoogMetadata metadata.MD")
+		p("}")
+		p("")
+
+		g.imports[pbinfo.ImportSpec{Path: "net/http"}] = true
+		g.imports[pbinfo.ImportSpec{Path: "google.golang.org/grpc/metadata"}] = true
+	}
+
+	// Client constructor
+	{
+		clientName := camelToSnake(serv.GetName())
+		clientName = strings.Replace(clientName, "_", " ", -1)
+
+		p("// New%sRESTClient creates a new %s client that uses HTTP/JSON as its transport.", servName, clientName)
+		p("//")
+		g.comment(g.comments[serv])
+		g.deprecationNotice(serv, "New"+servName+"RESTClient")
+		p("func New%[1]sRESTClient(ctx context.Context, opts ...option.ClientOption) (*%[1]sRESTClient, error) {", servName)
+		p("  httpClient, endpoint, err := httptransport.NewClient(ctx, append(default%sRESTClientOptions(), opts...)...)", servName)
+		p("  if err != nil {")
+		p("    return nil, err")
+		p("  }")
+		p("  c := &%sRESTClient{", servName)
+		p("    endpoint:    endpoint,")
+		p("    httpClient:  httpClient,")
+		p("    CallOptions: default%sCallOptions(),", servName)
+		p("  }")
+		p("  c.setGoogleClientInfo()")
+		p("")
+		p("  return c, nil")
+		p("}")
+		p("")
+
+		g.imports[pbinfo.ImportSpec{Name: "httptransport", Path: "google.golang.org/api/transport/http"}] = true
+		g.imports[pbinfo.ImportSpec{Path: "context"}] = true
+	}
+
+	// Close()
+	{
+		p("// Close closes the connection to the API service. The user should invoke this when")
+		p("// the client is no longer required.")
+		p("func (c *%sRESTClient) Close() error {", servName)
+		p("  // The HTTP client may be shared with the caller, so it is left open.")
+		p("  return nil")
+		p("}")
+		p("")
+	}
+
+	// setGoogleClientInfo
+	{
+		p("// setGoogleClientInfo sets the name and version of the application in")
+		p("// the `x-goog-api-client` header passed on each request. Intended for")
+		p("// use by Google-written clients.")
+		p("func (c *%sRESTClient) setGoogleClientInfo(keyval ...string) {", servName)
+		p(`  kv := append([]string{"gl-go", versionGo()}, keyval...)`)
+		p(`  kv = append(kv, "gapic", versionClient, "gax", gax.Version, "rest", "UNKNOWN")`)
+		p(`  c.xGoogMetadata = metadata.Pairs("x-goog-api-client", gax.XGoogHeader(kv...))`)
+		p("}")
+		p("")
+
+		g.imports[pbinfo.ImportSpec{Name: "gax", Path: "github.com/googleapis/gax-go/v2"}] = true
+	}
+}
+
+// genRESTMethod generates a single method of the HTTP/JSON client.
+// m must be a method declared in serv.
+func (g *generator) genRESTMethod(servName string, serv *descriptor.ServiceDescriptorProto, m *descriptor.MethodDescriptorProto) error {
+	rule, err := httpRule(m)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case rule == nil:
+		return g.restUnsupportedCall(servName, serv, m, "has no google.api.http binding")
+	case g.isLRO(m):
+		return g.restUnsupportedCall(servName, serv, m, "is a long-running operation, which is not yet supported for REST clients")
+	case m.GetClientStreaming() || m.GetServerStreaming():
+		return g.restUnsupportedCall(servName, serv, m, "is a streaming method, which is not yet supported for REST clients")
+	case g.customOps[m] != nil:
+		pm := g.customOps[m].pollingMethod
+		if pmRule, err := httpRule(pm); err != nil {
+			return err
+		} else if pmRule == nil {
+			return g.restUnsupportedCall(servName, serv, m, "is polled with "+pm.GetName()+", which has no google.api.http binding")
+		}
+	}
+
+	if pf, err := g.pagingField(m); err != nil {
+		return err
+	} else if pf != nil {
+		iter, err := g.iterTypeOf(pf)
+		if err != nil {
+			return err
+		}
+		return g.restPagingCall(servName, m, rule, pf, iter)
+	}
+
+	return g.restUnaryCall(servName, m, rule)
+}
+
+func (g *generator) restUnaryCall(servName string, m *descriptor.MethodDescriptorProto, rule *annotations.HttpRule) error {
+	inType := g.descInfo.Type[m.GetInputType()]
+	inSpec, err := g.descInfo.ImportSpec(inType)
+	if err != nil {
+		return err
+	}
+
+	isEmpty := m.GetOutputType() == emptyType
+	retErr := "nil, err"
+	if isEmpty {
+		retErr = "err"
+	}
+
+	p := g.printf
+	if isEmpty {
+		p("func (c *%sRESTClient) %s(ctx context.Context, req *%s.%s, opts ...gax.CallOption) error {",
+			servName, m.GetName(), inSpec.Name, inType.GetName())
+	} else {
+		outType := g.descInfo.Type[m.GetOutputType()]
+		outSpec, err := g.descInfo.ImportSpec(outType)
+		if err != nil {
+			return err
+		}
+		g.imports[outSpec] = true
+
+		ret := fmt.Sprintf("*%s.%s", outSpec.Name, outType.GetName())
+		if g.customOps[m] != nil {
+			ret = "*" + lroTypeName(m.GetName())
+		}
+		p("func (c *%sRESTClient) %s(ctx context.Context, req *%s.%s, opts ...gax.CallOption) (%s, error) {",
+			servName, m.GetName(), inSpec.Name, inType.GetName(), ret)
+	}
+
+	if err := g.validateRequest(m, retErr); err != nil {
+		return err
+	}
+	g.applyTimeout(m)
+	md, err := g.restMetadata(m)
+	if err != nil {
+		return err
+	}
+	verb, body, err := g.restURL(m, rule, retErr)
+	if err != nil {
+		return err
+	}
+
+	g.appendCallOpts(m)
+	if g.hedged[m] {
+		// Each attempt decodes into its own response, so that they do not race.
+		if isEmpty {
+			p("_, err = invokeHedged(ctx, opts, func(ctx context.Context, settings gax.CallSettings) (interface{}, error) {")
+			p("  return nil, sendRESTRequest(ctx, c.httpClient, %q, baseURL.String(), %s, %s, nil)", verb, md, body)
+			p("})")
+			p("return err")
+		} else {
+			outType := g.descInfo.Type[m.GetOutputType()]
+			outSpec, _ := g.descInfo.ImportSpec(outType)
+
+			p("res, err := invokeHedged(ctx, opts, func(ctx context.Context, settings gax.CallSettings) (interface{}, error) {")
+			p("  resp := &%s.%s{}", outSpec.Name, outType.GetName())
+			p("  return resp, sendRESTRequest(ctx, c.httpClient, %q, baseURL.String(), %s, %s, resp)", verb, md, body)
+			p("})")
+			p("if err != nil {")
+			p("  return nil, err")
+			p("}")
+			g.unaryResult(m, fmt.Sprintf("res.(*%s.%s)", outSpec.Name, outType.GetName()))
+		}
+	} else if isEmpty {
+		p("return gax.Invoke(ctx, func(ctx context.Context, settings gax.CallSettings) error {")
+		p("  return sendRESTRequest(ctx, c.httpClient, %q, baseURL.String(), %s, %s, nil)", verb, md, body)
+		p("}, opts...)")
+	} else {
+		outType := g.descInfo.Type[m.GetOutputType()]
+		outSpec, _ := g.descInfo.ImportSpec(outType)
+
+		p("resp := &%s.%s{}", outSpec.Name, outType.GetName())
+		p("err = gax.Invoke(ctx, func(ctx context.Context, settings gax.CallSettings) error {")
+		p("  return sendRESTRequest(ctx, c.httpClient, %q, baseURL.String(), %s, %s, resp)", verb, md, body)
+		p("}, opts...)")
+		p("if err != nil {")
+		p("  return nil, err")
+		p("}")
+		g.unaryResult(m, "resp")
+	}
+	p("}")
+	p("")
+
+	g.imports[inSpec] = true
+	return nil
+}
+
+func (g *generator) restPagingCall(servName string, m *descriptor.MethodDescriptorProto, rule *annotations.HttpRule, elemField *descriptor.FieldDescriptorProto, pt *iterType) error {
+	inType := g.descInfo.Type[m.GetInputType()]
+	outType := g.descInfo.Type[m.GetOutputType()]
+
+	inSpec, err := g.descInfo.ImportSpec(inType)
+	if err != nil {
+		return err
+	}
+
+	outSpec, err := g.descInfo.ImportSpec(outType)
+	if err != nil {
+		return err
+	}
+
+	p := g.printf
+	p("func (c *%sRESTClient) %s(ctx context.Context, req *%s.%s, opts ...gax.CallOption) *%s {",
+		servName, m.GetName(), inSpec.Name, inType.GetName(), pt.iterTypeName)
+
+	g.appendCallOpts(m)
+
+	p("it := &%s{}", pt.iterTypeName)
+	p("req = proto.Clone(req).(*%s.%s)", inSpec.Name, inType.GetName())
+	if err := g.clearOutputOnly(m); err != nil {
+		return err
+	}
+	p("it.InternalFetch = func(pageSize int, pageToken string) ([]%s, string, error) {", pt.elemTypeName)
+	if err := g.validateRequest(m, `nil, "", err`); err != nil {
+		return err
+	}
+	if _, ok := g.timeouts[m]; ok {
+		// Each page gets the timeout, and the ctx of the other pages is left alone.
+		p("ctx := ctx")
+		g.applyTimeout(m)
+	}
+	p("  req.PageToken = pageToken")
+	p("  if pageSize > math.MaxInt32 {")
+	p("    req.PageSize = math.MaxInt32")
+	p("  } else {")
+	p("    req.PageSize = int32(pageSize)")
+	p("  }")
+
+	md, err := g.restMetadata(m)
+	if err != nil {
+		return err
+	}
+	verb, body, err := g.restURL(m, rule, `nil, "", err`)
+	if err != nil {
+		return err
+	}
+
+	if g.hedged[m] {
+		p("res, err := invokeHedged(ctx, opts, func(ctx context.Context, settings gax.CallSettings) (interface{}, error) {")
+		p("  resp := &%s.%s{}", outSpec.Name, outType.GetName())
+		p("  return resp, sendRESTRequest(ctx, c.httpClient, %q, baseURL.String(), %s, %s, resp)", verb, md, body)
+		p("})")
+		p("if err != nil {")
+		p("  return nil, \"\", err")
+		p("}")
+		p("resp := res.(*%s.%s)", outSpec.Name, outType.GetName())
+	} else {
+		p("  resp := &%s.%s{}", outSpec.Name, outType.GetName())
+		p("  err = gax.Invoke(ctx, func(ctx context.Context, settings gax.CallSettings) error {")
+		p("    return sendRESTRequest(ctx, c.httpClient, %q, baseURL.String(), %s, %s, resp)", verb, md, body)
+		p("  }, opts...)")
+		p("  if err != nil {")
+		p("    return nil, \"\", err")
+		p("  }")
+	}
+	p("")
+	p("  it.Response = resp")
+	g.pagingResults(elemField, pt)
+	p("}")
+
+	p("fetch := func(pageSize int, pageToken string) (string, error) {")
+	p("  items, nextPageToken, err := it.InternalFetch(pageSize, pageToken)")
+	p("  if err != nil {")
+	p("    return \"\", err")
+	p("  }")
+	p("  it.items = append(it.items, items...)")
+	p("  return nextPageToken, nil")
+	p("}")
+
+	p("it.pageInfo, it.nextFunc = iterator.NewPageInfo(fetch, it.bufLen, it.takeBuf)")
+	p("it.pageInfo.MaxSize = int(req.PageSize)")
+	p("it.pageInfo.Token = req.PageToken")
+	p("return it")
+
+	p("}")
+	p("")
+
+	g.imports[pbinfo.ImportSpec{Path: "math"}] = true
+	g.imports[pbinfo.ImportSpec{Path: "github.com/golang/protobuf/proto"}] = true
+	g.imports[pbinfo.ImportSpec{Path: "google.golang.org/api/iterator"}] = true
+	g.imports[inSpec] = true
+	g.imports[outSpec] = true
+	for _, spec := range pt.elemImports {
+		g.imports[spec] = true
+	}
+	return nil
+}
+
+// restUnsupportedCall generates a method with the same signature as the gRPC client
+// that always fails, so that both clients expose the same methods.
+func (g *generator) restUnsupportedCall(servName string, serv *descriptor.ServiceDescriptorProto, m *descriptor.MethodDescriptorProto, reason string) error {
+	inType := g.descInfo.Type[m.GetInputType()]
+	inSpec, err := g.descInfo.ImportSpec(inType)
+	if err != nil {
+		return err
+	}
+
+	var params, ret, retErr string
+	switch {
+	case m.GetClientStreaming():
+		params = "ctx context.Context, opts ...gax.CallOption"
+		ret = fmt.Sprintf("(*%s, error)", streamTypeName(m.GetName()))
+		retErr = "nil, "
+	case m.GetServerStreaming():
+		params = fmt.Sprintf("ctx context.Context, req *%s.%s, opts ...gax.CallOption", inSpec.Name, inType.GetName())
+		ret = fmt.Sprintf("(*%s, error)", streamTypeName(m.GetName()))
+		retErr = "nil, "
+		g.imports[inSpec] = true
+	case g.isLRO(m), g.customOps[m] != nil:
+		params = fmt.Sprintf("ctx context.Context, req *%s.%s, opts ...gax.CallOption", inSpec.Name, inType.GetName())
+		ret = fmt.Sprintf("(*%s, error)", lroTypeName(m.GetName()))
+		retErr = "nil, "
+		g.imports[inSpec] = true
+	case m.GetOutputType() == emptyType:
+		params = fmt.Sprintf("ctx context.Context, req *%s.%s, opts ...gax.CallOption", inSpec.Name, inType.GetName())
+		ret = "error"
+		g.imports[inSpec] = true
+	default:
+		outType := g.descInfo.Type[m.GetOutputType()]
+		outSpec, err := g.descInfo.ImportSpec(outType)
+		if err != nil {
+			return err
+		}
+		g.imports[outSpec] = true
+
+		params = fmt.Sprintf("ctx context.Context, req *%s.%s, opts ...gax.CallOption", inSpec.Name, inType.GetName())
+		ret = fmt.Sprintf("(*%s.%s, error)", outSpec.Name, outType.GetName())
+		retErr = "nil, "
+		g.imports[inSpec] = true
+
+		// Paging methods without an HTTP binding still return an iterator.
+		if pf, err := g.pagingField(m); err != nil {
+			return err
+		} else if pf != nil {
+			iter, err := g.iterTypeOf(pf)
+			if err != nil {
+				return err
+			}
+			for _, spec := range iter.elemImports {
+				g.imports[spec] = true
+			}
+
+			p := g.printf
+			p("func (c *%sRESTClient) %s(%s) *%s {", servName, m.GetName(), params, iter.iterTypeName)
+			p("  it := &%s{}", iter.iterTypeName)
+			p("  it.InternalFetch = func(pageSize int, pageToken string) ([]%s, string, error) {", iter.elemTypeName)
+			p("    return nil, \"\", status.Error(codes.Unimplemented, %q)", m.GetName()+" "+reason)
+			p("  }")
+			p("  it.pageInfo, it.nextFunc = iterator.NewPageInfo(func(pageSize int, pageToken string) (string, error) {")
+			p("    _, _, err := it.InternalFetch(pageSize, pageToken)")
+			p("    return \"\", err")
+			p("  }, it.bufLen, it.takeBuf)")
+			p("  return it")
+			p("}")
+			p("")
+
+			g.imports[pbinfo.ImportSpec{Path: "google.golang.org/api/iterator"}] = true
+			g.imports[pbinfo.ImportSpec{Path: "google.golang.org/grpc/codes"}] = true
+			g.imports[pbinfo.ImportSpec{Path: "google.golang.org/grpc/status"}] = true
+			return nil
+		}
+	}
+
+	p := g.printf
+	p("func (c *%sRESTClient) %s(%s) %s {", servName, m.GetName(), params, ret)
+	p("  return %sstatus.Error(codes.Unimplemented, %q)", retErr, m.GetName()+" "+reason)
+	p("}")
+	p("")
+
+	g.imports[pbinfo.ImportSpec{Path: "google.golang.org/grpc/codes"}] = true
+	g.imports[pbinfo.ImportSpec{Path: "google.golang.org/grpc/status"}] = true
+	return nil
+}
+
+// restURL prints the statements building baseURL, the URL of the HTTP request for m,
+// from the path template and query parameters of rule.
+// If building the URL fails, the generated code returns retErr.
+//
+// restURL reports the HTTP verb and the expression to be sent as the request body.
+func (g *generator) restURL(m *descriptor.MethodDescriptorProto, rule *annotations.HttpRule, retErr string) (string, string, error) {
+	p := g.printf
+
+	verb, path := httpVerbPath(rule)
+	if verb == "" {
+		return "", "", errors.E(nil, "unsupported google.api.http pattern in rpc %q", m.GetName())
+	}
+
+	inType := g.descInfo.Type[m.GetInputType()]
+	inMsg, ok := inType.(*descriptor.DescriptorProto)
+	if !ok {
+		return "", "", errors.E(nil, "expected %q to be message type, found %T", m.GetInputType(), inType)
+	}
+
+	// Fields bound to the path are not sent elsewhere.
+	bound := map[string]bool{}
+	var pathArgs strings.Builder
+	for _, match := range pathParamRegexp.FindAllStringSubmatch(path, -1) {
+		bound[match[1]] = true
+		fmt.Fprintf(&pathArgs, ", req%s", buildAccessor(match[1]))
+	}
+	path = pathParamRegexp.ReplaceAllString(path, "%v")
+
+	body := "nil"
+	switch b := rule.GetBody(); b {
+	case "":
+	case "*":
+		body = "req"
+	default:
+		var bodyField *descriptor.FieldDescriptorProto
+		for _, f := range inMsg.GetField() {
+			if f.GetName() == b {
+				bodyField = f
+			}
+		}
+		if bodyField == nil || bodyField.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE ||
+			bodyField.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+			return "", "", errors.E(nil, "rpc %q: google.api.http body %q must name a singular message field", m.GetName(), b)
+		}
+		bound[b] = true
+		body = "req" + buildAccessor(b)
+	}
+
+	p("baseURL, err := url.Parse(c.endpoint)")
+	p("if err != nil {")
+	p("  return %s", retErr)
+	p("}")
+	if pathArgs.Len() > 0 {
+		p("baseURL.Path += fmt.Sprintf(%q%s)", path, pathArgs.String())
+		g.imports[pbinfo.ImportSpec{Path: "fmt"}] = true
+	} else {
+		p("baseURL.Path += %q", path)
+	}
+
+	// With body "*", every field not bound to the path is in the body.
+	if rule.GetBody() != "*" {
+		params, err := g.restQueryParams(m, inMsg, "", "", "", bound, map[*descriptor.DescriptorProto]bool{inMsg: true})
+		if err != nil {
+			return "", "", err
+		}
+
+		if len(params) > 0 {
+			p("")
+			p("params := url.Values{}")
+			for _, qp := range params {
+				g.restQueryParam(qp, retErr)
+			}
+			p("baseURL.RawQuery = params.Encode()")
+		}
+	}
+	p("")
+
+	g.imports[pbinfo.ImportSpec{Path: "net/url"}] = true
+	return verb, body, nil
+}
+
+// restMetadata generates the metadata sent as HTTP headers with the requests of m,
+// including the x-goog-request-params header like the gRPC client,
+// and returns the expression holding it.
+func (g *generator) restMetadata(m *descriptor.MethodDescriptorProto) (string, error) {
+	hasParams, err := g.requestParamsMD(m)
+	if err != nil || !hasParams {
+		return "c.xGoogMetadata", err
+	}
+	g.printf("md = metadata.Join(c.xGoogMetadata, md)")
+	return "md", nil
+}
+
+// restQueryValueTypes are the well-known message types sent as query parameters in their JSON form.
+var restQueryValueTypes = map[string]bool{
+	".google.protobuf.Timestamp":   true,
+	".google.protobuf.Duration":    true,
+	".google.protobuf.DoubleValue": true,
+	".google.protobuf.FloatValue":  true,
+	".google.protobuf.Int64Value":  true,
+	".google.protobuf.UInt64Value": true,
+	".google.protobuf.Int32Value":  true,
+	".google.protobuf.UInt32Value": true,
+	".google.protobuf.BoolValue":   true,
+	".google.protobuf.StringValue": true,
+	".google.protobuf.BytesValue":  true,
+}
+
+// queryParam is a leaf field of a request sent as a query parameter:
+// the field, the name of the parameter, and the expression getting the field from req.
+type queryParam struct {
+	f   *descriptor.FieldDescriptorProto
+	key string
+	get string
+}
+
+// restQueryParams reports the query parameters of the fields of msg, a message of the request of m
+// at the dotted field path fieldPath, named keyPrefix in parameters and accessed by get, that are not in bound.
+// As described by google.api.HttpRule, nested messages are flattened into "parent.child" parameters.
+// Fields that cannot be sent as query parameters are an error, rather than silently dropped.
+// onPath holds the messages enclosing msg, to reject recursive messages.
+func (g *generator) restQueryParams(m *descriptor.MethodDescriptorProto, msg *descriptor.DescriptorProto, fieldPath, keyPrefix, get string,
+	bound map[string]bool, onPath map[*descriptor.DescriptorProto]bool) ([]queryParam, error) {
+	var params []queryParam
+	for _, f := range msg.GetField() {
+		path := fieldPath + f.GetName()
+		if bound[path] {
+			continue
+		}
+		key := f.GetJsonName()
+		if key == "" {
+			key = lowerFirst(snakeToCamel(f.GetName()))
+		}
+		qp := queryParam{
+			f:   f,
+			key: keyPrefix + key,
+			get: "req" + get + buildAccessor(f.GetName()),
+		}
+
+		if f.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE ||
+			f.GetTypeName() == fieldMaskType || restQueryValueTypes[f.GetTypeName()] {
+			params = append(params, qp)
+			continue
+		}
+
+		nested, ok := g.descInfo.Type[f.GetTypeName()].(*descriptor.DescriptorProto)
+		switch {
+		case !ok:
+			return nil, errors.E(nil, "rpc %s: can't find message %s of field %s", m.GetName(), f.GetTypeName(), path)
+		case f.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED:
+			return nil, errors.E(nil, "rpc %s: repeated message field %s can't be sent as query parameters; "+
+				"bind it to the google.api.http body instead", m.GetName(), path)
+		case onPath[nested]:
+			return nil, errors.E(nil, "rpc %s: recursive message field %s can't be sent as query parameters; "+
+				"bind it to the google.api.http body instead", m.GetName(), path)
+		}
+
+		onPath[nested] = true
+		nestedParams, err := g.restQueryParams(m, nested, path+".", qp.key+".", get+buildAccessor(f.GetName()), bound, onPath)
+		delete(onPath, nested)
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, nestedParams...)
+	}
+	return params, nil
+}
+
+// restQueryParam prints the statements adding the request field of qp to the url.Values params,
+// if the field is set. retErr is returned if the field cannot be encoded.
+func (g *generator) restQueryParam(qp queryParam, retErr string) {
+	p := g.printf
+	f, key, get := qp.f, qp.key, qp.get
+
+	repeated := f.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED
+	switch {
+	case f.GetTypeName() == fieldMaskType:
+		p("if %s != nil {", get)
+		p("  params.Add(%q, restFieldMask(%s.GetPaths()))", key, get)
+		p("}")
+	case restQueryValueTypes[f.GetTypeName()]:
+		if repeated {
+			p("for _, v := range %s {", get)
+		} else {
+			p("if v := %s; v != nil {", get)
+		}
+		p("  s, err := restQueryValue(v)")
+		p("  if err != nil {")
+		p("    return %s", retErr)
+		p("  }")
+		p("  params.Add(%q, s)", key)
+		p("}")
+	case repeated && f.GetType() == descriptor.FieldDescriptorProto_TYPE_BYTES:
+		p("for _, v := range %s {", get)
+		p("  params.Add(%q, base64.URLEncoding.EncodeToString(v))", key)
+		p("}")
+		g.imports[pbinfo.ImportSpec{Path: "encoding/base64"}] = true
+	case repeated:
+		p("for _, v := range %s {", get)
+		p("  params.Add(%q, fmt.Sprintf(\"%%v\", v))", key)
+		p("}")
+		g.imports[pbinfo.ImportSpec{Path: "fmt"}] = true
+	case f.GetType() == descriptor.FieldDescriptorProto_TYPE_BOOL:
+		p("if %s {", get)
+		p("  params.Add(%q, \"true\")", key)
+		p("}")
+	case f.GetType() == descriptor.FieldDescriptorProto_TYPE_STRING:
+		p("if %s != \"\" {", get)
+		p("  params.Add(%q, %s)", key, get)
+		p("}")
+	case f.GetType() == descriptor.FieldDescriptorProto_TYPE_BYTES:
+		p("if len(%s) > 0 {", get)
+		p("  params.Add(%q, base64.URLEncoding.EncodeToString(%s))", key, get)
+		p("}")
+		g.imports[pbinfo.ImportSpec{Path: "encoding/base64"}] = true
+	case f.GetType() == descriptor.FieldDescriptorProto_TYPE_ENUM:
+		p("if %s != 0 {", get)
+		p("  params.Add(%q, %s.String())", key, get)
+		p("}")
+	default:
+		p("if %s != 0 {", get)
+		p("  params.Add(%q, fmt.Sprintf(\"%%v\", %s))", key, get)
+		p("}")
+		g.imports[pbinfo.ImportSpec{Path: "fmt"}] = true
+	}
+}
+
+// restHelpers prints the package-level functions shared by the HTTP/JSON clients in the package.
+func (g *generator) restHelpers() {
+	p := g.printf
+
+	p("// sendRESTRequest sends body as JSON in an HTTP request to url, and decodes the JSON response into resp.")
+	p("// Either body or resp may be nil. HTTP errors are converted to gRPC status errors,")
+	p("// so that both transports report errors, and are retried, the same way.")
+	p("func sendRESTRequest(ctx context.Context, client *http.Client, method, url string, md metadata.MD, body, resp proto.Message) error {")
+	p("  var reqBody io.Reader")
+	p("  if body != nil {")
+	p("    b, err := (&jsonpb.Marshaler{}).MarshalToString(body)")
+	p("    if err != nil {")
+	p("      return err")
+	p("    }")
+	p("    reqBody = strings.NewReader(b)")
+	p("  }")
+	p("")
+	p("  httpReq, err := http.NewRequest(method, url, reqBody)")
+	p("  if err != nil {")
+	p("    return err")
+	p("  }")
+	p("  httpReq = httpReq.WithContext(ctx)")
+	p("  httpReq.Header.Set(%q, %q)", "Content-Type", "application/json")
+	p("  for k, vs := range md {")
+	p("    for _, v := range vs {")
+	p("      httpReq.Header.Add(k, v)")
+	p("    }")
+	p("  }")
+	p("")
+	p("  httpResp, err := client.Do(httpReq)")
+	p("  if err != nil {")
+	p("    return err")
+	p("  }")
+	p("  defer httpResp.Body.Close()")
+	p("")
+	p("  if err := googleapi.CheckResponse(httpResp); err != nil {")
+	p("    return restStatusError(err)")
+	p("  }")
+	p("  if resp == nil {")
+	p("    return nil")
+	p("  }")
+	p("  return (&jsonpb.Unmarshaler{AllowUnknownFields: true}).Unmarshal(httpResp.Body, resp)")
+	p("}")
+	p("")
+
+	p("// restQueryValue returns the JSON form of the well-known type m as a query parameter,")
+	p("// that is, without the quotes of JSON strings.")
+	p("func restQueryValue(m proto.Message) (string, error) {")
+	p("  s, err := (&jsonpb.Marshaler{}).MarshalToString(m)")
+	p("  if err != nil {")
+	p("    return \"\", err")
+	p("  }")
+	p("  if strings.HasPrefix(s, `\"`) {")
+	p("    return strconv.Unquote(s)")
+	p("  }")
+	p("  return s, nil")
+	p("}")
+	p("")
+
+	p("// restFieldMask returns the JSON form of a google.protobuf.FieldMask with paths,")
+	p("// whose field names are in lowerCamelCase.")
+	p("func restFieldMask(paths []string) string {")
+	p("  var sb strings.Builder")
+	p("  for i, path := range paths {")
+	p("    if i > 0 {")
+	p("      sb.WriteByte(',')")
+	p("    }")
+	p("    upper := false")
+	p("    for _, r := range path {")
+	p("      if r == '_' {")
+	p("        upper = true")
+	p("        continue")
+	p("      }")
+	p("      if upper {")
+	p("        r = unicode.ToUpper(r)")
+	p("        upper = false")
+	p("      }")
+	p("      sb.WriteRune(r)")
+	p("    }")
+	p("  }")
+	p("  return sb.String()")
+	p("}")
+	p("")
+
+	p("// restStatusError converts an HTTP error into a gRPC status error")
+	p("// according to https://cloud.google.com/apis/design/errors#handling_errors.")
+	p("func restStatusError(err error) error {")
+	p("  apiErr, ok := err.(*googleapi.Error)")
+	p("  if !ok {")
+	p("    return err")
+	p("  }")
+	p("")
+	p("  c := codes.Unknown")
+	p("  switch apiErr.Code {")
+	for _, sc := range []struct{ http, code string }{
+		{"http.StatusBadRequest", "InvalidArgument"},
+		{"http.StatusUnauthorized", "Unauthenticated"},
+		{"http.StatusForbidden", "PermissionDenied"},
+		{"http.StatusNotFound", "NotFound"},
+		{"http.StatusConflict", "Aborted"},
+		{"http.StatusRequestedRangeNotSatisfiable", "OutOfRange"},
+		{"http.StatusTooManyRequests", "ResourceExhausted"},
+		{"499", "Canceled"},
+		{"http.StatusInternalServerError", "Internal"},
+		{"http.StatusNotImplemented", "Unimplemented"},
+		{"http.StatusServiceUnavailable", "Unavailable"},
+		{"http.StatusGatewayTimeout", "DeadlineExceeded"},
+	} {
+		p("  case %s:", sc.http)
+		p("    c = codes.%s", sc.code)
+	}
+	p("  }")
+	p("")
+	p("  msg := apiErr.Message")
+	p("  if msg == %q {", "")
+	p("    msg = apiErr.Error()")
+	p("  }")
+	p("  return status.Error(c, msg)")
+	p("}")
+	p("")
+
+	for _, path := range []string{
+		"context", "io", "net/http", "strconv", "strings", "unicode",
+		"github.com/golang/protobuf/jsonpb", "github.com/golang/protobuf/proto", "google.golang.org/api/googleapi",
+		"google.golang.org/grpc/codes", "google.golang.org/grpc/metadata", "google.golang.org/grpc/status",
+	} {
+		g.imports[pbinfo.ImportSpec{Path: path}] = true
+	}
+}
@@ -0,0 +1,308 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gengapic
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/googleapis/gapic-generator-go/internal/pbinfo"
+	"github.com/googleapis/gapic-generator-go/internal/txtdiff"
+	"google.golang.org/genproto/googleapis/api/annotations"
+)
+
+func TestParseTransports(t *testing.T) {
+	for _, tst := range []struct {
+		in      string
+		want    []string
+		wantErr bool
+	}{
+		{in: "", want: []string{"grpc"}},
+		{in: "grpc", want: []string{"grpc"}},
+		{in: "grpc+rest", want: []string{"grpc", "rest"}},
+		{in: "rest+grpc+rest", want: []string{"rest", "grpc"}},
+		{in: "rest", wantErr: true},
+		{in: "grpc+http", wantErr: true},
+	} {
+		got, err := parseTransports(tst.in)
+		if (err != nil) != tst.wantErr {
+			t.Errorf("parseTransports(%q) error = %v, wantErr %v", tst.in, err, tst.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tst.want) {
+			t.Errorf("parseTransports(%q) = %v, want %v", tst.in, got, tst.want)
+		}
+	}
+}
+
+func TestGenRESTMethod(t *testing.T) {
+	typep := func(t descriptor.FieldDescriptorProto_Type) *descriptor.FieldDescriptorProto_Type {
+		return &t
+	}
+	labelp := func(l descriptor.FieldDescriptorProto_Label) *descriptor.FieldDescriptorProto_Label {
+		return &l
+	}
+
+	thing := &descriptor.DescriptorProto{
+		Name: proto.String("Thing"),
+		Field: []*descriptor.FieldDescriptorProto{
+			{
+				Name:     proto.String("display_name"),
+				JsonName: proto.String("displayName"),
+				Type:     typep(descriptor.FieldDescriptorProto_TYPE_STRING),
+				Label:    labelp(descriptor.FieldDescriptorProto_LABEL_OPTIONAL),
+			},
+			{
+				Name:     proto.String("expire_time"),
+				JsonName: proto.String("expireTime"),
+				Type:     typep(descriptor.FieldDescriptorProto_TYPE_MESSAGE),
+				TypeName: proto.String(".google.protobuf.Timestamp"),
+				Label:    labelp(descriptor.FieldDescriptorProto_LABEL_OPTIONAL),
+			},
+		},
+	}
+	inputType := &descriptor.DescriptorProto{
+		Name: proto.String("InputType"),
+		Field: []*descriptor.FieldDescriptorProto{
+			{
+				Name:     proto.String("name"),
+				JsonName: proto.String("name"),
+				Type:     typep(descriptor.FieldDescriptorProto_TYPE_STRING),
+				Label:    labelp(descriptor.FieldDescriptorProto_LABEL_OPTIONAL),
+			},
+			{
+				Name:     proto.String("thing"),
+				JsonName: proto.String("thing"),
+				Type:     typep(descriptor.FieldDescriptorProto_TYPE_MESSAGE),
+				TypeName: proto.String(".my.pkg.Thing"),
+				Label:    labelp(descriptor.FieldDescriptorProto_LABEL_OPTIONAL),
+			},
+			{
+				Name:     proto.String("update_mask"),
+				JsonName: proto.String("updateMask"),
+				Type:     typep(descriptor.FieldDescriptorProto_TYPE_MESSAGE),
+				TypeName: proto.String(fieldMaskType),
+				Label:    labelp(descriptor.FieldDescriptorProto_LABEL_OPTIONAL),
+			},
+			{
+				Name:     proto.String("validate_only"),
+				JsonName: proto.String("validateOnly"),
+				Type:     typep(descriptor.FieldDescriptorProto_TYPE_BOOL),
+				Label:    labelp(descriptor.FieldDescriptorProto_LABEL_OPTIONAL),
+			},
+			{
+				Name:     proto.String("tags"),
+				JsonName: proto.String("tags"),
+				Type:     typep(descriptor.FieldDescriptorProto_TYPE_STRING),
+				Label:    labelp(descriptor.FieldDescriptorProto_LABEL_REPEATED),
+			},
+			{
+				Name:     proto.String("etags"),
+				JsonName: proto.String("etags"),
+				Type:     typep(descriptor.FieldDescriptorProto_TYPE_BYTES),
+				Label:    labelp(descriptor.FieldDescriptorProto_LABEL_REPEATED),
+			},
+			{
+				Name:     proto.String("ttl"),
+				JsonName: proto.String("ttl"),
+				Type:     typep(descriptor.FieldDescriptorProto_TYPE_MESSAGE),
+				TypeName: proto.String(".google.protobuf.Duration"),
+				Label:    labelp(descriptor.FieldDescriptorProto_LABEL_OPTIONAL),
+			},
+		},
+	}
+	outputType := &descriptor.DescriptorProto{
+		Name: proto.String("OutputType"),
+	}
+
+	file := &descriptor.FileDescriptorProto{
+		Package: proto.String("my.pkg"),
+		Options: &descriptor.FileOptions{
+			GoPackage: proto.String("mypackage"),
+		},
+	}
+	serv := &descriptor.ServiceDescriptorProto{
+		Name: proto.String("FooService"),
+	}
+
+	var g generator
+	g.imports = map[pbinfo.ImportSpec]bool{}
+	g.aux = &auxTypes{
+		iters: map[string]*iterType{},
+	}
+
+	commonTypes(&g)
+	for _, typ := range []*descriptor.DescriptorProto{
+		thing, inputType, outputType,
+	} {
+		g.descInfo.Type[".my.pkg."+*typ.Name] = typ
+		g.descInfo.ParentFile[typ] = file
+	}
+	g.descInfo.ParentFile[serv] = file
+
+	httpOpts := func(rule *annotations.HttpRule) *descriptor.MethodOptions {
+		opts := &descriptor.MethodOptions{}
+		if err := proto.SetExtension(opts, annotations.E_Http, rule); err != nil {
+			t.Fatal(err)
+		}
+		return opts
+	}
+
+	for _, m := range []*descriptor.MethodDescriptorProto{
+		{
+			Name:       proto.String("GetThing"),
+			InputType:  proto.String(".my.pkg.InputType"),
+			OutputType: proto.String(".my.pkg.OutputType"),
+			Options: httpOpts(&annotations.HttpRule{
+				Pattern: &annotations.HttpRule_Get{Get: "/v1/{name=things/*}"},
+			}),
+		},
+		{
+			Name:       proto.String("UpdateThing"),
+			InputType:  proto.String(".my.pkg.InputType"),
+			OutputType: proto.String(".my.pkg.OutputType"),
+			Options: httpOpts(&annotations.HttpRule{
+				Pattern: &annotations.HttpRule_Patch{Patch: "/v1/{name=things/*}"},
+				Body:    "thing",
+			}),
+		},
+		{
+			Name:       proto.String("DeleteThing"),
+			InputType:  proto.String(".my.pkg.InputType"),
+			OutputType: proto.String(emptyType),
+			Options: httpOpts(&annotations.HttpRule{
+				Pattern: &annotations.HttpRule_Post{Post: "/v1/{name=things/*}:delete"},
+				Body:    "*",
+			}),
+		},
+		{
+			Name:       proto.String("NoBinding"),
+			InputType:  proto.String(".my.pkg.InputType"),
+			OutputType: proto.String(".my.pkg.OutputType"),
+		},
+		{
+			Name:       proto.String("CreateThing"),
+			InputType:  proto.String(".my.pkg.InputType"),
+			OutputType: proto.String(lroType),
+			Options: httpOpts(&annotations.HttpRule{
+				Pattern: &annotations.HttpRule_Post{Post: "/v1/things"},
+				Body:    "thing",
+			}),
+		},
+		{
+			Name:            proto.String("WatchThings"),
+			InputType:       proto.String(".my.pkg.InputType"),
+			OutputType:      proto.String(".my.pkg.OutputType"),
+			ServerStreaming: proto.Bool(true),
+			Options: httpOpts(&annotations.HttpRule{
+				Pattern: &annotations.HttpRule_Get{Get: "/v1/things:watch"},
+			}),
+		},
+	} {
+		g.reset()
+		// UpdateThing and DeleteThing send hedged requests.
+		g.hedged = map[*descriptor.MethodDescriptorProto]bool{
+			m: m.GetName() == "UpdateThing" || m.GetName() == "DeleteThing",
+		}
+		if err := g.genRESTMethod("Foo", serv, m); err != nil {
+			t.Error(err)
+			continue
+		}
+		txtdiff.Diff(t, m.GetName(), g.pt.String(), filepath.Join("testdata", "rest_method_"+m.GetName()+".want"))
+	}
+}
+
+func TestRESTQueryParams_unsupported(t *testing.T) {
+	thing := &descriptor.DescriptorProto{
+		Name: proto.String("Thing"),
+		Field: []*descriptor.FieldDescriptorProto{
+			{Name: proto.String("name"), Type: descriptor.FieldDescriptorProto_TYPE_STRING.Enum()},
+		},
+	}
+	node := &descriptor.DescriptorProto{Name: proto.String("Node")}
+	node.Field = []*descriptor.FieldDescriptorProto{
+		{Name: proto.String("name"), Type: descriptor.FieldDescriptorProto_TYPE_STRING.Enum()},
+		{Name: proto.String("child"), Type: descriptor.FieldDescriptorProto_TYPE_MESSAGE.Enum(), TypeName: proto.String(".my.pkg.Node")},
+	}
+
+	var g generator
+	g.descInfo.Type = map[string]pbinfo.ProtoType{
+		".my.pkg.Thing": thing,
+		".my.pkg.Node":  node,
+	}
+	m := &descriptor.MethodDescriptorProto{Name: proto.String("ListThings")}
+
+	for _, tst := range []struct {
+		name    string
+		field   *descriptor.FieldDescriptorProto
+		wantErr string
+	}{
+		{
+			name: "repeated message",
+			field: &descriptor.FieldDescriptorProto{
+				Name:     proto.String("things"),
+				Type:     descriptor.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				TypeName: proto.String(".my.pkg.Thing"),
+				Label:    descriptor.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+			},
+			wantErr: "repeated message field things",
+		},
+		{
+			name: "recursive message",
+			field: &descriptor.FieldDescriptorProto{
+				Name:     proto.String("root"),
+				Type:     descriptor.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				TypeName: proto.String(".my.pkg.Node"),
+			},
+			wantErr: "recursive message field root.child",
+		},
+	} {
+		req := &descriptor.DescriptorProto{
+			Name:  proto.String("Request"),
+			Field: []*descriptor.FieldDescriptorProto{tst.field},
+		}
+		_, err := g.restQueryParams(m, req, "", "", "", nil, map[*descriptor.DescriptorProto]bool{req: true})
+		if err == nil || !strings.Contains(err.Error(), tst.wantErr) {
+			t.Errorf("%s: restQueryParams() = %v, want error containing %q", tst.name, err, tst.wantErr)
+		}
+	}
+}
+
+func TestRESTClientInit(t *testing.T) {
+	var g generator
+	g.apiName = "Awesome Foo API"
+	g.imports = map[pbinfo.ImportSpec]bool{}
+
+	serv := &descriptor.ServiceDescriptorProto{
+		Name:    proto.String("Foo"),
+		Options: &descriptor.ServiceOptions{},
+	}
+	if err := proto.SetExtension(serv.Options, annotations.E_DefaultHost, proto.String("foo.bar.com")); err != nil {
+		t.Fatal(err)
+	}
+	g.comments = map[proto.Message]string{
+		serv: "Foo service does stuff.",
+	}
+
+	if err := g.restClientOptions(serv, "Foo"); err != nil {
+		t.Fatal(err)
+	}
+	g.restClientInit(serv, "Foo")
+	txtdiff.Diff(t, "rest_client_init", g.pt.String(), filepath.Join("testdata", "rest_client_init.want"))
+}
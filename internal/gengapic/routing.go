@@ -0,0 +1,193 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gengapic
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/googleapis/gapic-generator-go/internal/errors"
+	"github.com/googleapis/gapic-generator-go/internal/pbinfo"
+	"github.com/googleapis/gapic-generator-go/internal/routing"
+)
+
+// routingParam is a parameter of a google.api.routing annotation: the routing header key it sets,
+// and the regexp whose first submatch extracts the value of the header from the request field.
+type routingParam struct {
+	field, key, regexp string
+}
+
+// routingParams reports the parameters of the google.api.routing annotation of m,
+// in declaration order, or nil if m has no such annotation.
+func routingParams(m *descriptor.MethodDescriptorProto) ([]routingParam, error) {
+	if m.GetOptions() == nil {
+		return nil, nil
+	}
+	eRouting, err := proto.GetExtension(m.GetOptions(), routing.E_Routing)
+	if err == proto.ErrMissingExtension {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var params []routingParam
+	for _, rp := range eRouting.(*routing.RoutingRule).GetRoutingParameters() {
+		if rp.GetField() == "" {
+			return nil, errors.E(nil, "rpc %s: google.api.routing parameter is missing field", m.GetName())
+		}
+		if rp.GetPathTemplate() == "" {
+			params = append(params, routingParam{field: rp.GetField(), key: rp.GetField(), regexp: "^(.*)$"})
+			continue
+		}
+		key, re, err := pathTemplateRegexp(rp.GetPathTemplate())
+		if err != nil {
+			return nil, errors.E(err, "rpc %s: bad google.api.routing path_template of field %s", m.GetName(), rp.GetField())
+		}
+		params = append(params, routingParam{field: rp.GetField(), key: key, regexp: re})
+	}
+	return params, nil
+}
+
+// pathTemplateRegexp converts the path template of a routing parameter, e.g.
+// "projects/*/{instance=instances/*}/**", into a regexp matching the whole field
+// whose only group captures the named segment, and reports the name of the segment.
+// A named segment without pattern, e.g. "{instance}", matches a single path segment.
+func pathTemplateRegexp(tmpl string) (string, string, error) {
+	lb := strings.IndexByte(tmpl, '{')
+	rb := strings.IndexByte(tmpl, '}')
+	if lb < 0 || rb < lb || strings.Count(tmpl, "{") != 1 || strings.Count(tmpl, "}") != 1 {
+		return "", "", errors.E(nil, "path template %q must have exactly one named segment", tmpl)
+	}
+
+	key, pattern := tmpl[lb+1:rb], "*"
+	if p := strings.IndexByte(key, '='); p >= 0 {
+		key, pattern = key[:p], key[p+1:]
+	}
+	if key == "" || pattern == "" {
+		return "", "", errors.E(nil, "path template %q has an empty segment name or pattern", tmpl)
+	}
+
+	re := "^" + segmentsRegexp(tmpl[:lb]) + "(" + segmentsRegexp(pattern) + ")" + segmentsRegexp(tmpl[rb+1:]) + "$"
+	if _, err := regexp.Compile(re); err != nil {
+		return "", "", err
+	}
+	return key, re, nil
+}
+
+// segmentsRegexp converts the slash-separated segments of a path template into a regexp.
+// A "*" segment matches a single path segment, and a "**" segment any number of them.
+func segmentsRegexp(s string) string {
+	if s == "" {
+		return ""
+	}
+	var sb strings.Builder
+	for i, seg := range strings.Split(s, "/") {
+		switch {
+		case seg == "**" && i > 0:
+			// The slash before "**" is optional, so that "foo/**" matches "foo".
+			sb.WriteString("(?:/.*)?")
+			continue
+		case i > 0:
+			sb.WriteByte('/')
+		}
+		switch seg {
+		case "*":
+			sb.WriteString("[^/]+")
+		case "**":
+			sb.WriteString(".*")
+		default:
+			sb.WriteString(regexp.QuoteMeta(seg))
+		}
+	}
+	return sb.String()
+}
+
+// hasRequestHeaders reports whether calls of m send request headers derived from the request,
+// either from the google.api.routing annotation or from the google.api.http annotation of m.
+func hasRequestHeaders(m *descriptor.MethodDescriptorProto) (bool, error) {
+	params, err := routingParams(m)
+	if err != nil || len(params) > 0 {
+		return len(params) > 0, err
+	}
+	headers, err := parseRequestHeaders(m)
+	return len(headers) > 0, err
+}
+
+// routingHeadersMD generates code that extracts the x-goog-request-params header from req,
+// according to the google.api.routing parameters params, into the metadata.MD md.
+// Parameters are applied in order, so of the parameters setting the same key,
+// the last one matching the request wins.
+func (g *generator) routingHeadersMD(params []routingParam) {
+	p := g.printf
+
+	var keys []string
+	seen := map[string]bool{}
+	p("routingHeadersMap := make(map[string]string)")
+	for _, rp := range params {
+		p("if match := %s.FindStringSubmatch(req%s); len(match) > 1 && match[1] != \"\" {",
+			g.routingRegexp(rp.regexp), buildAccessor(rp.field))
+		// URL encode key & values separately per aip.dev/4222.
+		// Encode the key ahead of time to reduce clutter.
+		p("  routingHeadersMap[%q] = url.QueryEscape(match[1])", url.QueryEscape(rp.key))
+		p("}")
+
+		if !seen[rp.key] {
+			seen[rp.key] = true
+			keys = append(keys, fmt.Sprintf("%q", url.QueryEscape(rp.key)))
+		}
+	}
+	p("var routingHeaders []string")
+	p("for _, k := range []string{%s} {", strings.Join(keys, ", "))
+	p("  if v, ok := routingHeadersMap[k]; ok {")
+	p("    routingHeaders = append(routingHeaders, k+\"=\"+v)")
+	p("  }")
+	p("}")
+	p("md := metadata.Pairs(\"x-goog-request-params\", strings.Join(routingHeaders, \"&\"))")
+
+	g.imports[pbinfo.ImportSpec{Path: "net/url"}] = true
+	g.imports[pbinfo.ImportSpec{Path: "strings"}] = true
+	g.imports[pbinfo.ImportSpec{Path: "google.golang.org/grpc/metadata"}] = true
+}
+
+// routingRegexp returns the name of the package-level variable holding the regexp re compiled,
+// recording in g.aux that it must be generated. Methods with the same regexp share the variable.
+func (g *generator) routingRegexp(re string) string {
+	i := 0
+	for i < len(g.aux.routingRegexps) && g.aux.routingRegexps[i] != re {
+		i++
+	}
+	if i == len(g.aux.routingRegexps) {
+		g.aux.routingRegexps = append(g.aux.routingRegexps, re)
+	}
+	return fmt.Sprintf("routingRegexp%d", i)
+}
+
+// routingRegexpVars generates the variables recorded in g.aux that hold the compiled regexps
+// of google.api.routing parameters.
+func (g *generator) routingRegexpVars() {
+	p := g.printf
+	p("// The regexps extracting google.api.routing parameters from requests,")
+	p("// compiled once rather than on every call.")
+	for i, re := range g.aux.routingRegexps {
+		p("var routingRegexp%d = regexp.MustCompile(%q)", i, re)
+	}
+	p("")
+
+	g.imports[pbinfo.ImportSpec{Path: "regexp"}] = true
+}
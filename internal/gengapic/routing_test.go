@@ -0,0 +1,129 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gengapic
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/googleapis/gapic-generator-go/internal/pbinfo"
+)
+
+func TestPathTemplateRegexp(t *testing.T) {
+	for _, tst := range []struct {
+		tmpl, key string
+		// Values of the field, and the routing header value extracted from them, "" if they do not match.
+		matches map[string]string
+	}{
+		{
+			tmpl: "{routing_id=**}",
+			key:  "routing_id",
+			matches: map[string]string{
+				"profiles/prof_qux": "profiles/prof_qux",
+				"":                  "",
+			},
+		},
+		{
+			tmpl: "{table_name=projects/*/instances/*/**}",
+			key:  "table_name",
+			matches: map[string]string{
+				"projects/p/instances/i/tables/t": "projects/p/instances/i/tables/t",
+				"projects/p/instances/i":          "projects/p/instances/i",
+				"projects/p/tables/t":             "",
+			},
+		},
+		{
+			tmpl: "projects/*/{table_location=instances/*}/tables/*",
+			key:  "table_location",
+			matches: map[string]string{
+				"projects/p/instances/i/tables/t": "instances/i",
+				"projects/p/instances/i/tables":   "",
+				"regions/r/instances/i/tables/t":  "",
+			},
+		},
+		{
+			tmpl: "{project}/**",
+			key:  "project",
+			matches: map[string]string{
+				"p/instances/i": "p",
+				"p":             "p",
+			},
+		},
+		{
+			tmpl: "v1.{name=*}",
+			key:  "name",
+			matches: map[string]string{
+				"v1.foo": "foo",
+				"v12foo": "",
+			},
+		},
+	} {
+		key, re, err := pathTemplateRegexp(tst.tmpl)
+		if err != nil {
+			t.Errorf("pathTemplateRegexp(%q) = %v", tst.tmpl, err)
+			continue
+		}
+		if key != tst.key {
+			t.Errorf("pathTemplateRegexp(%q) key = %q, want %q", tst.tmpl, key, tst.key)
+		}
+		for in, want := range tst.matches {
+			var got string
+			if match := regexp.MustCompile(re).FindStringSubmatch(in); len(match) > 1 {
+				got = match[1]
+			}
+			if got != want {
+				t.Errorf("pathTemplateRegexp(%q) = %q, which extracts %q from %q, want %q", tst.tmpl, re, got, in, want)
+			}
+		}
+	}
+
+	for _, tmpl := range []string{
+		"projects/*",
+		"{a=*}/{b=*}",
+		"{=projects/*}",
+		"{name=}",
+		"}name{",
+	} {
+		if _, _, err := pathTemplateRegexp(tmpl); err == nil {
+			t.Errorf("pathTemplateRegexp(%q) = nil error, want error", tmpl)
+		}
+	}
+}
+
+func TestRoutingRegexp(t *testing.T) {
+	g := generator{aux: newAuxTypes(), imports: map[pbinfo.ImportSpec]bool{}}
+	for _, tst := range []struct {
+		re, want string
+	}{
+		{re: "^(projects/[^/]+)$", want: "routingRegexp0"},
+		{re: "^(.*)$", want: "routingRegexp1"},
+		{re: "^(projects/[^/]+)$", want: "routingRegexp0"},
+	} {
+		if got := g.routingRegexp(tst.re); got != tst.want {
+			t.Errorf("routingRegexp(%q) = %q, want %q", tst.re, got, tst.want)
+		}
+	}
+
+	g.routingRegexpVars()
+	want := `// The regexps extracting google.api.routing parameters from requests,
+// compiled once rather than on every call.
+var routingRegexp0 = regexp.MustCompile("^(projects/[^/]+)$")
+var routingRegexp1 = regexp.MustCompile("^(.*)$")
+
+`
+	if got := g.pt.String(); got != want {
+		t.Errorf("routingRegexpVars() = %q, want %q", got, want)
+	}
+}
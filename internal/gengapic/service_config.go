@@ -14,15 +14,213 @@
 
 package gengapic
 
-// serviceConfig represents a gapic service config
-// Deprecated: workaround for not having annotations yet; to be removed
-type serviceConfig struct {
-	Title         string
-	Documentation *configDocumentation
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/googleapis/gapic-generator-go/internal/errors"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/genproto/googleapis/api/serviceconfig"
+	"gopkg.in/yaml.v2"
+)
+
+// readServiceConfig decodes the service YAML read from r into the google.api.Service it represents.
+// The YAML is converted to the JSON mapping of google.api.Service, so fields are named as in the
+// proto, and fields unknown to the generator, such as "type", are ignored.
+func readServiceConfig(r io.Reader) (*serviceconfig.Service, error) {
+	var y interface{}
+	if err := yaml.NewDecoder(r).Decode(&y); err != nil {
+		return nil, err
+	}
+	j, err := yamlToJSON(y)
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(j)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &serviceconfig.Service{}
+	u := jsonpb.Unmarshaler{AllowUnknownFields: true}
+	if err := u.Unmarshal(bytes.NewReader(b), config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// yamlToJSON converts the maps decoded by the yaml package, keyed by interface{},
+// into maps that encoding/json can marshal.
+func yamlToJSON(y interface{}) (interface{}, error) {
+	switch y := y.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(y))
+		for k, v := range y {
+			ks, ok := k.(string)
+			if !ok {
+				return nil, errors.E(nil, "service config key %v is not a string", k)
+			}
+			jv, err := yamlToJSON(v)
+			if err != nil {
+				return nil, err
+			}
+			m[ks] = jv
+		}
+		return m, nil
+	case []interface{}:
+		s := make([]interface{}, len(y))
+		for i, v := range y {
+			jv, err := yamlToJSON(v)
+			if err != nil {
+				return nil, err
+			}
+			s[i] = jv
+		}
+		return s, nil
+	default:
+		return y, nil
+	}
+}
+
+// selectorMatches reports whether the rule selector sel selects the element with the
+// fully-qualified name, without leading dot. A selector is a comma-separated list of
+// patterns, each a name that may end in a "*" wildcard matching one or more components.
+func selectorMatches(sel, name string) bool {
+	for _, pat := range strings.Split(sel, ",") {
+		pat = strings.TrimSpace(pat)
+		switch {
+		case pat == "*":
+			return true
+		case strings.HasSuffix(pat, ".*"):
+			if prefix := pat[:len(pat)-1]; strings.HasPrefix(name, prefix) && len(name) > len(prefix) {
+				return true
+			}
+		case pat == name:
+			return true
+		}
+	}
+	return false
+}
+
+// mixinNames reports the fully-qualified names of the APIs listed in the service config.
+func (g *generator) mixinNames() []string {
+	var names []string
+	for _, api := range g.serviceConfig.GetApis() {
+		names = append(names, api.GetName())
+	}
+	return names
+}
+
+// applyServiceConfig applies the rules of the service config to the services of the request.
+// Documentation rules replace the comments and deprecation descriptions of services and methods,
+// and http rules replace the google.api.http annotations of methods. Of the rules selecting
+// an element, the last wins.
+// It must be called after g.init, and before collectMixins copies the mixin methods.
+func (g *generator) applyServiceConfig() error {
+	g.deprecations = map[proto.Message]string{}
+	if g.serviceConfig == nil {
+		return nil
+	}
+
+	docRules := g.serviceConfig.GetDocumentation().GetRules()
+	httpRules := g.serviceConfig.GetHttp().GetRules()
+	for fqn, serv := range g.descInfo.Serv {
+		servName := strings.TrimPrefix(fqn, ".")
+		for _, r := range docRules {
+			if selectorMatches(r.GetSelector(), servName) {
+				g.applyDocumentationRule(serv, r)
+			}
+		}
+
+		for _, m := range serv.GetMethod() {
+			mName := servName + "." + m.GetName()
+			for _, r := range docRules {
+				if selectorMatches(r.GetSelector(), mName) {
+					g.applyDocumentationRule(m, r)
+				}
+			}
+
+			var rule *annotations.HttpRule
+			for _, r := range httpRules {
+				if selectorMatches(r.GetSelector(), mName) {
+					rule = r
+				}
+			}
+			if rule == nil {
+				continue
+			}
+			if m.Options == nil {
+				m.Options = &descriptor.MethodOptions{}
+			}
+			if err := proto.SetExtension(m.Options, annotations.E_Http, rule); err != nil {
+				return errors.E(err, "can't apply http rule of %s", mName)
+			}
+		}
+	}
+	return nil
+}
+
+// applyDocumentationRule applies the documentation rule r to the proto element e.
+func (g *generator) applyDocumentationRule(e proto.Message, r *serviceconfig.DocumentationRule) {
+	if d := r.GetDescription(); d != "" {
+		g.comments[e] = d
+	}
+	if d := r.GetDeprecationDescription(); d != "" {
+		g.deprecations[e] = d
+	}
+}
+
+// backendDeadline reports the deadline of the method with the fully-qualified name,
+// without leading dot, in milliseconds, as configured by the backend rules of the service config.
+func (g *generator) backendDeadline(name string) (int64, bool) {
+	var deadline float64
+	for _, r := range g.serviceConfig.GetBackend().GetRules() {
+		if selectorMatches(r.GetSelector(), name) {
+			deadline = r.GetDeadline()
+		}
+	}
+	if deadline <= 0 {
+		return 0, false
+	}
+	return int64(math.Round(deadline * 1000)), true
+}
+
+// authScopes reports the OAuth scopes that the authentication rules of the service config
+// require for the method with the fully-qualified name, without leading dot.
+func (g *generator) authScopes(name string) []string {
+	var scopes []string
+	for _, r := range g.serviceConfig.GetAuthentication().GetRules() {
+		if selectorMatches(r.GetSelector(), name) {
+			scopes = nil
+			if sc := r.GetOauth().GetCanonicalScopes(); sc != "" {
+				scopes = strings.Split(sc, ",")
+			}
+		}
+	}
+	for i, sc := range scopes {
+		scopes[i] = strings.TrimSpace(sc)
+	}
+	return scopes
 }
 
-// configDocumentation represents gapic service config documentation section
-// Deprecated: workaround for not having annotations yet; to be removed
-type configDocumentation struct {
-	Summary string
+// methodName reports the fully-qualified name, without leading dot, of the method m
+// of the client of serv. The methods of mixins are named after their mixin.
+func (g *generator) methodName(serv *descriptor.ServiceDescriptorProto, m *descriptor.MethodDescriptorProto) string {
+	if _, ok := g.mixinFields[m]; ok {
+		for _, mix := range g.mixins {
+			for _, mm := range mix.methods {
+				if mm == m {
+					serv = mix.serv
+				}
+			}
+		}
+	}
+	return fmt.Sprintf("%s.%s.%s", g.descInfo.ParentFile[serv].GetPackage(), serv.GetName(), m.GetName())
 }
@@ -0,0 +1,170 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gengapic
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/genproto/googleapis/api/annotations"
+)
+
+const fooServiceYAML = `
+type: google.api.Service
+config_version: 3
+name: foo.googleapis.com
+title: Awesome Foo API
+
+apis:
+- name: my.pkg.Foo
+
+documentation:
+  summary: The Foo API is awesome.
+  rules:
+  - selector: my.pkg.Foo.*
+    description: Does foo things.
+  - selector: my.pkg.Foo.Zip
+    description: Zips a foo.
+  - selector: my.pkg.Foo
+    description: Foo service does stuff.
+
+backend:
+  rules:
+  - selector: '*'
+    deadline: 30.0
+  - selector: my.pkg.Foo.Zap
+    deadline: 1.5
+
+http:
+  rules:
+  - selector: my.pkg.Foo.Zip
+    post: '/v1/{name=foos/*}:zip'
+    body: '*'
+
+authentication:
+  rules:
+  - selector: '*'
+    oauth:
+      canonical_scopes: |-
+        https://www.googleapis.com/auth/cloud-platform,
+        https://www.googleapis.com/auth/foo
+  - selector: my.pkg.Foo.Zap
+    oauth:
+      canonical_scopes: https://www.googleapis.com/auth/foo.readonly
+`
+
+func TestSelectorMatches(t *testing.T) {
+	for _, tst := range []struct {
+		sel, name string
+		want      bool
+	}{
+		{sel: "*", name: "my.pkg.Foo.Zip", want: true},
+		{sel: "my.pkg.Foo.Zip", name: "my.pkg.Foo.Zip", want: true},
+		{sel: "my.pkg.Foo.Zap", name: "my.pkg.Foo.Zip", want: false},
+		{sel: "my.pkg.Foo.*", name: "my.pkg.Foo.Zip", want: true},
+		{sel: "my.pkg.*", name: "my.pkg.Foo.Zip", want: true},
+		{sel: "my.pkg.Foo.*", name: "my.pkg.Foo", want: false},
+		{sel: "my.pkg.Fo.*", name: "my.pkg.Foo.Zip", want: false},
+		{sel: "my.pkg.Foo.Zap, my.pkg.Foo.Zip", name: "my.pkg.Foo.Zip", want: true},
+	} {
+		if got := selectorMatches(tst.sel, tst.name); got != tst.want {
+			t.Errorf("selectorMatches(%q, %q) = %t, want %t", tst.sel, tst.name, got, tst.want)
+		}
+	}
+}
+
+func TestServiceConfig(t *testing.T) {
+	zip := &descriptor.MethodDescriptorProto{Name: proto.String("Zip")}
+	zap := &descriptor.MethodDescriptorProto{Name: proto.String("Zap")}
+	serv := &descriptor.ServiceDescriptorProto{
+		Name:   proto.String("Foo"),
+		Method: []*descriptor.MethodDescriptorProto{zip, zap},
+	}
+	file := &descriptor.FileDescriptorProto{
+		Package: proto.String("my.pkg"),
+		Service: []*descriptor.ServiceDescriptorProto{serv},
+	}
+
+	config, err := readServiceConfig(strings.NewReader(fooServiceYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := config.GetTitle(), "Awesome Foo API"; got != want {
+		t.Errorf("title: got %q, want %q", got, want)
+	}
+	if got, want := config.GetDocumentation().GetSummary(), "The Foo API is awesome."; got != want {
+		t.Errorf("summary: got %q, want %q", got, want)
+	}
+
+	var g generator
+	g.serviceConfig = config
+	g.init([]*descriptor.FileDescriptorProto{file})
+	if err := g.applyServiceConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.collectMixins(); err != nil {
+		t.Fatal(err)
+	}
+
+	for elem, want := range map[proto.Message]string{
+		serv: "Foo service does stuff.",
+		zip:  "Zips a foo.",
+		zap:  "Does foo things.",
+	} {
+		if got := g.comments[elem]; got != want {
+			t.Errorf("comment of %v: got %q, want %q", elem, got, want)
+		}
+	}
+
+	eHTTP, err := proto.GetExtension(zip.GetOptions(), annotations.E_Http)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := eHTTP.(*annotations.HttpRule).GetPost(), "/v1/{name=foos/*}:zip"; got != want {
+		t.Errorf("http rule of Zip: got %q, want %q", got, want)
+	}
+	if zap.GetOptions() != nil {
+		t.Errorf("http rule of Zap: got %v, want none", zap.GetOptions())
+	}
+
+	for _, tst := range []struct {
+		m    *descriptor.MethodDescriptorProto
+		want int64
+	}{
+		{m: zip, want: 30000},
+		{m: zap, want: 1500},
+	} {
+		got, ok := g.backendDeadline(g.methodName(serv, tst.m))
+		if !ok || got != tst.want {
+			t.Errorf("backendDeadline(%s) = %d, %t, want %d", tst.m.GetName(), got, ok, tst.want)
+		}
+	}
+
+	scopes, err := g.collectScopes([]*descriptor.ServiceDescriptorProto{serv})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"https://www.googleapis.com/auth/cloud-platform",
+		"https://www.googleapis.com/auth/foo",
+		"https://www.googleapis.com/auth/foo.readonly",
+	}
+	if diff := cmp.Diff(scopes, want); diff != "" {
+		t.Errorf("collectScopes got(-),want(+):\n%s", diff)
+	}
+}
@@ -0,0 +1,534 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gengapic
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/googleapis/gapic-generator-go/internal/pbinfo"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/genproto/googleapis/longrunning"
+)
+
+// The kinds of standard methods, as in https://aip.dev/130.
+const (
+	stdGet    = "Get"
+	stdList   = "List"
+	stdCreate = "Create"
+	stdUpdate = "Update"
+	stdDelete = "Delete"
+)
+
+// stdMethod is a standard method of a resource, which a stateful fake implements
+// by keeping the resources it creates.
+type stdMethod struct {
+	kind string
+	m    *descriptor.MethodDescriptorProto
+
+	// The resource message, and the collection of the resources, e.g. "books" for
+	// the pattern "shelves/{shelf}/books/{book}".
+	res        *descriptor.DescriptorProto
+	nameField  string
+	collection string
+
+	// The field of the request holding the resource, of Create and Update methods,
+	// or the field of the response listing the resources, of List methods.
+	resField *descriptor.FieldDescriptorProto
+
+	// Whether the request has a "parent" field, of Create and List methods,
+	// and the "{resource}_id" field of the request of Create methods, if any.
+	hasParent bool
+	idField   *descriptor.FieldDescriptorProto
+
+	// Whether the request of Update methods has an "update_mask" field.
+	hasMask bool
+
+	// Whether the method returns a long-running operation.
+	lro bool
+}
+
+// httpVerb reports the HTTP verb of the google.api.http annotation of m, or "" if it has none.
+func httpVerb(m *descriptor.MethodDescriptorProto) string {
+	if m.GetOptions() == nil {
+		return ""
+	}
+	eHTTP, err := proto.GetExtension(m.GetOptions(), annotations.E_Http)
+	if err != nil {
+		return ""
+	}
+	rule := eHTTP.(*annotations.HttpRule)
+	switch {
+	case rule.GetGet() != "":
+		return "GET"
+	case rule.GetPost() != "":
+		return "POST"
+	case rule.GetPatch() != "":
+		return "PATCH"
+	case rule.GetPut() != "":
+		return "PUT"
+	case rule.GetDelete() != "":
+		return "DELETE"
+	}
+	return ""
+}
+
+// resourceOf reports the google.api.resource annotation of msg, and the collection of its
+// resources, from the last collection of its first pattern. It reports nil if msg is not a resource,
+// or its pattern does not end with a collection and a variable.
+func resourceOf(msg *descriptor.DescriptorProto) (*annotations.ResourceDescriptor, string) {
+	if msg.GetOptions() == nil {
+		return nil, ""
+	}
+	eRes, err := proto.GetExtension(msg.GetOptions(), annotations.E_Resource)
+	if err != nil {
+		return nil, ""
+	}
+	res := eRes.(*annotations.ResourceDescriptor)
+	if len(res.GetPattern()) == 0 {
+		return nil, ""
+	}
+	segs := strings.Split(res.GetPattern()[0], "/")
+	if len(segs) < 2 {
+		return nil, ""
+	}
+	coll, id := segs[len(segs)-2], segs[len(segs)-1]
+	if strings.ContainsAny(coll, "{}") || !strings.HasPrefix(id, "{") {
+		return nil, ""
+	}
+	return res, coll
+}
+
+// stdMethods reports the standard methods of serv. They are told by their name,
+// the verb of their google.api.http annotation, and the resources of their requests and responses.
+func (g *generator) stdMethods(serv *descriptor.ServiceDescriptorProto) ([]*stdMethod, error) {
+	pkg := g.descInfo.ParentFile[serv].GetPackage()
+
+	var stds []*stdMethod
+	for _, m := range serv.GetMethod() {
+		if m.GetClientStreaming() || m.GetServerStreaming() {
+			continue
+		}
+		in, ok := g.descInfo.Type[m.GetInputType()].(*descriptor.DescriptorProto)
+		if !ok {
+			continue
+		}
+		field := func(name string, typ descriptor.FieldDescriptorProto_Type) *descriptor.FieldDescriptorProto {
+			for _, f := range in.GetField() {
+				if f.GetName() == name && f.GetType() == typ && f.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REPEATED {
+					return f
+				}
+			}
+			return nil
+		}
+
+		// The response, or the response of the operation of LRO methods.
+		out := g.descInfo.Type[m.GetOutputType()]
+		lro := g.isLRO(m)
+		if lro {
+			eLRO, err := proto.GetExtension(m.GetOptions(), longrunning.E_OperationInfo)
+			if err != nil {
+				continue
+			}
+			out = g.descInfo.Resolve(eLRO.(*longrunning.OperationInfo).GetResponseType(), pkg)
+		}
+		outMsg, _ := out.(*descriptor.DescriptorProto)
+
+		std := &stdMethod{m: m, lro: lro, hasParent: field("parent", descriptor.FieldDescriptorProto_TYPE_STRING) != nil}
+		verb := httpVerb(m)
+		switch {
+		case strings.HasPrefix(m.GetName(), stdGet) && verb == "GET":
+			if lro || field("name", descriptor.FieldDescriptorProto_TYPE_STRING) == nil {
+				continue
+			}
+			std.kind, std.res = stdGet, outMsg
+
+		case strings.HasPrefix(m.GetName(), stdList) && verb == "GET":
+			elem, err := g.pagingField(m)
+			if err != nil {
+				return nil, err
+			}
+			if lro || elem == nil || elem.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE {
+				continue
+			}
+			std.kind, std.resField = stdList, elem
+			std.res, _ = g.descInfo.Type[elem.GetTypeName()].(*descriptor.DescriptorProto)
+
+		case strings.HasPrefix(m.GetName(), stdCreate) && verb == "POST",
+			strings.HasPrefix(m.GetName(), stdUpdate) && (verb == "PATCH" || verb == "PUT"):
+			std.kind = stdCreate
+			if strings.HasPrefix(m.GetName(), stdUpdate) {
+				std.kind = stdUpdate
+			}
+			for _, f := range in.GetField() {
+				if f.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE && f.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REPEATED &&
+					g.descInfo.Type[f.GetTypeName()] == out && out != nil {
+					std.resField, std.res = f, outMsg
+					break
+				}
+			}
+			if std.resField == nil {
+				continue
+			}
+			std.idField = field(std.resField.GetName()+"_id", descriptor.FieldDescriptorProto_TYPE_STRING)
+			std.hasMask = field("update_mask", descriptor.FieldDescriptorProto_TYPE_MESSAGE) != nil
+
+		case strings.HasPrefix(m.GetName(), stdDelete) && verb == "DELETE":
+			if field("name", descriptor.FieldDescriptorProto_TYPE_STRING) == nil {
+				continue
+			}
+			if m.GetOutputType() != emptyType && !(lro && out == g.descInfo.Type[emptyType]) {
+				continue
+			}
+			std.kind = stdDelete
+			std.res, _ = g.descInfo.Resolve(strings.TrimPrefix(m.GetName(), stdDelete), pkg).(*descriptor.DescriptorProto)
+
+		default:
+			continue
+		}
+
+		if std.res == nil {
+			continue
+		}
+		res, coll := resourceOf(std.res)
+		if res == nil {
+			continue
+		}
+		std.collection = coll
+		std.nameField = res.GetNameField()
+		if std.nameField == "" {
+			std.nameField = "name"
+		}
+		stds = append(stds, std)
+	}
+	return stds, nil
+}
+
+// genStatefulFake generates the stateful fake server of serv, implementing its standard methods
+// by keeping the resources they create, and the helper creating a client connected to it.
+// Nothing is generated if serv has no standard methods.
+func (g *generator) genStatefulFake(serv *descriptor.ServiceDescriptorProto, pkgName string) error {
+	stds, err := g.stdMethods(serv)
+	if err != nil {
+		return err
+	}
+	if len(stds) == 0 {
+		return nil
+	}
+
+	servName := pbinfo.ReduceServName(serv.GetName(), pkgName)
+	servSpec, err := g.descInfo.ImportSpec(serv)
+	if err != nil {
+		return err
+	}
+	fakeType := "Stateful" + servName + "Server"
+	p := g.printf
+
+	p("// %s is a fake implementation of the %s service that keeps the resources", fakeType, serv.GetName())
+	p("// created with its standard methods, which can then be read, listed, updated and deleted.")
+	p("// Long-running operations of standard methods are done when returned.")
+	p("// Its other methods are those of %sServer.", servName)
+	p("// The zero value is ready to use.")
+	p("type %s struct {", fakeType)
+	p("  %sServer", servName)
+	p("  store")
+	p("}")
+	p("")
+
+	for _, std := range stds {
+		if err := g.statefulMethod(fakeType, std); err != nil {
+			return err
+		}
+	}
+
+	p("// NewStateful%sClient starts srv on an in-memory gRPC server, and returns a client connected to it,", servName)
+	p("// created with opts. Calling stop closes the client and stops the server.")
+	p("// The server also runs a fake Operations service, reporting the long-running operations")
+	p("// returned by srv.")
+	p("func NewStateful%[1]sClient(ctx context.Context, srv *%[2]s, opts ...option.ClientOption) (client *%[3]s.%[1]sClient, stop func(), err error) {",
+		servName, fakeType, pkgName)
+	p("  conn, stopServer, err := serve(ctx, func(s *grpc.Server) {")
+	p("    %s.Register%sServer(s, srv)", servSpec.Name, serv.GetName())
+	p("    longrunningpb.RegisterOperationsServer(s, &operations{fake: &srv.Fake})")
+	p("  })")
+	p("  if err != nil {")
+	p("    return nil, nil, err")
+	p("  }")
+	p("  c, err := %s.New%sClient(ctx, append([]option.ClientOption{option.WithGRPCConn(conn)}, opts...)...)", pkgName, servName)
+	p("  if err != nil {")
+	p("    stopServer()")
+	p("    return nil, nil, err")
+	p("  }")
+	p("  return c, func() {")
+	p("    c.Close()")
+	p("    stopServer()")
+	p("  }, nil")
+	p("}")
+	p("")
+
+	g.imports[pbinfo.ImportSpec{Name: "longrunningpb", Path: "google.golang.org/genproto/googleapis/longrunning"}] = true
+	return nil
+}
+
+// statefulMethod generates the method of the stateful fake server type fakeType implementing std.
+func (g *generator) statefulMethod(fakeType string, std *stdMethod) error {
+	m := std.m
+	inName, inSpec, err := g.descInfo.NameSpec(g.descInfo.Type[m.GetInputType()])
+	if err != nil {
+		return err
+	}
+	outName, outSpec, err := g.descInfo.NameSpec(g.descInfo.Type[m.GetOutputType()])
+	if err != nil {
+		return err
+	}
+	resName, resSpec, err := g.descInfo.NameSpec(std.res)
+	if err != nil {
+		return err
+	}
+	in := fmt.Sprintf("%s.%s", inSpec.Name, inName)
+	out := fmt.Sprintf("%s.%s", outSpec.Name, outName)
+	res := fmt.Sprintf("%s.%s", resSpec.Name, resName)
+	name := m.GetName()
+	nameField := snakeToCamel(std.nameField)
+	parent := `""`
+	if std.hasParent {
+		parent = "req.GetParent()"
+	}
+	// ret generates the return of resp, as is or in a done operation.
+	ret := func(resp string) {
+		if std.lro {
+			g.printf("  return s.done(%q, %s)", name, resp)
+		} else {
+			g.printf("  return %s, nil", resp)
+		}
+	}
+
+	p := g.printf
+	switch std.kind {
+	case stdGet:
+		p("// %s reports the %s named in req.", name, resName)
+		p("func (s *%s) %s(ctx context.Context, req *%s) (*%s, error) {", fakeType, name, in, out)
+		p("  s.record(%q, req)", name)
+		p("  r, ok := s.get(req.GetName())")
+		p("  if !ok {")
+		p("    return nil, status.Errorf(codes.NotFound, \"fake: %%s not found\", req.GetName())")
+		p("  }")
+		p("  return r.(*%s), nil", res)
+		p("}")
+
+	case stdList:
+		p("// %s lists the %s of the parent in req, ordered by name, a page at a time.", name, std.collection)
+		p("func (s *%s) %s(ctx context.Context, req *%s) (*%s, error) {", fakeType, name, in, out)
+		p("  s.record(%q, req)", name)
+		p("  rs := s.list(%s, %q)", parent, std.collection)
+		p("  start, end, next, err := page(len(rs), req.GetPageToken(), req.GetPageSize())")
+		p("  if err != nil {")
+		p("    return nil, err")
+		p("  }")
+		p("  resp := &%s{NextPageToken: next}", out)
+		p("  for _, r := range rs[start:end] {")
+		p("    resp.%[1]s = append(resp.%[1]s, r.(*%[2]s))", snakeToCamel(std.resField.GetName()), res)
+		p("  }")
+		p("  return resp, nil")
+		p("}")
+
+	case stdCreate:
+		field := snakeToCamel(std.resField.GetName())
+		id := `""`
+		if std.idField != nil {
+			id = "req.Get" + snakeToCamel(std.idField.GetName()) + "()"
+		}
+		p("// %s keeps the %s in req, named after the parent and ID in req, if any.", name, resName)
+		p("func (s *%s) %s(ctx context.Context, req *%s) (*%s, error) {", fakeType, name, in, out)
+		p("  s.record(%q, req)", name)
+		p("  if req.Get%s() == nil {", field)
+		p("    return nil, status.Errorf(codes.InvalidArgument, \"fake: missing %s\")", std.resField.GetName())
+		p("  }")
+		p("  r := proto.Clone(req.Get%s()).(*%s)", field, res)
+		p("  r.%s = s.newName(%s, %q, %s)", nameField, parent, std.collection, id)
+		p("  if err := s.create(r.%s, r); err != nil {", nameField)
+		p("    return nil, err")
+		p("  }")
+		ret("proto.Clone(r).(*" + res + ")")
+		p("}")
+
+	case stdUpdate:
+		field := snakeToCamel(std.resField.GetName())
+		p("// %s replaces the %s in req, or the fields of it in the update mask, if any.", name, resName)
+		p("func (s *%s) %s(ctx context.Context, req *%s) (*%s, error) {", fakeType, name, in, out)
+		p("  s.record(%q, req)", name)
+		p("  upd := req.Get%s()", field)
+		p("  s.store.mu.Lock()")
+		p("  defer s.store.mu.Unlock()")
+		if std.hasMask {
+			p("  old, ok := s.resources[upd.Get%s()]", nameField)
+		} else {
+			p("  _, ok := s.resources[upd.Get%s()]", nameField)
+		}
+		p("  if !ok {")
+		p("    return nil, status.Errorf(codes.NotFound, \"fake: %%s not found\", upd.Get%s())", nameField)
+		p("  }")
+		p("  r := proto.Clone(upd).(*%s)", res)
+		if std.hasMask {
+			p("  if paths := req.GetUpdateMask().GetPaths(); len(paths) > 0 {")
+			p("    r = proto.Clone(old).(*%s)", res)
+			p("    for _, path := range paths {")
+			p("      switch path {")
+			for _, f := range std.res.GetField() {
+				if f.OneofIndex != nil || f.GetName() == std.nameField {
+					continue
+				}
+				p("      case %q:", f.GetName())
+				p("        r.%[1]s = upd.%[1]s", snakeToCamel(f.GetName()))
+			}
+			p("      default:")
+			p("        return nil, status.Errorf(codes.InvalidArgument, \"fake: can't update %%s\", path)")
+			p("      }")
+			p("    }")
+			p("  }")
+		}
+		p("  s.resources[r.%s] = r", nameField)
+		ret("proto.Clone(r).(*" + res + ")")
+		p("}")
+
+	case stdDelete:
+		p("// %s deletes the %s named in req.", name, resName)
+		p("func (s *%s) %s(ctx context.Context, req *%s) (*%s, error) {", fakeType, name, in, out)
+		p("  s.record(%q, req)", name)
+		p("  if !s.delete(req.GetName()) {")
+		p("    return nil, status.Errorf(codes.NotFound, \"fake: %%s not found\", req.GetName())")
+		p("  }")
+		ret("&emptypb.Empty{}")
+		p("}")
+		g.imports[pbinfo.ImportSpec{Name: "emptypb", Path: "github.com/golang/protobuf/ptypes/empty"}] = true
+	}
+	p("")
+
+	g.imports[inSpec] = true
+	g.imports[outSpec] = true
+	g.imports[resSpec] = true
+	g.imports[pbinfo.ImportSpec{Path: "context"}] = true
+	g.imports[pbinfo.ImportSpec{Path: "github.com/golang/protobuf/proto"}] = true
+	g.imports[pbinfo.ImportSpec{Path: "google.golang.org/grpc/codes"}] = true
+	g.imports[pbinfo.ImportSpec{Path: "google.golang.org/grpc/status"}] = true
+	return nil
+}
+
+// genStoreSupport generates the store of resources embedded in stateful fakes,
+// and the paging helper of their List methods.
+func (g *generator) genStoreSupport() {
+	p := g.printf
+
+	p("// store keeps the resources of a stateful fake, by name. Its methods are safe for concurrent use.")
+	p("type store struct {")
+	p("  mu        sync.Mutex")
+	p("  resources map[string]proto.Message")
+	p("  count     int")
+	p("}")
+	p("")
+	p("func (st *store) get(name string) (proto.Message, bool) {")
+	p("  st.mu.Lock()")
+	p("  defer st.mu.Unlock()")
+	p("  r, ok := st.resources[name]")
+	p("  if !ok {")
+	p("    return nil, false")
+	p("  }")
+	p("  return proto.Clone(r), true")
+	p("}")
+	p("")
+	p("// create keeps r with name, unless a resource with the name exists.")
+	p("func (st *store) create(name string, r proto.Message) error {")
+	p("  st.mu.Lock()")
+	p("  defer st.mu.Unlock()")
+	p("  if _, ok := st.resources[name]; ok {")
+	p("    return status.Errorf(codes.AlreadyExists, \"fake: %%s already exists\", name)")
+	p("  }")
+	p("  if st.resources == nil {")
+	p("    st.resources = map[string]proto.Message{}")
+	p("  }")
+	p("  st.resources[name] = proto.Clone(r)")
+	p("  return nil")
+	p("}")
+	p("")
+	p("func (st *store) delete(name string) bool {")
+	p("  st.mu.Lock()")
+	p("  defer st.mu.Unlock()")
+	p("  _, ok := st.resources[name]")
+	p("  delete(st.resources, name)")
+	p("  return ok")
+	p("}")
+	p("")
+	p("// newName reports the name of a new resource in the collection of parent, with id,")
+	p("// or with an ID of its own if id is empty.")
+	p("func (st *store) newName(parent, collection, id string) string {")
+	p("  if id == \"\" {")
+	p("    st.mu.Lock()")
+	p("    st.count++")
+	p("    id = fmt.Sprintf(\"%%s-%%d\", strings.TrimSuffix(collection, \"s\"), st.count)")
+	p("    st.mu.Unlock()")
+	p("  }")
+	p("  if parent == \"\" {")
+	p("    return collection + \"/\" + id")
+	p("  }")
+	p("  return parent + \"/\" + collection + \"/\" + id")
+	p("}")
+	p("")
+	p("// list reports the resources in the collection of parent, ordered by name.")
+	p("func (st *store) list(parent, collection string) []proto.Message {")
+	p("  prefix := collection + \"/\"")
+	p("  if parent != \"\" {")
+	p("    prefix = parent + \"/\" + prefix")
+	p("  }")
+	p("")
+	p("  st.mu.Lock()")
+	p("  defer st.mu.Unlock()")
+	p("  var names []string")
+	p("  for name := range st.resources {")
+	p("    if strings.HasPrefix(name, prefix) && !strings.Contains(name[len(prefix):], \"/\") {")
+	p("      names = append(names, name)")
+	p("    }")
+	p("  }")
+	p("  sort.Strings(names)")
+	p("  rs := make([]proto.Message, len(names))")
+	p("  for i, name := range names {")
+	p("    rs[i] = proto.Clone(st.resources[name])")
+	p("  }")
+	p("  return rs")
+	p("}")
+	p("")
+	p("// page reports the bounds of the page of n items starting at the offset of token, of at most")
+	p("// size items, or of all the remaining ones if size is not positive, and the token of the next page.")
+	p("func page(n int, token string, size int32) (start, end int, next string, err error) {")
+	p("  if token != \"\" {")
+	p("    start, err = strconv.Atoi(token)")
+	p("    if err != nil || start < 0 || start > n {")
+	p("      return 0, 0, \"\", status.Errorf(codes.InvalidArgument, \"fake: invalid page token %%q\", token)")
+	p("    }")
+	p("  }")
+	p("  end = n")
+	p("  if size > 0 && start+int(size) < n {")
+	p("    end = start + int(size)")
+	p("    next = strconv.Itoa(end)")
+	p("  }")
+	p("  return start, end, next, nil")
+	p("}")
+	p("")
+
+	for _, path := range []string{"fmt", "sort", "strconv", "strings", "sync"} {
+		g.imports[pbinfo.ImportSpec{Path: path}] = true
+	}
+}
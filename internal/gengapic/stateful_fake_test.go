@@ -0,0 +1,183 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gengapic
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/google/go-cmp/cmp"
+	"github.com/googleapis/gapic-generator-go/internal/txtdiff"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/genproto/googleapis/longrunning"
+)
+
+func TestStatefulFake(t *testing.T) {
+	field := func(name string, typ descriptor.FieldDescriptorProto_Type, typeName string) *descriptor.FieldDescriptorProto {
+		f := &descriptor.FieldDescriptorProto{
+			Name:  proto.String(name),
+			Type:  typ.Enum(),
+			Label: descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	str := descriptor.FieldDescriptorProto_TYPE_STRING
+	msg := descriptor.FieldDescriptorProto_TYPE_MESSAGE
+
+	bookOpts := &descriptor.MessageOptions{}
+	if err := proto.SetExtension(bookOpts, annotations.E_Resource, &annotations.ResourceDescriptor{
+		Type:    "library.googleapis.com/Book",
+		Pattern: []string{"shelves/{shelf}/books/{book}"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	books := field("books", msg, ".my.pkg.Book")
+	books.Label = descriptor.FieldDescriptorProto_LABEL_REPEATED.Enum()
+
+	file := &descriptor.FileDescriptorProto{
+		Package: proto.String("my.pkg"),
+		Options: &descriptor.FileOptions{GoPackage: proto.String("mypackage")},
+		MessageType: []*descriptor.DescriptorProto{
+			{
+				Name: proto.String("Book"),
+				Field: []*descriptor.FieldDescriptorProto{
+					field("name", str, ""),
+					field("title", str, ""),
+					field("author", str, ""),
+				},
+				Options: bookOpts,
+			},
+			{Name: proto.String("GetBookRequest"), Field: []*descriptor.FieldDescriptorProto{field("name", str, "")}},
+			{
+				Name: proto.String("ListBooksRequest"),
+				Field: []*descriptor.FieldDescriptorProto{
+					field("parent", str, ""),
+					field("page_size", descriptor.FieldDescriptorProto_TYPE_INT32, ""),
+					field("page_token", str, ""),
+				},
+			},
+			{
+				Name:  proto.String("ListBooksResponse"),
+				Field: []*descriptor.FieldDescriptorProto{books, field("next_page_token", str, "")},
+			},
+			{
+				Name: proto.String("CreateBookRequest"),
+				Field: []*descriptor.FieldDescriptorProto{
+					field("parent", str, ""),
+					field("book", msg, ".my.pkg.Book"),
+					field("book_id", str, ""),
+				},
+			},
+			{
+				Name: proto.String("UpdateBookRequest"),
+				Field: []*descriptor.FieldDescriptorProto{
+					field("book", msg, ".my.pkg.Book"),
+					field("update_mask", msg, ".google.protobuf.FieldMask"),
+				},
+			},
+			{Name: proto.String("DeleteBookRequest"), Field: []*descriptor.FieldDescriptorProto{field("name", str, "")}},
+		},
+	}
+	wkt := &descriptor.FileDescriptorProto{
+		Package: proto.String("google.protobuf"),
+		Options: &descriptor.FileOptions{GoPackage: proto.String("github.com/golang/protobuf/ptypes/empty;empty")},
+		MessageType: []*descriptor.DescriptorProto{
+			{Name: proto.String("Empty")},
+			{Name: proto.String("FieldMask")},
+		},
+	}
+	lroFile := &descriptor.FileDescriptorProto{
+		Package:     proto.String("google.longrunning"),
+		Options:     &descriptor.FileOptions{GoPackage: proto.String("google.golang.org/genproto/googleapis/longrunning;longrunning")},
+		MessageType: []*descriptor.DescriptorProto{{Name: proto.String("Operation")}},
+	}
+
+	method := func(name, in, out string, rule *annotations.HttpRule, lroResp string) *descriptor.MethodDescriptorProto {
+		m := &descriptor.MethodDescriptorProto{
+			Name:       proto.String(name),
+			InputType:  proto.String(in),
+			OutputType: proto.String(out),
+			Options:    &descriptor.MethodOptions{},
+		}
+		if rule != nil {
+			if err := proto.SetExtension(m.Options, annotations.E_Http, rule); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if lroResp != "" {
+			if err := proto.SetExtension(m.Options, longrunning.E_OperationInfo, &longrunning.OperationInfo{ResponseType: lroResp}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return m
+	}
+	get := func(path string) *annotations.HttpRule {
+		return &annotations.HttpRule{Pattern: &annotations.HttpRule_Get{Get: path}}
+	}
+	serv := &descriptor.ServiceDescriptorProto{
+		Name: proto.String("Library"),
+		Method: []*descriptor.MethodDescriptorProto{
+			method("GetBook", ".my.pkg.GetBookRequest", ".my.pkg.Book", get("/v1/{name=shelves/*/books/*}"), ""),
+			method("ListBooks", ".my.pkg.ListBooksRequest", ".my.pkg.ListBooksResponse", get("/v1/{parent=shelves/*}/books"), ""),
+			method("CreateBook", ".my.pkg.CreateBookRequest", ".google.longrunning.Operation",
+				&annotations.HttpRule{Pattern: &annotations.HttpRule_Post{Post: "/v1/{parent=shelves/*}/books"}, Body: "book"}, "Book"),
+			method("UpdateBook", ".my.pkg.UpdateBookRequest", ".my.pkg.Book",
+				&annotations.HttpRule{Pattern: &annotations.HttpRule_Patch{Patch: "/v1/{book.name=shelves/*/books/*}"}, Body: "book"}, ""),
+			method("DeleteBook", ".my.pkg.DeleteBookRequest", ".google.protobuf.Empty",
+				&annotations.HttpRule{Pattern: &annotations.HttpRule_Delete{Delete: "/v1/{name=shelves/*/books/*}"}}, ""),
+			// Not standard methods: no http annotation, and a verb that doesn't match the name.
+			method("GetBookStats", ".my.pkg.GetBookRequest", ".my.pkg.Book", nil, ""),
+			method("CreateBookReview", ".my.pkg.CreateBookRequest", ".my.pkg.Book", get("/v1/{parent=shelves/*}/books:review"), ""),
+		},
+	}
+	file.Service = []*descriptor.ServiceDescriptorProto{serv}
+
+	var g generator
+	g.init([]*descriptor.FileDescriptorProto{file, wkt, lroFile})
+
+	stds, err := g.stdMethods(serv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, std := range stds {
+		got = append(got, std.kind+" "+std.m.GetName()+" "+std.collection)
+	}
+	want := []string{
+		"Get GetBook books",
+		"List ListBooks books",
+		"Create CreateBook books",
+		"Update UpdateBook books",
+		"Delete DeleteBook books",
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("stdMethods got(-),want(+):\n%s", diff)
+	}
+
+	g.reset()
+	if err := g.genStatefulFake(serv, "library"); err != nil {
+		t.Fatal(err)
+	}
+	txtdiff.Diff(t, "stateful_fake", g.pt.String(), filepath.Join("testdata", "stateful_fake.want"))
+
+	g.reset()
+	g.genStoreSupport()
+	txtdiff.Diff(t, "stateful_store", g.pt.String(), filepath.Join("testdata", "stateful_store.want"))
+}
@@ -14,35 +14,67 @@
 
 package gengapic
 
-import "github.com/golang/protobuf/protoc-gen-go/descriptor"
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/googleapis/gapic-generator-go/internal/pbinfo"
+)
 
 // Used for both bidi and client streaming.
 func (g *generator) noRequestStreamCall(servName string, s *descriptor.ServiceDescriptorProto, m *descriptor.MethodDescriptorProto) error {
-	p := g.printf
+	inType := g.descInfo.Type[m.GetInputType()]
+	inSpec, err := g.descInfo.ImportSpec(inType)
+	if err != nil {
+		return err
+	}
+	g.imports[inSpec] = true
 
-	servSpec, err := g.descInfo.ImportSpec(s)
+	routed, err := hasRequestHeaders(m)
 	if err != nil {
 		return err
 	}
-	g.imports[servSpec] = true
 
-	p("func (c *%sClient) %s(ctx context.Context, opts ...gax.CallOption) (%s.%s_%sClient, error) {",
-		servName, m.GetName(), servSpec.Name, s.GetName(), m.GetName())
-	g.insertMetadata(nil)
-	g.appendCallOpts(m)
-	p("  var resp %s.%s_%sClient", servSpec.Name, s.GetName(), m.GetName())
+	p := g.printf
+	streamType := streamTypeName(m.GetName())
 
-	p("  err := gax.Invoke(ctx, func(ctx context.Context, settings gax.CallSettings) error {")
-	p("    var err error")
-	p("    resp, err = c.%s.%s(ctx, settings.GRPC...)", grpcClientField(servName), m.GetName())
-	p("    return err")
-	p("  }, opts...)")
-	p("  if err != nil {")
-	p("    return nil, err")
+	p("func (c *%sClient) %s(ctx context.Context, opts ...gax.CallOption) (*%s, error) {",
+		servName, m.GetName(), streamType)
+	g.appendCallOpts(m)
+	p("  open := func(ctx context.Context, req *%s.%s) (grpc.ClientStream, error) {", inSpec.Name, inType.GetName())
+	if routed {
+		p("    if req == nil {")
+		p("      ctx = insertMetadata(ctx, c.xGoogMetadata)")
+		p("    } else {")
+		if err := g.insertMetadata(m); err != nil {
+			return err
+		}
+		p("    }")
+	} else {
+		g.insertMetadata(nil)
+	}
+	p("    var resp grpc.ClientStream")
+	p("    err := gax.Invoke(ctx, func(ctx context.Context, settings gax.CallSettings) error {")
+	p("      var err error")
+	p("      resp, err = c.%s.%s(ctx, settings.GRPC...)", g.methodClientField(servName, m), m.GetName())
+	p("      return err")
+	p("    }, opts...)")
+	p("    return resp, err")
 	p("  }")
-	p("  return resp, nil")
+	if routed {
+		p("  // The request headers are derived from the first request, so the stream is opened by the first Send.")
+		p("  return &%s{ctx: ctx, open: open, opened: make(chan struct{})}, nil", streamType)
+	} else {
+		p("  s := &%s{ctx: ctx, open: open, opened: make(chan struct{})}", streamType)
+		p("  if err := s.init(nil); err != nil {")
+		p("    return nil, err")
+		p("  }")
+		p("  return s, nil")
+	}
 	p("}")
 	p("")
+
+	g.imports[pbinfo.ImportSpec{Path: "google.golang.org/grpc"}] = true
 	return nil
 }
 
@@ -55,36 +87,291 @@ func (g *generator) serverStreamCall(servName string, s *descriptor.ServiceDescr
 	}
 	g.imports[inSpec] = true
 
-	servSpec, err := g.descInfo.ImportSpec(s)
+	p := g.printf
+	streamType := streamTypeName(m.GetName())
+
+	p("func (c *%sClient) %s(ctx context.Context, req *%s.%s, opts ...gax.CallOption) (*%s, error) {",
+		servName, m.GetName(), inSpec.Name, inType.GetName(), streamType)
+	if err := g.validateRequest(m, "nil, err"); err != nil {
+		return err
+	}
+	g.appendCallOpts(m)
+	p("  open := func(ctx context.Context) (grpc.ClientStream, error) {")
+	if err := g.insertMetadata(m); err != nil {
+		return err
+	}
+	p("    var resp grpc.ClientStream")
+	p("    err := gax.Invoke(ctx, func(ctx context.Context, settings gax.CallSettings) error {")
+	p("      var err error")
+	p("      resp, err = %s", g.grpcClientCall(servName, m))
+	p("      return err")
+	p("    }, opts...)")
+	p("    return resp, err")
+	p("  }")
+	p("  stream, err := open(ctx)")
+	p("  if err != nil {")
+	p("    return nil, err")
+	p("  }")
+	p("  return &%s{ctx: ctx, open: open, stream: stream, retryer: streamRetryer(opts)}, nil", streamType)
+	p("}")
+	p("")
+
+	g.imports[pbinfo.ImportSpec{Path: "google.golang.org/grpc"}] = true
+	return nil
+}
+
+// streamType generates the "FooStream" type of the streaming method m, returned by its client method.
+// The type only exposes the typed messages of m, so that the gRPC stream it wraps is an implementation detail.
+func (g *generator) streamType(m *descriptor.MethodDescriptorProto) error {
+	inType := g.descInfo.Type[m.GetInputType()]
+	inName, inSpec, err := g.descInfo.NameSpec(inType)
 	if err != nil {
 		return err
 	}
-	g.imports[servSpec] = true
+	outType := g.descInfo.Type[m.GetOutputType()]
+	outName, outSpec, err := g.descInfo.NameSpec(outType)
+	if err != nil {
+		return err
+	}
+	in := fmt.Sprintf("%s.%s", inSpec.Name, inName)
+	out := fmt.Sprintf("%s.%s", outSpec.Name, outName)
 
 	p := g.printf
+	typeName := streamTypeName(m.GetName())
+	sends := m.GetClientStreaming()
 
-	p("func (c *%sClient) %s(ctx context.Context, req *%s.%s, opts ...gax.CallOption) (%s.%s_%sClient, error) {",
-		servName, m.GetName(), inSpec.Name, inType.GetName(), servSpec.Name, s.GetName(), m.GetName())
+	switch {
+	case sends && m.GetServerStreaming():
+		p("// %s is the bidirectional stream of a %s call.", typeName, m.GetName())
+	case sends:
+		p("// %s is the client stream of a %s call.", typeName, m.GetName())
+	default:
+		p("// %s is the server stream of a %s call.", typeName, m.GetName())
+	}
+	g.deprecationNotice(m, typeName)
+	p("type %s struct {", typeName)
+	p("  ctx context.Context")
+	if sends {
+		p("  // open opens the stream with the request headers derived from req, if not nil.")
+		p("  open func(ctx context.Context, req *%s) (grpc.ClientStream, error)", in)
+	} else {
+		p("  open func(ctx context.Context) (grpc.ClientStream, error)")
+	}
+	if sends {
+		p("")
+		p("  once sync.Once")
+		p("  // opened is closed once the stream is opened, or failed to open.")
+		p("  opened chan struct{}")
+		p("  stream grpc.ClientStream")
+		p("  err error")
+	} else {
+		p("  stream grpc.ClientStream")
+		p("  retryer gax.Retryer")
+		p("  received bool")
+	}
+	p("}")
+	p("")
 
-	err = g.insertMetadata(m)
-	if err != nil {
-		return err
+	if sends {
+		p("// init opens the stream with the request headers derived from req, unless it was already opened.")
+		p("func (s *%s) init(req *%s) error {", typeName, in)
+		p("  s.once.Do(func() {")
+		p("    s.stream, s.err = s.open(s.ctx, req)")
+		p("    close(s.opened)")
+		p("  })")
+		p("  return s.err")
+		p("}")
+		p("")
+
+		p("// wait waits for the stream to be opened by the first Send, so that it is opened with")
+		p("// the request headers derived from the first request.")
+		p("func (s *%s) wait() error {", typeName)
+		p("  select {")
+		p("  case <-s.opened:")
+		p("    return s.err")
+		p("  case <-s.ctx.Done():")
+		p("    return s.ctx.Err()")
+		p("  }")
+		p("}")
+		p("")
+
+		p("// current returns the stream if it is open, or nil.")
+		p("func (s *%s) current() grpc.ClientStream {", typeName)
+		p("  select {")
+		p("  case <-s.opened:")
+		p("    return s.stream")
+		p("  default:")
+		p("    return nil")
+		p("  }")
+		p("}")
+		p("")
+
+		p("// Send sends a request on the stream.")
+		p("func (s *%s) Send(req *%s) error {", typeName, in)
+		if err := g.validateRequest(m, "err"); err != nil {
+			return err
+		}
+		p("  if err := s.init(req); err != nil {")
+		p("    return err")
+		p("  }")
+		p("  return s.stream.SendMsg(req)")
+		p("}")
+		p("")
 	}
 
-	g.appendCallOpts(m)
-	p("  var resp %s.%s_%sClient", servSpec.Name, s.GetName(), m.GetName())
-	p("err := gax.Invoke(ctx, func(ctx context.Context, settings gax.CallSettings) error {")
-	p("  var err error")
-	p("  resp, err = %s", grpcClientCall(servName, m.GetName()))
-	p("  return err")
-	p("}, opts...)")
-	p("if err != nil {")
-	p("  return nil, err")
+	switch {
+	case sends && m.GetServerStreaming():
+		p("// Recv receives the next response of the stream. It returns io.EOF once the server has closed the stream.")
+		p("func (s *%s) Recv() (*%s, error) {", typeName, out)
+		p("  if err := s.wait(); err != nil {")
+		p("    return nil, err")
+		p("  }")
+		p("  resp := new(%s)", out)
+		p("  if err := s.stream.RecvMsg(resp); err != nil {")
+		p("    return nil, err")
+		p("  }")
+		p("  return resp, nil")
+		p("}")
+		p("")
+	case sends:
+		p("// CloseAndRecv closes the sending side of the stream, and receives the response.")
+		p("// It must not be called concurrently with Send.")
+		p("func (s *%s) CloseAndRecv() (*%s, error) {", typeName, out)
+		p("  // Unless a request was sent, which opened the stream, there are no request headers to wait for.")
+		p("  if err := s.init(nil); err != nil {")
+		p("    return nil, err")
+		p("  }")
+		p("  if err := s.stream.CloseSend(); err != nil {")
+		p("    return nil, err")
+		p("  }")
+		p("  resp := new(%s)", out)
+		p("  if err := s.stream.RecvMsg(resp); err != nil {")
+		p("    return nil, err")
+		p("  }")
+		p("  return resp, nil")
+		p("}")
+		p("")
+	default:
+		p("// Recv receives the next response of the stream. It returns io.EOF once the server has closed the stream.")
+		p("// Errors received before the first response are retried according to the retry settings of the call,")
+		p("// by opening the stream again.")
+		p("func (s *%s) Recv() (*%s, error) {", typeName, out)
+		p("  for {")
+		p("    resp := new(%s)", out)
+		p("    err := s.stream.RecvMsg(resp)")
+		p("    if err == nil {")
+		p("      s.received = true")
+		p("      return resp, nil")
+		p("    }")
+		p("    if err == io.EOF || s.received || s.retryer == nil {")
+		p("      return nil, err")
+		p("    }")
+		p("    pause, shouldRetry := s.retryer.Retry(err)")
+		p("    if !shouldRetry {")
+		p("      return nil, err")
+		p("    }")
+		p("    if err := gax.Sleep(s.ctx, pause); err != nil {")
+		p("      return nil, err")
+		p("    }")
+		p("    stream, err := s.open(s.ctx)")
+		p("    if err != nil {")
+		p("      return nil, err")
+		p("    }")
+		p("    s.stream = stream")
+		p("  }")
+		p("}")
+		p("")
+		g.imports[pbinfo.ImportSpec{Path: "io"}] = true
+	}
+
+	p("// CloseSend closes the sending side of the stream.")
+	if sends {
+		p("// It must not be called concurrently with Send.")
+	}
+	p("func (s *%s) CloseSend() error {", typeName)
+	if sends {
+		p("  // Unless a request was sent, which opened the stream, there are no request headers to wait for.")
+		p("  if err := s.init(nil); err != nil {")
+		p("    return err")
+		p("  }")
+	}
+	p("  return s.stream.CloseSend()")
+	p("}")
+	p("")
+
+	p("// Header returns the header metadata received from the server, waiting for it if needed.")
+	p("func (s *%s) Header() (metadata.MD, error) {", typeName)
+	if sends {
+		p("  if err := s.wait(); err != nil {")
+		p("    return nil, err")
+		p("  }")
+	}
+	p("  return s.stream.Header()")
+	p("}")
+	p("")
+
+	p("// Trailer returns the trailer metadata received from the server, once the stream has ended.")
+	p("func (s *%s) Trailer() metadata.MD {", typeName)
+	if sends {
+		p("  stream := s.current()")
+		p("  if stream == nil {")
+		p("    return nil")
+		p("  }")
+		p("  return stream.Trailer()")
+	} else {
+		p("  return s.stream.Trailer()")
+	}
 	p("}")
-	p("return resp, nil")
+	p("")
 
+	p("// Context returns the context of the stream.")
+	p("func (s *%s) Context() context.Context {", typeName)
+	if sends {
+		p("  stream := s.current()")
+		p("  if stream == nil {")
+		p("    return s.ctx")
+		p("  }")
+		p("  return stream.Context()")
+	} else {
+		p("  return s.stream.Context()")
+	}
 	p("}")
 	p("")
 
+	g.imports[inSpec] = true
+	g.imports[outSpec] = true
+	g.imports[pbinfo.ImportSpec{Path: "context"}] = true
+	g.imports[pbinfo.ImportSpec{Path: "google.golang.org/grpc"}] = true
+	g.imports[pbinfo.ImportSpec{Path: "google.golang.org/grpc/metadata"}] = true
+	if sends {
+		g.imports[pbinfo.ImportSpec{Path: "sync"}] = true
+	} else {
+		g.imports[pbinfo.ImportSpec{Name: "gax", Path: "github.com/googleapis/gax-go/v2"}] = true
+	}
 	return nil
 }
+
+// streamRetryer generates streamRetryer, which server streams use to retry
+// errors received before their first response.
+func (g *generator) streamRetryer() {
+	p := g.printf
+
+	p("// streamRetryer returns the Retryer of the retry settings in opts, or nil if there are none.")
+	p("func streamRetryer(opts []gax.CallOption) gax.Retryer {")
+	p("  var settings gax.CallSettings")
+	p("  for _, opt := range opts {")
+	p("    opt.Resolve(&settings)")
+	p("  }")
+	p("  if settings.Retry == nil {")
+	p("    return nil")
+	p("  }")
+	p("  return settings.Retry()")
+	p("}")
+	p("")
+
+	g.imports[pbinfo.ImportSpec{Name: "gax", Path: "github.com/googleapis/gax-go/v2"}] = true
+}
+
+func streamTypeName(methodName string) string {
+	return methodName + "Stream"
+}
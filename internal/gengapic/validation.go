@@ -0,0 +1,330 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gengapic
+
+import (
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/googleapis/gapic-generator-go/internal/pbinfo"
+	"google.golang.org/genproto/googleapis/api/annotations"
+)
+
+// fieldBehavior reports whether the google.api.field_behavior annotation of f
+// marks it as REQUIRED, and as OUTPUT_ONLY.
+func fieldBehavior(f *descriptor.FieldDescriptorProto) (required, outputOnly bool) {
+	if f.GetOptions() == nil {
+		return false, false
+	}
+	eBehav, err := proto.GetExtension(f.GetOptions(), annotations.E_FieldBehavior)
+	if err != nil {
+		return false, false
+	}
+	for _, b := range eBehav.([]annotations.FieldBehavior) {
+		switch b {
+		case annotations.FieldBehavior_REQUIRED:
+			required = true
+		case annotations.FieldBehavior_OUTPUT_ONLY:
+			outputOnly = true
+		}
+	}
+	return required, outputOnly
+}
+
+func isRequired(f *descriptor.FieldDescriptorProto) bool {
+	required, _ := fieldBehavior(f)
+	return required
+}
+
+func isOutputOnly(f *descriptor.FieldDescriptorProto) bool {
+	_, outputOnly := fieldBehavior(f)
+	return outputOnly
+}
+
+// fieldMessage reports the message of the values of the message field f,
+// or nil if f is not a message field, or is a map field.
+func (g *generator) fieldMessage(f *descriptor.FieldDescriptorProto) *descriptor.DescriptorProto {
+	if f.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE || g.mapEntry(f) != nil {
+		return nil
+	}
+	msg, _ := g.descInfo.Type[f.GetTypeName()].(*descriptor.DescriptorProto)
+	return msg
+}
+
+// reaches reports whether msg, or a message in its message fields, however deeply nested,
+// has a field for which pred is true. Messages in seen are not searched again.
+func (g *generator) reaches(msg *descriptor.DescriptorProto, pred func(*descriptor.FieldDescriptorProto) bool, seen map[*descriptor.DescriptorProto]bool) bool {
+	if msg == nil || seen[msg] {
+		return false
+	}
+	seen[msg] = true
+	for _, f := range msg.GetField() {
+		if pred(f) || g.reaches(g.fieldMessage(f), pred, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasPresence reports whether the Go field of the singular scalar field f, declared in a file
+// of syntax proto2, is a pointer, so that a field set to its zero value can be told from an unset one.
+func (g *generator) hasPresence(msg *descriptor.DescriptorProto, f *descriptor.FieldDescriptorProto) bool {
+	top := pbinfo.ProtoType(msg)
+	for parent := g.descInfo.ParentElement[top]; parent != nil; parent = g.descInfo.ParentElement[top] {
+		top = parent
+	}
+	return g.descInfo.ParentFile[top].GetSyntax() != "proto3" &&
+		f.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REPEATED &&
+		f.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE &&
+		f.GetType() != descriptor.FieldDescriptorProto_TYPE_BYTES &&
+		f.OneofIndex == nil
+}
+
+// validateRequest generates code that returns retErr from the client method of m
+// if a REQUIRED field of req is not set, when the validate-required option is set.
+func (g *generator) validateRequest(m *descriptor.MethodDescriptorProto, retErr string) error {
+	if !g.validateRequired {
+		return nil
+	}
+	msg, ok := g.descInfo.Type[m.GetInputType()].(*descriptor.DescriptorProto)
+	if !ok || !g.reaches(msg, isRequired, map[*descriptor.DescriptorProto]bool{}) {
+		return nil
+	}
+	name, err := g.addValidator(msg)
+	if err != nil {
+		return err
+	}
+	g.printf("if err := %s(req, \"\"); err != nil {", name)
+	g.printf("  return %s", retErr)
+	g.printf("}")
+	return nil
+}
+
+// clearOutputOnly generates code that clears the OUTPUT_ONLY fields of req, a copy of the request
+// of m owned by the client method, when the validate-required option is set. The server ignores them.
+func (g *generator) clearOutputOnly(m *descriptor.MethodDescriptorProto) error {
+	if !g.validateRequired {
+		return nil
+	}
+	msg, ok := g.descInfo.Type[m.GetInputType()].(*descriptor.DescriptorProto)
+	if !ok || !g.reaches(msg, isOutputOnly, map[*descriptor.DescriptorProto]bool{}) {
+		return nil
+	}
+	name, err := g.addOutputOnlyClearer(msg)
+	if err != nil {
+		return err
+	}
+	g.printf("%s(req)", name)
+	return nil
+}
+
+// addValidator records that the validator of msg must be generated, and reports its name.
+func (g *generator) addValidator(msg *descriptor.DescriptorProto) (string, error) {
+	goName, _, err := g.descInfo.NameSpec(msg)
+	if err != nil {
+		return "", err
+	}
+	name := "validate" + goName
+	g.aux.validators[name] = msg
+	return name, nil
+}
+
+// addOutputOnlyClearer records that the function clearing the OUTPUT_ONLY fields of msg
+// must be generated, and reports its name.
+func (g *generator) addOutputOnlyClearer(msg *descriptor.DescriptorProto) (string, error) {
+	goName, _, err := g.descInfo.NameSpec(msg)
+	if err != nil {
+		return "", err
+	}
+	name := "clearOutputOnly" + goName
+	g.aux.outputOnlyClearers[name] = msg
+	return name, nil
+}
+
+// genValidators generates the validators recorded in g.aux, and those of the messages they
+// validate the fields of, sorted by name.
+func (g *generator) genValidators() error {
+	done := map[string]bool{}
+	for {
+		var names []string
+		for name := range g.aux.validators {
+			if !done[name] {
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			return nil
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			done[name] = true
+			if err := g.validator(name, g.aux.validators[name]); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// validator generates the function named name that checks that the REQUIRED fields of msg,
+// and of the messages in its fields, are set. REQUIRED numeric, enum and bool fields without presence,
+// such as those of proto3 messages, are not checked, since their zero value can't be told from unset.
+func (g *generator) validator(name string, msg *descriptor.DescriptorProto) error {
+	goName, spec, err := g.descInfo.NameSpec(msg)
+	if err != nil {
+		return err
+	}
+	p := g.printf
+
+	p("// %s returns an InvalidArgument error if a REQUIRED field of m, or of the messages in its fields,", name)
+	p("// is not set. prefix is the path of m in the request, used in the error.")
+	p("func %s(m *%s.%s, prefix string) error {", name, spec.Name, goName)
+	p("  if m == nil {")
+	p("    return nil")
+	p("  }")
+	for _, f := range msg.GetField() {
+		getter := "m.Get" + snakeToCamel(f.GetName()) + "()"
+		if isRequired(f) {
+			var unset string
+			switch {
+			case g.hasPresence(msg, f):
+				unset = "m." + snakeToCamel(f.GetName()) + " == nil"
+			case f.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED,
+				f.GetType() == descriptor.FieldDescriptorProto_TYPE_STRING,
+				f.GetType() == descriptor.FieldDescriptorProto_TYPE_BYTES:
+				unset = "len(" + getter + ") == 0"
+			case f.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+				unset = getter + " == nil"
+			}
+			if unset != "" {
+				p("  if %s {", unset)
+				p("    return status.Errorf(codes.InvalidArgument, \"missing required field %%s%s\", prefix)", f.GetName())
+				p("  }")
+			}
+		}
+
+		nested := g.fieldMessage(f)
+		if !g.reaches(nested, isRequired, map[*descriptor.DescriptorProto]bool{}) {
+			continue
+		}
+		nestedName, err := g.addValidator(nested)
+		if err != nil {
+			return err
+		}
+		if f.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+			p("  for i, v := range %s {", getter)
+			p("    if err := %s(v, fmt.Sprintf(\"%%s%s[%%d].\", prefix, i)); err != nil {", nestedName, f.GetName())
+			p("      return err")
+			p("    }")
+			p("  }")
+			g.imports[pbinfo.ImportSpec{Path: "fmt"}] = true
+		} else {
+			p("  if err := %s(%s, prefix+%q); err != nil {", nestedName, getter, f.GetName()+".")
+			p("    return err")
+			p("  }")
+		}
+	}
+	p("  return nil")
+	p("}")
+	p("")
+
+	g.imports[spec] = true
+	g.imports[pbinfo.ImportSpec{Path: "google.golang.org/grpc/codes"}] = true
+	g.imports[pbinfo.ImportSpec{Path: "google.golang.org/grpc/status"}] = true
+	return nil
+}
+
+// genOutputOnlyClearers generates the functions recorded in g.aux that clear OUTPUT_ONLY fields,
+// and those of the messages in their fields, sorted by name.
+func (g *generator) genOutputOnlyClearers() error {
+	done := map[string]bool{}
+	for {
+		var names []string
+		for name := range g.aux.outputOnlyClearers {
+			if !done[name] {
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			return nil
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			done[name] = true
+			if err := g.outputOnlyClearer(name, g.aux.outputOnlyClearers[name]); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// outputOnlyClearer generates the function named name that clears the OUTPUT_ONLY fields of msg,
+// and of the messages in its fields. Fields of oneofs are left alone.
+func (g *generator) outputOnlyClearer(name string, msg *descriptor.DescriptorProto) error {
+	goName, spec, err := g.descInfo.NameSpec(msg)
+	if err != nil {
+		return err
+	}
+	p := g.printf
+
+	p("// %s clears the OUTPUT_ONLY fields of m, and of the messages in its fields.", name)
+	p("func %s(m *%s.%s) {", name, spec.Name, goName)
+	p("  if m == nil {")
+	p("    return")
+	p("  }")
+	for _, f := range msg.GetField() {
+		if f.OneofIndex != nil {
+			continue
+		}
+		field := "m." + snakeToCamel(f.GetName())
+		if isOutputOnly(f) {
+			switch {
+			case g.hasPresence(msg, f),
+				f.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED,
+				f.GetType() == descriptor.FieldDescriptorProto_TYPE_MESSAGE,
+				f.GetType() == descriptor.FieldDescriptorProto_TYPE_BYTES:
+				p("  %s = nil", field)
+			case f.GetType() == descriptor.FieldDescriptorProto_TYPE_STRING:
+				p("  %s = \"\"", field)
+			case f.GetType() == descriptor.FieldDescriptorProto_TYPE_BOOL:
+				p("  %s = false", field)
+			default:
+				p("  %s = 0", field)
+			}
+			continue
+		}
+
+		nested := g.fieldMessage(f)
+		if !g.reaches(nested, isOutputOnly, map[*descriptor.DescriptorProto]bool{}) {
+			continue
+		}
+		nestedName, err := g.addOutputOnlyClearer(nested)
+		if err != nil {
+			return err
+		}
+		if f.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+			p("  for _, v := range %s {", field)
+			p("    %s(v)", nestedName)
+			p("  }")
+		} else {
+			p("  %s(%s)", nestedName, field)
+		}
+	}
+	p("}")
+	p("")
+
+	g.imports[spec] = true
+	return nil
+}
@@ -0,0 +1,152 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gengapic
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/googleapis/gapic-generator-go/internal/txtdiff"
+	"google.golang.org/genproto/googleapis/api/annotations"
+)
+
+func TestValidation(t *testing.T) {
+	field := func(name string, typ descriptor.FieldDescriptorProto_Type, typeName string, behavior ...annotations.FieldBehavior) *descriptor.FieldDescriptorProto {
+		f := &descriptor.FieldDescriptorProto{
+			Name:  proto.String(name),
+			Type:  typ.Enum(),
+			Label: descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		if len(behavior) > 0 {
+			f.Options = &descriptor.FieldOptions{}
+			if err := proto.SetExtension(f.Options, annotations.E_FieldBehavior, behavior); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return f
+	}
+	repeated := func(f *descriptor.FieldDescriptorProto) *descriptor.FieldDescriptorProto {
+		f.Label = descriptor.FieldDescriptorProto_LABEL_REPEATED.Enum()
+		return f
+	}
+	required := annotations.FieldBehavior_REQUIRED
+	outputOnly := annotations.FieldBehavior_OUTPUT_ONLY
+
+	file := &descriptor.FileDescriptorProto{
+		Package: proto.String("my.pkg"),
+		Syntax:  proto.String("proto3"),
+		Options: &descriptor.FileOptions{GoPackage: proto.String("mypackage")},
+		MessageType: []*descriptor.DescriptorProto{
+			{
+				Name: proto.String("CreateBookRequest"),
+				Field: []*descriptor.FieldDescriptorProto{
+					field("parent", descriptor.FieldDescriptorProto_TYPE_STRING, "", required),
+					field("book", descriptor.FieldDescriptorProto_TYPE_MESSAGE, ".my.pkg.Book", required),
+					repeated(field("tags", descriptor.FieldDescriptorProto_TYPE_MESSAGE, ".my.pkg.Tag")),
+					field("validate_only", descriptor.FieldDescriptorProto_TYPE_BOOL, "", required),
+				},
+			},
+			{
+				Name: proto.String("Book"),
+				Field: []*descriptor.FieldDescriptorProto{
+					field("name", descriptor.FieldDescriptorProto_TYPE_STRING, "", outputOnly),
+					field("title", descriptor.FieldDescriptorProto_TYPE_STRING, "", required),
+					field("pages", descriptor.FieldDescriptorProto_TYPE_INT32, "", required),
+					field("update_time", descriptor.FieldDescriptorProto_TYPE_MESSAGE, ".google.protobuf.Timestamp", outputOnly),
+					field("sequel", descriptor.FieldDescriptorProto_TYPE_MESSAGE, ".my.pkg.Book"),
+				},
+			},
+			{
+				Name: proto.String("ListBooksRequest"),
+				Field: []*descriptor.FieldDescriptorProto{
+					field("parent", descriptor.FieldDescriptorProto_TYPE_STRING, "", required),
+					field("page_size", descriptor.FieldDescriptorProto_TYPE_INT32, ""),
+					field("page_token", descriptor.FieldDescriptorProto_TYPE_STRING, ""),
+					field("example", descriptor.FieldDescriptorProto_TYPE_MESSAGE, ".my.pkg.Book"),
+				},
+			},
+			{
+				Name: proto.String("ListBooksResponse"),
+				Field: []*descriptor.FieldDescriptorProto{
+					repeated(field("books", descriptor.FieldDescriptorProto_TYPE_MESSAGE, ".my.pkg.Book")),
+					field("next_page_token", descriptor.FieldDescriptorProto_TYPE_STRING, ""),
+				},
+			},
+			{
+				Name: proto.String("Tag"),
+				Field: []*descriptor.FieldDescriptorProto{
+					field("key", descriptor.FieldDescriptorProto_TYPE_STRING, "", required),
+					field("created_by", descriptor.FieldDescriptorProto_TYPE_STRING, "", outputOnly),
+				},
+			},
+		},
+		Service: []*descriptor.ServiceDescriptorProto{
+			{
+				Name: proto.String("Foo"),
+				Method: []*descriptor.MethodDescriptorProto{
+					{
+						Name:       proto.String("CreateBook"),
+						InputType:  proto.String(".my.pkg.CreateBookRequest"),
+						OutputType: proto.String(".my.pkg.Book"),
+					},
+					{
+						Name:       proto.String("ListBooks"),
+						InputType:  proto.String(".my.pkg.ListBooksRequest"),
+						OutputType: proto.String(".my.pkg.ListBooksResponse"),
+					},
+				},
+			},
+		},
+	}
+	timestamp := &descriptor.FileDescriptorProto{
+		Package:     proto.String("google.protobuf"),
+		Syntax:      proto.String("proto3"),
+		Options:     &descriptor.FileOptions{GoPackage: proto.String("github.com/golang/protobuf/ptypes/timestamp")},
+		MessageType: []*descriptor.DescriptorProto{{Name: proto.String("Timestamp")}},
+	}
+
+	var g generator
+	g.init([]*descriptor.FileDescriptorProto{file, timestamp})
+	serv := file.GetService()[0]
+
+	for _, tst := range []struct {
+		name             string
+		validateRequired bool
+	}{
+		{name: "validation_off"},
+		{name: "validation", validateRequired: true},
+	} {
+		g.validateRequired = tst.validateRequired
+		g.reset()
+		g.aux = newAuxTypes()
+		for _, m := range serv.GetMethod() {
+			if err := g.genMethod("Foo", serv, m); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := g.genValidators(); err != nil {
+			t.Fatal(err)
+		}
+		if err := g.genOutputOnlyClearers(); err != nil {
+			t.Fatal(err)
+		}
+		txtdiff.Diff(t, tst.name, g.pt.String(), filepath.Join("testdata", tst.name+".want"))
+	}
+}
@@ -31,6 +31,10 @@ type GAPICMethod struct {
 	FieldNamePatterns map[string]string `yaml:"field_name_patterns"`
 
 	LongRunning LongRunningConfig `yaml:"long_running"`
+
+	// CustomOperation marks a method that returns an operation message other than
+	// google.longrunning.Operation as long-running.
+	CustomOperation *CustomOperationConfig `yaml:"custom_operation"`
 }
 
 type ResourceName struct {
@@ -38,8 +42,45 @@ type ResourceName struct {
 	NamePattern string `yaml:"name_pattern"`
 }
 
-// All other fields are left out because samples do not need to know polling config, and we are moving to annotations anyway
+// LongRunningConfig describes the operation of a long-running method, used by samples,
+// and how the Wait method of the operation polls it, used by clients.
 type LongRunningConfig struct {
 	ReturnType   string `yaml:"return_type"`
 	MetadataType string `yaml:"metadata_type"`
+
+	InitialPollDelayMillis int64   `yaml:"initial_poll_delay_millis"`
+	PollDelayMultiplier    float64 `yaml:"poll_delay_multiplier"`
+	MaxPollDelayMillis     int64   `yaml:"max_poll_delay_millis"`
+	TotalPollTimeoutMillis int64   `yaml:"total_poll_timeout_millis"`
+}
+
+// CustomOperationConfig describes how to poll and interpret a custom operation message.
+// The polling settings of the method are taken from its long_running config, as for
+// google.longrunning.Operation.
+type CustomOperationConfig struct {
+	// Name of the method of the same service that fetches the latest state of the operation.
+	// It must return the operation message.
+	PollingMethod string `yaml:"polling_method"`
+
+	// Field of the polling request set to the name of the operation. Defaults to "name".
+	PollingNameField string `yaml:"polling_name_field"`
+
+	// Fields of the polling request copied from the field of the same name
+	// in the request that started the operation, e.g. "project".
+	PollingRequestFields []string `yaml:"polling_request_fields"`
+
+	// Field of the operation holding its name. Defaults to "name".
+	NameField string `yaml:"name_field"`
+
+	// Enum field of the operation holding its status. Defaults to "status".
+	StatusField string `yaml:"status_field"`
+
+	// Value of the status field once the operation has completed. Defaults to "DONE".
+	DoneValue string `yaml:"done_value"`
+
+	// Optional int32 field of the operation holding the google.rpc.Code it failed with.
+	ErrorCodeField string `yaml:"error_code_field"`
+
+	// Optional string field of the operation holding the message of the error it failed with.
+	ErrorMessageField string `yaml:"error_message_field"`
 }
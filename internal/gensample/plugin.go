@@ -21,44 +21,55 @@ import (
 
 	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
 	"github.com/googleapis/gapic-generator-go/internal/errors"
+	"github.com/googleapis/gapic-generator-go/internal/options"
 )
 
 const (
 	paramError = "need parameter in format: go-gapic-package=client/import/path;packageName"
 )
 
+// Options are the options of SampleGen as a protoc plugin.
+var Options = options.NewSet(
+	&options.Option{
+		Name:     "go-gapic-package",
+		Value:    "path;name",
+		Usage:    "the import path and name of the package of the clients, e.g. cloud.google.com/go/foo/apiv1;foo",
+		Required: true,
+	},
+	&options.Option{
+		Name:  "gapic-config",
+		Kind:  options.File,
+		Value: "path",
+		Usage: "the GAPIC config of the API, in YAML",
+	},
+	&options.Option{
+		Name:     "sample",
+		Kind:     options.File,
+		Value:    "path",
+		Usage:    "a sample config, in YAML, whose samples are generated",
+		Repeated: true,
+	},
+)
+
 // PluginEntry is the entry point of SampleGen as a protoc plugin. If gapic-generator-go
 // is called as a protoc plugin or docker image with the intention to generate samples,
 // it will eventually call this function to do so.
-func PluginEntry(genReq *plugin.CodeGeneratorRequest) (*plugin.CodeGeneratorResponse, error) {
-	var (
-		gapicPkg     string
-		gapicFname   string
-		sampleFnames []string
-	)
-
+//
+// The parameter of genReq may also hold the options of others, e.g. those of the generators run
+// by the same plugin; any other option is an error.
+func PluginEntry(genReq *plugin.CodeGeneratorRequest, others ...*options.Set) (*plugin.CodeGeneratorResponse, error) {
 	// Always formats the output code if runs as a protoc plugin
 	nofmt := false
 
 	resp := plugin.CodeGeneratorResponse{}
-	if genReq.Parameter == nil {
-		return &resp, errors.E(nil, paramError)
+	opts, err := Options.Merge(others...).Parse(genReq.GetParameter())
+	if err != nil {
+		return &resp, err
 	}
-	for _, s := range strings.Split(*genReq.Parameter, ",") {
-		if e := strings.IndexByte(s, '='); e > 0 {
-			switch s[:e] {
-			case "gapic-config":
-				gapicFname = s[e+1:]
+	gapicPkg := opts.String("go-gapic-package")
+	gapicFname := opts.String("gapic-config")
+	sampleFnames := opts.Strings("sample")
 
-			case "sample":
-				sample := s[e+1:]
-				sampleFnames = append(sampleFnames, sample)
-
-			case "go-gapic-package":
-				gapicPkg = s[e+1:]
-			}
-		}
-	}
 	gen, err := InitGen(genReq.GetProtoFile(), sampleFnames, gapicFname, gapicPkg, nofmt)
 	if err != nil {
 		return &resp, err
@@ -0,0 +1,332 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package options parses the options of the protoc plugins, given to them in the parameter
+// of the CodeGeneratorRequest as a comma-separated list of "key=value" pairs.
+package options
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/googleapis/gapic-generator-go/internal/errors"
+)
+
+// Kind is the type of the value of an option.
+type Kind int
+
+const (
+	// String options take any value.
+	String Kind = iota
+	// Bool options take "true" or "false". An option given without a value is true.
+	Bool
+	// File options take the path of a file, which must exist.
+	File
+)
+
+// Option declares an option of a plugin.
+type Option struct {
+	// Name is the key of the option, e.g. "go-gapic-package".
+	Name string
+	Kind Kind
+
+	// Value names the value in the usage, e.g. "path;name". Bool options don't need one.
+	Value string
+
+	// Usage documents the option.
+	Usage string
+
+	// Default is the value of the option when it is not given, or given an empty value.
+	Default string
+
+	// Required options must be given a value.
+	Required bool
+
+	// Repeated options can be given more than once. The others can be given at most once.
+	Repeated bool
+
+	// Enum lists the values allowed, if not empty. They are compared regardless of case.
+	Enum []string
+
+	// Conflicts lists the names of the options that can't be set along with this one.
+	Conflicts []string
+}
+
+// Set is a set of options, those of a plugin. Options are parsed and documented in the order
+// they are declared.
+type Set struct {
+	opts   []*Option
+	byName map[string]*Option
+}
+
+// NewSet returns the set of opts.
+func NewSet(opts ...*Option) *Set {
+	s := &Set{byName: map[string]*Option{}}
+	for _, o := range opts {
+		s.add(o)
+	}
+	return s
+}
+
+func (s *Set) add(o *Option) {
+	if _, ok := s.byName[o.Name]; ok {
+		return
+	}
+	s.opts = append(s.opts, o)
+	s.byName[o.Name] = o
+}
+
+// Merge returns the set of the options of s and others, e.g. to parse the options of several
+// generators run by the same plugin. When options have the same name, the first one is kept.
+func (s *Set) Merge(others ...*Set) *Set {
+	m := NewSet(s.opts...)
+	for _, o := range others {
+		for _, opt := range o.opts {
+			m.add(opt)
+		}
+	}
+	return m
+}
+
+// Values holds the values of the options of a set, parsed by Set.Parse.
+type Values struct {
+	set  *Set
+	vals map[string][]string
+}
+
+// Parse parses param, the parameter of a CodeGeneratorRequest.
+// It reports an error if an option isn't in s, is given a value of the wrong kind,
+// is given more than once without being Repeated, or conflicts with another one,
+// and if a Required option is missing.
+func (s *Set) Parse(param string) (*Values, error) {
+	v := &Values{set: s, vals: map[string][]string{}}
+	for _, kv := range strings.Split(param, ",") {
+		if kv == "" {
+			continue
+		}
+		key, val := kv, ""
+		hasVal := false
+		if e := strings.IndexByte(kv, '='); e >= 0 {
+			key, val, hasVal = kv[:e], kv[e+1:], true
+		}
+
+		o, ok := s.byName[key]
+		if !ok {
+			if sugg := s.suggest(key); sugg != "" {
+				return nil, errors.E(nil, "unknown option %q, did you mean %q?", key, sugg)
+			}
+			return nil, errors.E(nil, "unknown option %q", key)
+		}
+		if _, ok := v.vals[key]; ok && !o.Repeated {
+			return nil, errors.E(nil, "option %q given more than once", key)
+		}
+
+		switch o.Kind {
+		case Bool:
+			if !hasVal {
+				val = "true"
+			}
+			if val != "" {
+				if _, err := strconv.ParseBool(val); err != nil {
+					return nil, errors.E(nil, "option %q must be true or false, got %q", key, val)
+				}
+			}
+		default:
+			if !hasVal {
+				return nil, errors.E(nil, "option %q needs a value, as in %s", key, o.usageName())
+			}
+		}
+		if val != "" {
+			if err := o.check(val); err != nil {
+				return nil, err
+			}
+		}
+		v.vals[key] = append(v.vals[key], val)
+	}
+
+	for _, o := range s.opts {
+		if o.Required && v.String(o.Name) == "" {
+			return nil, errors.E(nil, "missing option %s", o.usageName())
+		}
+		if !v.isSet(o) {
+			continue
+		}
+		for _, c := range o.Conflicts {
+			if co, ok := s.byName[c]; ok && v.isSet(co) {
+				return nil, errors.E(nil, "options %q and %q can't be set together", o.Name, c)
+			}
+		}
+	}
+	return v, nil
+}
+
+// check reports an error if val, a non-empty value of o, isn't allowed.
+func (o *Option) check(val string) error {
+	if len(o.Enum) > 0 {
+		ok := false
+		for _, e := range o.Enum {
+			ok = ok || strings.EqualFold(val, e)
+		}
+		if !ok {
+			return errors.E(nil, "option %q must be one of %s, got %q", o.Name, strings.Join(o.Enum, ", "), val)
+		}
+	}
+	if o.Kind == File {
+		if _, err := os.Stat(val); err != nil {
+			return errors.E(err, "option %q must name a file", o.Name)
+		}
+	}
+	return nil
+}
+
+// isSet reports whether the option o was given a value, other than false for a Bool option.
+func (v *Values) isSet(o *Option) bool {
+	for _, val := range v.vals[o.Name] {
+		if val == "" {
+			continue
+		}
+		if b, _ := strconv.ParseBool(val); b || o.Kind != Bool {
+			return true
+		}
+	}
+	return false
+}
+
+// suggest reports the name of the option of s closest to the unknown name, if one is close enough
+// to be a typo of it, or "".
+func (s *Set) suggest(name string) string {
+	best, bestDist := "", len(name)/2+1
+	for _, o := range s.opts {
+		if d := editDistance(name, o.Name); d < bestDist {
+			best, bestDist = o.Name, d
+		}
+	}
+	return best
+}
+
+// editDistance reports the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			d := prev[j-1]
+			if a[i-1] != b[j-1] {
+				d++
+			}
+			if prev[j]+1 < d {
+				d = prev[j] + 1
+			}
+			if cur[j-1]+1 < d {
+				d = cur[j-1] + 1
+			}
+			cur[j] = d
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// String reports the value of the option name, its last value if it is Repeated,
+// or its default if it is not given a value.
+func (v *Values) String(name string) string {
+	vals := v.vals[name]
+	if len(vals) == 0 || vals[len(vals)-1] == "" {
+		if o, ok := v.set.byName[name]; ok {
+			return o.Default
+		}
+		return ""
+	}
+	return vals[len(vals)-1]
+}
+
+// Strings reports the non-empty values of the Repeated option name, in order.
+func (v *Values) Strings(name string) []string {
+	var vals []string
+	for _, val := range v.vals[name] {
+		if val != "" {
+			vals = append(vals, val)
+		}
+	}
+	return vals
+}
+
+// Bool reports the value of the Bool option name.
+func (v *Values) Bool(name string) bool {
+	b, _ := strconv.ParseBool(v.String(name))
+	return b
+}
+
+// Open opens the file named by the File option name, or returns nil if it is not given.
+// The caller must close the file.
+func (v *Values) Open(name string) (*os.File, error) {
+	path := v.String(name)
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.E(err, "error opening %s", name)
+	}
+	return f, nil
+}
+
+// usageName reports how o is given, e.g. "go-gapic-package=path;name".
+func (o *Option) usageName() string {
+	if o.Kind == Bool {
+		return o.Name + "[=true|false]"
+	}
+	val := o.Value
+	if val == "" {
+		val = "value"
+	}
+	return o.Name + "=" + val
+}
+
+// Usage documents the options of s, in the style of the usage of command-line flags.
+func (s *Set) Usage() string {
+	var b strings.Builder
+	for _, o := range s.opts {
+		fmt.Fprintf(&b, "  %s\n", o.usageName())
+
+		var notes []string
+		if o.Required {
+			notes = append(notes, "required")
+		}
+		if o.Repeated {
+			notes = append(notes, "can be repeated")
+		}
+		if len(o.Enum) > 0 {
+			notes = append(notes, "one of "+strings.Join(o.Enum, ", "))
+		}
+		if o.Default != "" {
+			notes = append(notes, "default "+o.Default)
+		}
+		if len(o.Conflicts) > 0 {
+			notes = append(notes, "can't be set with "+strings.Join(o.Conflicts, ", "))
+		}
+		usage := o.Usage
+		if len(notes) > 0 {
+			usage += " (" + strings.Join(notes, "; ") + ")"
+		}
+		fmt.Fprintf(&b, "    \t%s\n", usage)
+	}
+	return b.String()
+}
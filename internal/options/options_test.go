@@ -0,0 +1,216 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testSet() *Set {
+	return NewSet(
+		&Option{Name: "pkg", Value: "path;name", Usage: "the package", Required: true},
+		&Option{Name: "config", Kind: File, Value: "path", Usage: "the config"},
+		&Option{Name: "sample", Kind: File, Value: "path", Usage: "a sample", Repeated: true},
+		&Option{Name: "level", Usage: "the level", Enum: []string{"alpha", "beta"}},
+		&Option{Name: "transport", Usage: "the transports", Default: "grpc"},
+		&Option{Name: "fakes", Kind: Bool, Usage: "whether to generate fakes"},
+		&Option{Name: "only", Kind: Bool, Usage: "whether to generate only", Conflicts: []string{"fakes"}},
+	)
+}
+
+func TestParse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "options")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(file, []byte("foo: bar"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	set := testSet()
+
+	for _, tst := range []struct {
+		name    string
+		in      string
+		check   func(v *Values) (got, want interface{})
+		wantErr string
+	}{
+		{
+			name: "string",
+			in:   "pkg=a/b;b",
+			check: func(v *Values) (interface{}, interface{}) {
+				return v.String("pkg"), "a/b;b"
+			},
+		},
+		{
+			name: "default",
+			in:   "pkg=a/b;b,transport=",
+			check: func(v *Values) (interface{}, interface{}) {
+				return v.String("transport"), "grpc"
+			},
+		},
+		{
+			name: "bare bool",
+			in:   "pkg=a/b;b,fakes",
+			check: func(v *Values) (interface{}, interface{}) {
+				return v.Bool("fakes"), true
+			},
+		},
+		{
+			name: "false bool",
+			in:   "pkg=a/b;b,fakes=false,only",
+			check: func(v *Values) (interface{}, interface{}) {
+				return v.Bool("fakes"), false
+			},
+		},
+		{
+			name: "repeated",
+			in:   "pkg=a/b;b,sample=" + file + ",sample=,sample=" + file,
+			check: func(v *Values) (interface{}, interface{}) {
+				return v.Strings("sample"), []string{file, file}
+			},
+		},
+		{
+			name: "enum",
+			in:   "pkg=a/b;b,level=Beta",
+			check: func(v *Values) (interface{}, interface{}) {
+				return v.String("level"), "Beta"
+			},
+		},
+		{
+			name: "file",
+			in:   "pkg=a/b;b,config=" + file,
+			check: func(v *Values) (interface{}, interface{}) {
+				return v.String("config"), file
+			},
+		},
+		{
+			name:    "unknown",
+			in:      "pkg=a/b;b,fake",
+			wantErr: `unknown option "fake", did you mean "fakes"?`,
+		},
+		{
+			name:    "unknown without suggestion",
+			in:      "pkg=a/b;b,frobnicate=true",
+			wantErr: `unknown option "frobnicate"`,
+		},
+		{
+			name:    "missing",
+			in:      "fakes",
+			wantErr: "missing option pkg=path;name",
+		},
+		{
+			name:    "empty required",
+			in:      "pkg=",
+			wantErr: "missing option pkg=path;name",
+		},
+		{
+			name:    "duplicate",
+			in:      "pkg=a/b;b,pkg=c/d;d",
+			wantErr: `option "pkg" given more than once`,
+		},
+		{
+			name:    "no value",
+			in:      "pkg",
+			wantErr: `option "pkg" needs a value, as in pkg=path;name`,
+		},
+		{
+			name:    "bad bool",
+			in:      "pkg=a/b;b,fakes=yes",
+			wantErr: `option "fakes" must be true or false, got "yes"`,
+		},
+		{
+			name:    "bad enum",
+			in:      "pkg=a/b;b,level=gamma",
+			wantErr: `option "level" must be one of alpha, beta, got "gamma"`,
+		},
+		{
+			name:    "missing file",
+			in:      "pkg=a/b;b,config=" + filepath.Join(dir, "nope.yaml"),
+			wantErr: `option "config" must name a file`,
+		},
+		{
+			name:    "conflict",
+			in:      "pkg=a/b;b,fakes,only=true",
+			wantErr: `options "only" and "fakes" can't be set together`,
+		},
+	} {
+		v, err := set.Parse(tst.in)
+		if tst.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tst.wantErr) {
+				t.Errorf("%s: Parse(%q) error = %v, want %q", tst.name, tst.in, err, tst.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Parse(%q) error = %v", tst.name, tst.in, err)
+			continue
+		}
+		if got, want := tst.check(v); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: Parse(%q) = %v, want %v", tst.name, tst.in, got, want)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a := NewSet(&Option{Name: "pkg", Usage: "a's package", Required: true}, &Option{Name: "a"})
+	b := NewSet(&Option{Name: "pkg", Usage: "b's package"}, &Option{Name: "b"})
+
+	m := a.Merge(b)
+	var got []string
+	for _, o := range m.opts {
+		got = append(got, o.Name)
+	}
+	if want := []string{"pkg", "a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Merge() = %v, want %v", got, want)
+	}
+	if usage := m.byName["pkg"].Usage; usage != "a's package" {
+		t.Errorf("Merge() kept pkg with usage %q, want a's", usage)
+	}
+	if _, err := m.Parse("a=1,b=2"); err == nil {
+		t.Errorf("Merge().Parse() doesn't require pkg")
+	}
+	if len(a.opts) != 2 {
+		t.Errorf("Merge() modified its receiver")
+	}
+}
+
+func TestUsage(t *testing.T) {
+	got := testSet().Usage()
+	want := `  pkg=path;name
+    	the package (required)
+  config=path
+    	the config
+  sample=path
+    	a sample (can be repeated)
+  level=value
+    	the level (one of alpha, beta)
+  transport=value
+    	the transports (default grpc)
+  fakes[=true|false]
+    	whether to generate fakes
+  only[=true|false]
+    	whether to generate only (can't be set with fakes)
+`
+	if got != want {
+		t.Errorf("Usage() = %q, want %q", got, want)
+	}
+}
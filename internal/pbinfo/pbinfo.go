@@ -117,6 +117,57 @@ func addMessage(typMap map[string]ProtoType, parentMap map[ProtoType]ProtoType,
 	}
 }
 
+// Resolve looks up the type referred to by name from within scope, following the protobuf
+// scoping rules. The scope is the fully-qualified name, without the leading dot, of the package
+// or message the name appears in, e.g. "google.example.v1" or "google.example.v1.Book".
+//
+// A name with a leading dot is fully-qualified. Otherwise, the first component of the name is
+// searched for from the innermost scope outwards, and the rest of the name is resolved relative
+// to the first match. So "Book.Author" used in package "google.example.v1" resolves to
+// ".google.example.v1.Book.Author", and "google.protobuf.Empty" resolves to ".google.protobuf.Empty"
+// unless a closer scope declares something named "google".
+// Resolve returns nil if the name does not refer to a known type.
+func (in *Info) Resolve(name, scope string) ProtoType {
+	if strings.HasPrefix(name, ".") {
+		return in.Type[name]
+	}
+
+	first := name
+	if p := strings.IndexByte(name, '.'); p >= 0 {
+		first = name[:p]
+	}
+	for {
+		prefix := "."
+		if scope != "" {
+			prefix = "." + scope + "."
+		}
+		if in.declares(prefix + first) {
+			return in.Type[prefix+name]
+		}
+		if scope == "" {
+			return nil
+		}
+		if p := strings.LastIndexByte(scope, '.'); p >= 0 {
+			scope = scope[:p]
+		} else {
+			scope = ""
+		}
+	}
+}
+
+// declares reports whether fullName, with a leading dot, names a known type or package.
+func (in *Info) declares(fullName string) bool {
+	if _, ok := in.Type[fullName]; ok {
+		return true
+	}
+	for n := range in.Type {
+		if strings.HasPrefix(n, fullName+".") {
+			return true
+		}
+	}
+	return false
+}
+
 type ImportSpec struct {
 	Name, Path string
 }
@@ -61,3 +61,57 @@ func TestNameSpec(t *testing.T) {
 		}
 	}
 }
+
+func TestResolve(t *testing.T) {
+	t.Parallel()
+
+	inner := &descriptor.DescriptorProto{Name: proto.String("Inner")}
+	outer := &descriptor.DescriptorProto{
+		Name:       proto.String("Outer"),
+		NestedType: []*descriptor.DescriptorProto{inner},
+	}
+	// Shadows the "google" package inside my.pkg.
+	google := &descriptor.DescriptorProto{Name: proto.String("google")}
+	file := &descriptor.FileDescriptorProto{
+		Package:     proto.String("my.pkg"),
+		MessageType: []*descriptor.DescriptorProto{outer, google},
+	}
+	empty := &descriptor.DescriptorProto{Name: proto.String("Empty")}
+	emptyFile := &descriptor.FileDescriptorProto{
+		Package:     proto.String("google.protobuf"),
+		MessageType: []*descriptor.DescriptorProto{empty},
+	}
+	other := &descriptor.DescriptorProto{Name: proto.String("Other")}
+	otherFile := &descriptor.FileDescriptorProto{
+		Package:     proto.String("my.other"),
+		MessageType: []*descriptor.DescriptorProto{other},
+	}
+
+	info := Of([]*descriptor.FileDescriptorProto{file, emptyFile, otherFile})
+
+	for _, tst := range []struct {
+		name, scope string
+		want        ProtoType
+	}{
+		{"Outer", "my.pkg", outer},
+		{"Outer.Inner", "my.pkg", inner},
+		{"Inner", "my.pkg.Outer", inner},
+		{"Outer.Inner", "my.pkg.Outer.Inner", inner},
+		{"my.pkg.Outer.Inner", "my.pkg", inner},
+		{".my.pkg.Outer", "my.other", outer},
+		{"other.Other", "my.pkg", other},
+		{"pkg.Outer", "my.other", outer},
+		{"google.protobuf.Empty", "my.other", empty},
+		{".google.protobuf.Empty", "my.pkg", empty},
+
+		// "google" resolves to my.pkg.google, which has no Empty.
+		{"google.protobuf.Empty", "my.pkg", nil},
+		// Nested types are not visible from the package scope.
+		{"Inner", "my.pkg", nil},
+		{"Missing", "my.pkg", nil},
+	} {
+		if got := info.Resolve(tst.name, tst.scope); got != tst.want {
+			t.Errorf("Resolve(%q, %q) = %v, want %v", tst.name, tst.scope, got, tst.want)
+		}
+	}
+}
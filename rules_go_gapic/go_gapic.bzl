@@ -21,6 +21,8 @@ def go_gapic_library(
   importpath,
   deps,
   release_level = "",
+  transport = "",
+  validate_required = False,
   grpc_service_config = None,
   service_yaml = None,
   **kwargs):
@@ -41,6 +43,8 @@ def go_gapic_library(
     plugin_args = [
       "go-gapic-package={}".format(importpath),
       "release-level={}".format(release_level),
+      "transport={}".format(transport),
+      "validate-required={}".format("true" if validate_required else "false"),
     ],
     plugin_file_args = file_args,
     output_type = "go_gapic",
@@ -57,10 +61,13 @@ def go_gapic_library(
     "@org_golang_google_api//option:go_default_library",
     "@org_golang_google_api//iterator:go_default_library",
     "@org_golang_google_api//transport:go_default_library",
+    "@org_golang_google_api//transport/http:go_default_library",
+    "@org_golang_google_api//googleapi:go_default_library",
     "@org_golang_google_grpc//:go_default_library",
     "@org_golang_google_grpc//codes:go_default_library",
     "@org_golang_google_grpc//metadata:go_default_library",
     "@com_github_golang_protobuf//proto:go_default_library",
+    "@com_github_golang_protobuf//jsonpb:go_default_library_gen",
     "@com_github_golang_protobuf//ptypes:go_default_library",
     "@com_github_golang_protobuf//ptypes/empty:go_default_library",
     "@com_github_golang_protobuf//ptypes/timestamp:go_default_library",
@@ -16,43 +16,19 @@ package showcase_integration
 
 import (
 	"context"
-	"flag"
 	"io"
-	"log"
-	"os"
 	"strings"
 	"testing"
 	"time"
 
-	showcase "cloud.google.com/go/showcase/apiv1beta1"
 	durationpb "github.com/golang/protobuf/ptypes/duration"
 	genprotopb "github.com/googleapis/gapic-showcase/server/genproto"
 	"google.golang.org/api/iterator"
-	"google.golang.org/api/option"
 	spb "google.golang.org/genproto/googleapis/rpc/status"
-	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
-var client *showcase.EchoClient
-
-func TestMain(m *testing.M) {
-	flag.Parse()
-
-	conn, err := grpc.Dial("localhost:7469", grpc.WithInsecure())
-	if err != nil {
-		log.Fatal(err)
-	}
-	clientOpt := option.WithGRPCConn(conn)
-	client, err = showcase.NewEchoClient(context.Background(), clientOpt)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	os.Exit(m.Run())
-}
-
 func TestEcho(t *testing.T) {
 	t.Parallel()
 	content := "hello world!"
@@ -90,6 +66,27 @@ func TestEcho_error(t *testing.T) {
 	}
 }
 
+func TestEcho_retry(t *testing.T) {
+	t.Parallel()
+	content := flakyPrefix + "TestEcho_retry"
+	req := &genprotopb.EchoRequest{
+		Response: &genprotopb.EchoRequest_Content{
+			Content: content,
+		},
+	}
+	resp, err := client.Echo(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetContent() != content {
+		t.Errorf("Echo() = %q, want %q", resp.GetContent(), content)
+	}
+	// The gRPC ServiceConfig retries Echo when it is Unavailable.
+	if n := echoServer.echoCalls(content); n != 2 {
+		t.Errorf("Echo() called the server %d times, want 2", n)
+	}
+}
+
 func TestExpand(t *testing.T) {
 	t.Parallel()
 	content := "The rain in Spain stays mainly on the plain!"
@@ -166,6 +163,60 @@ func TestChat(t *testing.T) {
 	}
 }
 
+// TestChat_routingHeaders receives while sending, as is usual for bidirectional streams,
+// and checks that the stream is opened with the request params of its first request:
+// the service config routes Chat requests by their content.
+func TestChat_routingHeaders(t *testing.T) {
+	t.Parallel()
+	contents := []string{"rooms/routing", "rooms/routing/again", "not a room"}
+	s, err := client.Chat(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Recv is called before the first Send, which opens the stream.
+	received := make(chan []string, 1)
+	errc := make(chan error, 1)
+	go func() {
+		var resps []string
+		for {
+			resp, err := s.Recv()
+			if err == io.EOF {
+				received <- resps
+				return
+			}
+			if err != nil {
+				errc <- err
+				return
+			}
+			resps = append(resps, resp.GetContent())
+		}
+	}()
+
+	for _, content := range contents {
+		if err := s.Send(&genprotopb.EchoRequest{
+			Response: &genprotopb.EchoRequest_Content{Content: content},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-received:
+		if strings.Join(got, ",") != strings.Join(contents, ",") {
+			t.Errorf("Chat() = %q, want %q", got, contents)
+		}
+	case err := <-errc:
+		t.Fatal(err)
+	}
+
+	if want := "content=rooms%2Frouting"; !echoServer.chatOpened(want) {
+		t.Errorf("Chat() opened no stream with request params %q", want)
+	}
+}
+
 func TestWait(t *testing.T) {
 	t.Parallel()
 	content := "hello world!"
@@ -190,6 +241,30 @@ func TestWait(t *testing.T) {
 	}
 }
 
+func TestWait_error(t *testing.T) {
+	t.Parallel()
+	val := codes.Aborted
+	req := &genprotopb.WaitRequest{
+		End: &genprotopb.WaitRequest_Ttl{
+			Ttl: &durationpb.Duration{Nanos: 100},
+		},
+		Response: &genprotopb.WaitRequest_Error{
+			Error: &spb.Status{Code: int32(val)},
+		},
+	}
+	op, err := client.Wait(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := op.Wait(context.Background())
+	if resp != nil {
+		t.Errorf("Wait() = %v, wanted error %d", resp, val)
+	}
+	if status.Code(err) != val {
+		t.Errorf("Wait() errors with %d, want %d", status.Code(err), val)
+	}
+}
+
 func TestWait_timeout(t *testing.T) {
 	t.Parallel()
 	content := "hello world!"
@@ -201,9 +276,14 @@ func TestWait_timeout(t *testing.T) {
 			Success: &genprotopb.WaitResponse{Content: content},
 		},
 	}
-	ctx, _ := context.WithTimeout(context.Background(), 1*time.Millisecond)
-	resp, err := client.Wait(ctx, req)
-
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Millisecond)
+	defer cancel()
+	op, err := client.Wait(ctx, req)
+	if err != nil {
+		// The deadline passed before the operation started.
+		return
+	}
+	resp, err := op.Wait(ctx)
 	if err == nil {
 		t.Errorf("Wait() = %v, want error", resp)
 	}
@@ -0,0 +1,113 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package showcase_integration
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	genprotopb "github.com/googleapis/gapic-showcase/server/genproto"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUserCRUD(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	user, err := identityClient.CreateUser(ctx, &genprotopb.CreateUserRequest{
+		User: &genprotopb.User{DisplayName: "Jane", Email: "jane@example.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.GetName() == "" {
+		t.Errorf("CreateUser() = %v, want a name", user)
+	}
+
+	got, err := identityClient.GetUser(ctx, &genprotopb.GetUserRequest{Name: user.GetName()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.GetEmail() != user.GetEmail() {
+		t.Errorf("GetUser() = %v, want %v", got, user)
+	}
+
+	user.DisplayName = "Janet"
+	got, err = identityClient.UpdateUser(ctx, &genprotopb.UpdateUserRequest{User: user})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.GetDisplayName() != "Janet" {
+		t.Errorf("UpdateUser() = %v, want display name %q", got, "Janet")
+	}
+
+	if err := identityClient.DeleteUser(ctx, &genprotopb.DeleteUserRequest{Name: user.GetName()}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = identityClient.GetUser(ctx, &genprotopb.GetUserRequest{Name: user.GetName()})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("GetUser() after DeleteUser() errors with %v, want %v", err, codes.NotFound)
+	}
+}
+
+func TestUserCRUD_error(t *testing.T) {
+	t.Parallel()
+	val := codes.InvalidArgument
+	resp, err := identityClient.CreateUser(context.Background(), &genprotopb.CreateUserRequest{
+		User: &genprotopb.User{DisplayName: "No Email"},
+	})
+	if resp != nil {
+		t.Errorf("CreateUser() = %v, wanted error %d", resp, val)
+	}
+	if status.Code(err) != val {
+		t.Errorf("CreateUser() errors with %d, want %d", status.Code(err), val)
+	}
+}
+
+func TestListUsers(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	want := map[string]bool{}
+	for i := 0; i < 5; i++ {
+		user, err := identityClient.CreateUser(ctx, &genprotopb.CreateUserRequest{
+			User: &genprotopb.User{
+				DisplayName: fmt.Sprintf("User %d", i),
+				Email:       fmt.Sprintf("list-user-%d@example.com", i),
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want[user.GetName()] = true
+	}
+
+	// The other tests may create users too.
+	iter := identityClient.ListUsers(ctx, &genprotopb.ListUsersRequest{PageSize: 2})
+	for {
+		user, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		delete(want, user.GetName())
+	}
+	if len(want) > 0 {
+		t.Errorf("ListUsers() is missing %v", want)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package showcase_integration
+
+import (
+	"context"
+	"testing"
+
+	genprotopb "github.com/googleapis/gapic-showcase/server/genproto"
+	"google.golang.org/api/iterator"
+)
+
+func TestBlurbs(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	user, err := identityClient.CreateUser(ctx, &genprotopb.CreateUserRequest{
+		User: &genprotopb.User{DisplayName: "Blurber", Email: "blurber@example.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	room, err := messagingClient.CreateRoom(ctx, &genprotopb.CreateRoomRequest{
+		Room: &genprotopb.Room{DisplayName: "Blurbs"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents := []string{"hello", "hello again", "goodbye"}
+	for _, content := range contents {
+		_, err := messagingClient.CreateBlurb(ctx, &genprotopb.CreateBlurbRequest{
+			Parent: room.GetName(),
+			Blurb: &genprotopb.Blurb{
+				User:    user.GetName(),
+				Content: &genprotopb.Blurb_Text{Text: content},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	iter := messagingClient.ListBlurbs(ctx, &genprotopb.ListBlurbsRequest{Parent: room.GetName(), PageSize: 2})
+	var got []string
+	for {
+		blurb, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, blurb.GetText())
+	}
+	if len(got) != len(contents) {
+		t.Errorf("ListBlurbs() = %q, want %q", got, contents)
+	}
+
+	op, err := messagingClient.SearchBlurbs(ctx, &genprotopb.SearchBlurbsRequest{
+		Parent:   room.GetName(),
+		Query:    "hello",
+		PageSize: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := op.Wait(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(resp.GetBlurbs()); n != 2 {
+		t.Errorf("SearchBlurbs() found %d blurbs, want 2", n)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package showcase_integration
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	showcase "cloud.google.com/go/showcase/apiv1beta1"
+	genprotopb "github.com/googleapis/gapic-showcase/server/genproto"
+	"github.com/googleapis/gapic-showcase/server/services"
+	"google.golang.org/api/option"
+	lropb "google.golang.org/genproto/googleapis/longrunning"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+var (
+	client          *showcase.EchoClient
+	identityClient  *showcase.IdentityClient
+	messagingClient *showcase.MessagingClient
+
+	echoServer = &testEchoServer{EchoServer: services.NewEchoServer()}
+)
+
+// TestMain runs the tests against the Showcase services, served in-process
+// on an in-memory listener.
+func TestMain(m *testing.M) {
+	flag.Parse()
+
+	identityServer := services.NewIdentityServer()
+	messagingServer := services.NewMessagingServer(identityServer)
+	s := grpc.NewServer()
+	genprotopb.RegisterEchoServer(s, echoServer)
+	genprotopb.RegisterIdentityServer(s, identityServer)
+	genprotopb.RegisterMessagingServer(s, messagingServer)
+	lropb.RegisterOperationsServer(s, services.NewOperationsServer(messagingServer))
+
+	lis := bufconn.Listen(1 << 20)
+	go s.Serve(lis)
+
+	dial := func(ctx context.Context, _ string) (net.Conn, error) {
+		return lis.Dial()
+	}
+	conn, err := grpc.Dial("bufnet", grpc.WithContextDialer(dial), grpc.WithInsecure())
+	if err != nil {
+		log.Fatal(err)
+	}
+	clientOpt := option.WithGRPCConn(conn)
+	ctx := context.Background()
+	client, err = showcase.NewEchoClient(ctx, clientOpt)
+	if err != nil {
+		log.Fatal(err)
+	}
+	identityClient, err = showcase.NewIdentityClient(ctx, clientOpt)
+	if err != nil {
+		log.Fatal(err)
+	}
+	messagingClient, err = showcase.NewMessagingClient(ctx, clientOpt)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	code := m.Run()
+	conn.Close()
+	s.Stop()
+	os.Exit(code)
+}
+
+// flakyPrefix marks the contents of the requests that testEchoServer fails.
+const flakyPrefix = "flaky:"
+
+// testEchoServer is the Showcase Echo service, except that Echo fails with Unavailable
+// the first time it is called with each content starting with flakyPrefix, so that
+// the tests can check the retries configured in the gRPC ServiceConfig, and that it
+// records the request params of Chat streams.
+type testEchoServer struct {
+	genprotopb.EchoServer
+
+	mu         sync.Mutex
+	calls      map[string]int
+	chatParams []string
+}
+
+func (s *testEchoServer) Echo(ctx context.Context, req *genprotopb.EchoRequest) (*genprotopb.EchoResponse, error) {
+	if content := req.GetContent(); strings.HasPrefix(content, flakyPrefix) {
+		s.mu.Lock()
+		if s.calls == nil {
+			s.calls = map[string]int{}
+		}
+		s.calls[content]++
+		n := s.calls[content]
+		s.mu.Unlock()
+
+		if n == 1 {
+			return nil, status.Errorf(codes.Unavailable, "%s failed once", content)
+		}
+	}
+	return s.EchoServer.Echo(ctx, req)
+}
+
+// echoCalls reports the number of times Echo was called with content, starting with flakyPrefix.
+func (s *testEchoServer) echoCalls(content string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[content]
+}
+
+func (s *testEchoServer) Chat(stream genprotopb.Echo_ChatServer) error {
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	s.mu.Lock()
+	s.chatParams = append(s.chatParams, md.Get("x-goog-request-params")...)
+	s.mu.Unlock()
+	return s.EchoServer.Chat(stream)
+}
+
+// chatOpened reports whether a Chat stream was opened with the request params params.
+func (s *testEchoServer) chatOpened(params string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range s.chatParams {
+		if p == params {
+			return true
+		}
+	}
+	return false
+}
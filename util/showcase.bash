@@ -23,38 +23,38 @@ fi
 
 go install ./cmd/protoc-gen-go_gapic
 
-SHOWCASE_SEMVER=0.6.0
-
 pushd showcase
+
+# The tests serve the Showcase services of the module required by go.mod in-process,
+# so the clients are generated from the protos of the same version. Its descriptor set,
+# with those of its dependencies, is checked in next to its gRPC service config, so that
+# nothing is downloaded.
+SHOWCASE_SEMVER=$(awk '$1 == "github.com/googleapis/gapic-showcase" { print substr($2, 2) }' go.mod)
+SHOWCASE_DESC=gapic-showcase-$SHOWCASE_SEMVER.desc
+if [[ ! -f $SHOWCASE_DESC ]]; then
+	echo >&2 "missing $SHOWCASE_DESC: build it from the protos of gapic-showcase v$SHOWCASE_SEMVER with" \
+		"protoc --include_imports --include_source_info --descriptor_set_out=$SHOWCASE_DESC"
+	exit 1
+fi
+
 rm -rf gen
 mkdir gen
 
-curl -L -O https://github.com/googleapis/gapic-showcase/releases/download/v$SHOWCASE_SEMVER/showcase_grpc_service_config.json
-
 protoc \
 	--go_gapic_out ./gen \
 	--go_gapic_opt 'go-gapic-package=cloud.google.com/go/showcase/apiv1beta1;showcase' \
 	--go_gapic_opt 'grpc-service-config=showcase_grpc_service_config.json' \
-	--descriptor_set_in=<(curl -sSL https://github.com/googleapis/gapic-showcase/releases/download/v$SHOWCASE_SEMVER/gapic-showcase-$SHOWCASE_SEMVER.desc) \
-	google/showcase/v1beta1/echo.proto
+	--go_gapic_opt 'gapic-service-config=showcase_v1beta1.yaml' \
+	--descriptor_set_in=$SHOWCASE_DESC \
+	google/showcase/v1beta1/echo.proto \
+	google/showcase/v1beta1/identity.proto \
+	google/showcase/v1beta1/messaging.proto
 
 pushd gen/cloud.google.com/go/showcase
 go mod init cloud.google.com/go/showcase
 popd
 
-hostos=$(go env GOHOSTOS)
-hostarch=$(go env GOHOSTARCH)
-
-curl -sSL https://github.com/googleapis/gapic-showcase/releases/download/v$SHOWCASE_SEMVER/gapic-showcase-$SHOWCASE_SEMVER-$hostos-$hostarch.tar.gz | tar xz
-./gapic-showcase run &
-showcase_pid=$!
-
-stop_showcase() {
-	kill $showcase_pid
-	# Wait for the process to die, but don't report error from the kill.
-	wait $showcase_pid || true
-}
-trap stop_showcase EXIT
-
-go test -count=1 ./...
+# The tests serve the Showcase services in-process, so no server needs to be running.
+# Streams are used concurrently, so the tests are run with the race detector.
+go test -count=1 -race ./...
 popd